@@ -0,0 +1,72 @@
+// Package main grants a permission of the admin panel to a user, or revokes
+// it, e.g. to let the first administrator manage the users.
+//
+// Usage:
+//
+//	go run ./cmd/grant-permission -email ana@example.com -permission users.manage [-revoke]
+//
+// The "*" permission grants every permission. The database is configured
+// from the environment as for the server.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"belcamp/internal/database"
+	"belcamp/internal/logging"
+	"belcamp/internal/service"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	email := flag.String("email", "", "email of the user")
+	permission := flag.String("permission", "", `permission to grant, e.g. users.manage, or "*" for all`)
+	revoke := flag.Bool("revoke", false, "revoke the permission instead of granting it")
+	flag.Parse()
+
+	envErr := godotenv.Load()
+	logging.Setup()
+	if envErr != nil {
+		slog.Warn(".env file not found, using environment variables")
+	}
+
+	if *email == "" || *permission == "" {
+		fatal("Missing arguments", fmt.Errorf("both -email and -permission are required"))
+	}
+
+	db, err := database.Initialize()
+	if err != nil {
+		fatal("Failed to connect to database", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		fatal("Failed to migrate database", err)
+	}
+
+	user, err := service.NewAuthService(db).GetUserByEmail(*email)
+	if err != nil {
+		fatal("Failed to find the user", err)
+	}
+
+	ctx := context.Background()
+	permissions := service.NewPermissionService(db)
+	if *revoke {
+		err = permissions.Revoke(ctx, user.ID, *permission)
+	} else {
+		err = permissions.Grant(ctx, user.ID, *permission)
+	}
+	if err != nil {
+		fatal("Failed to change the permission", err)
+	}
+	slog.Info("Permission changed", "email", *email, "permission", *permission, "revoked", *revoke)
+}
+
+// fatal logs the error and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
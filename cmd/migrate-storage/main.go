@@ -0,0 +1,142 @@
+// Package main copies the uploaded files from one storage backend to another,
+// e.g. from the local disk to an S3 bucket before switching STORAGE_DRIVER.
+//
+// Usage:
+//
+//	go run ./cmd/migrate-storage -from local -to s3 [-prefix catalogs/] [-dry-run] [-delete]
+//
+// Both backends are configured from the environment as for the server. Files
+// already at the destination with the same size are skipped, so the command
+// can be run again after an interruption.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"mime"
+	"os"
+	"os/signal"
+	"path"
+	"syscall"
+
+	"belcamp/internal/logging"
+	"belcamp/internal/storage"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	from := flag.String("from", "local", "storage to copy from: local or s3")
+	to := flag.String("to", "s3", "storage to copy to: local or s3")
+	prefix := flag.String("prefix", "", "only copy the files whose key starts with this prefix")
+	dryRun := flag.Bool("dry-run", false, "list the files to copy without copying them")
+	remove := flag.Bool("delete", false, "delete the files from the source once copied")
+	flag.Parse()
+
+	envErr := godotenv.Load()
+	logging.Setup()
+	if envErr != nil {
+		slog.Warn(".env file not found, using environment variables")
+	}
+
+	if *from == *to {
+		fatal("Source and destination are the same storage", fmt.Errorf("both are %q", *from))
+	}
+	source, err := storage.New(*from)
+	if err != nil {
+		fatal("Failed to open the source storage", err)
+	}
+	target, err := storage.New(*to)
+	if err != nil {
+		fatal("Failed to open the destination storage", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if bucket, ok := target.(*storage.S3); ok && !*dryRun {
+		if err := bucket.EnsureBucket(ctx); err != nil {
+			fatal("Failed to create the bucket", err)
+		}
+	}
+
+	copied, skipped, failed, err := migrate(ctx, source, target, *prefix, *dryRun, *remove)
+	if err != nil {
+		fatal("Failed to migrate files", err)
+	}
+	slog.Info("Migration finished", "copied", copied, "skipped", skipped, "failed", failed, "dry_run", *dryRun)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// migrate copies the files under the prefix that the target does not have
+// yet. Files that fail are logged and counted, the others are still copied.
+func migrate(ctx context.Context, source, target storage.Storage, prefix string, dryRun, remove bool) (copied, skipped, failed int, err error) {
+	objects, err := source.List(ctx, prefix)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	existing, err := target.List(ctx, prefix)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	sizes := make(map[string]int64, len(existing))
+	for _, object := range existing {
+		sizes[object.Key] = object.Size
+	}
+
+	for _, object := range objects {
+		if err := ctx.Err(); err != nil {
+			return copied, skipped, failed, err
+		}
+		if size, ok := sizes[object.Key]; ok && size == object.Size {
+			skipped++
+			continue
+		}
+		if dryRun {
+			slog.Info("Would copy", "key", object.Key, "size", object.Size)
+			copied++
+			continue
+		}
+
+		if err := copyFile(ctx, source, target, object); err != nil {
+			slog.Error("Failed to copy file", "key", object.Key, "error", err)
+			failed++
+			continue
+		}
+		copied++
+		if remove {
+			if err := source.Delete(ctx, object.Key); err != nil {
+				slog.Error("Failed to delete copied file", "key", object.Key, "error", err)
+			}
+		}
+	}
+	return copied, skipped, failed, nil
+}
+
+// copyFile copies a file between the storages
+func copyFile(ctx context.Context, source, target storage.Storage, object storage.Object) error {
+	r, err := source.Get(ctx, object.Key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return target.Put(ctx, object.Key, r, object.Size, contentType(object.Key))
+}
+
+// contentType returns the type of the file from its extension
+func contentType(key string) string {
+	if kind := mime.TypeByExtension(path.Ext(key)); kind != "" {
+		return kind
+	}
+	return "application/octet-stream"
+}
+
+// fatal logs the error and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
@@ -3,7 +3,7 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,7 +12,10 @@ import (
 
 	"belcamp/internal/database"
 	"belcamp/internal/infrastructure/setup"
+	"belcamp/internal/logging"
+	"belcamp/internal/metrics"
 	"belcamp/internal/middleware"
+	"belcamp/internal/storage"
 	"belcamp/internal/utils"
 
 	"github.com/gin-contrib/sessions"
@@ -25,24 +28,34 @@ import (
 func main() {
 	// Initialize configuration
 	if err := initConfig(); err != nil {
-		log.Fatalf("Failed to initialize configuration: %v", err)
+		fatal("Failed to initialize configuration", err)
 	}
 
 	// Initialize database
 	db, err := database.Initialize()
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("Failed to connect to database", err)
 	}
 
 	// Get the underlying SQL DB connection
 	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatalf("Failed to get database connection: %v", err)
+		fatal("Failed to get database connection", err)
 	}
 
 	// Ensure the connection is closed when the application exits
 	defer sqlDB.Close()
 
+	// Create the tables owned by the admin panel, e.g. saved table views
+	if err := database.Migrate(db); err != nil {
+		fatal("Failed to migrate database", err)
+	}
+
+	// Register database and business metrics
+	if err := metrics.Register(db); err != nil {
+		fatal("Failed to register metrics", err)
+	}
+
 	// Initialize router
 	router := initRouter()
 
@@ -55,8 +68,17 @@ func main() {
 
 func initConfig() error {
 	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: .env file not found, using environment variables")
+	envErr := godotenv.Load()
+
+	// Configure structured logging once the environment is known
+	logging.Setup()
+	if envErr != nil {
+		slog.Warn(".env file not found, using environment variables")
+	}
+
+	// Select where uploaded files are kept
+	if err := storage.Setup(); err != nil {
+		return err
 	}
 
 	// You could expand this to initialize a proper config structure
@@ -68,9 +90,13 @@ func initRouter() *gin.Engine {
 	// Set gin mode
 	gin.SetMode(getEnv("GIN_MODE", "debug"))
 
-	// Initialize Gin
-	r := gin.Default()
+	// Initialize Gin with request IDs and structured request logging
+	r := gin.New()
 	r.SetTrustedProxies([]string{"127.0.0.1"}) // Trust the local proxy
+	r.Use(middleware.RequestID(), middleware.RequestLogger(), middleware.Metrics(), middleware.Recovery())
+
+	// Metrics are served before sessions and CSRF so scrapers need no cookies
+	r.GET("/metrics", metrics.Handler(getEnv("METRICS_TOKEN", "")))
 
 	// Setup session middleware
 	store := cookie.NewStore([]byte(getEnv("SESSION_SECRET", "your-secret-key")))
@@ -90,6 +116,9 @@ func initRouter() *gin.Engine {
 }
 
 func setupRoutes(r *gin.Engine, db *gorm.DB) {
+	// Uploaded files, reached through signed URLs
+	setup.SetupFiles(r)
+
 	// Protected routes
 	protected := r.Group("/")
 	protected.Use(middleware.AuthMiddleware())
@@ -97,15 +126,15 @@ func setupRoutes(r *gin.Engine, db *gorm.DB) {
 		// Dashboard routes
 		setup.SetupDashboard(db, protected)
 
-		// Product management
-		setup.SetupProducts(db, protected)
-		setup.SetupCategories(db, protected)
+		// Admin resources: products, categories, orders, companies, users
+		setup.RegisterResources()
+		setup.SetupResources(db, protected)
 
-		// Order management
-		setup.SetupOrders(db, protected)
+		// Global search of the header across the resources
+		setup.SetupSearch(protected)
 
-		// User management
-		setup.SetupUsers(db, protected)
+		// Background jobs, e.g. large exports
+		setup.SetupJobs(protected)
 	}
 
 	// Public routes
@@ -132,9 +161,9 @@ func startServer(r *gin.Engine) {
 
 	// Start server in a goroutine
 	go func() {
-		log.Printf("Server starting on port %s", port)
+		slog.Info("Server starting", "port", port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+			fatal("Failed to start server", err)
 		}
 	}()
 
@@ -142,17 +171,17 @@ func startServer(r *gin.Engine) {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down server...")
+	slog.Info("Shutting down server...")
 
 	// The context is used to inform the server it has 5 seconds to finish
 	// the request it is currently handling
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		fatal("Server forced to shutdown", err)
 	}
 
-	log.Println("Server exited properly")
+	slog.Info("Server exited properly")
 }
 
 // Helper function to get environment variable with fallback
@@ -162,3 +191,9 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// fatal logs the error and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
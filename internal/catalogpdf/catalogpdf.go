@@ -0,0 +1,255 @@
+// Package catalogpdf lays out the printed catalogue of the shop: a cover,
+// then the products of every section with their photo, description, sizes
+// and quantity prices. The catalogue is read by the customers, so its labels
+// are in Portuguese.
+package catalogpdf
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // Registers the GIF decoder
+	"image/jpeg"
+	_ "image/png" // Registers the PNG decoder
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"belcamp/internal/domain/valueobject"
+
+	"github.com/go-pdf/fpdf"
+	_ "golang.org/x/image/webp" // Registers the WebP decoder
+)
+
+const (
+	margin      = 15.0 // Page margins, in mm
+	photoSize   = 45.0 // Side of the box the product photos are fitted in
+	maxDescLen  = 600  // Longer descriptions are cut, in characters
+	priceColumn = 20.0 // Width of a quantity break in the price table
+)
+
+// Document is the content of a catalogue
+type Document struct {
+	Title       string
+	Description string
+	Date        time.Time
+	Sections    []Section
+}
+
+// Section is a chapter of the catalogue, e.g. a category
+type Section struct {
+	Title    string
+	Products []Product
+}
+
+// Product is a product as printed in the catalogue
+type Product struct {
+	Name        string
+	Description string // Plain text or HTML, whose tags are dropped
+	Photo       []byte // JPEG, PNG, GIF or WebP; none when empty
+	Sizes       []string
+	Prices      valueobject.PriceTable
+}
+
+// Write renders the catalogue as a PDF. Photos that cannot be decoded are
+// left out rather than failing the catalogue.
+func Write(w io.Writer, doc Document) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(true, margin+5)
+	pdf.SetTitle(doc.Title, true)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pageWidth, pageHeight := pdf.GetPageSize()
+	half := (pageWidth - 2*margin) / 2
+	pdf.SetFooterFunc(func() {
+		if pdf.PageNo() == 1 {
+			return
+		}
+		pdf.SetY(-margin)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.SetTextColor(128, 128, 128)
+		pdf.CellFormat(half, 5, tr(doc.Title), "", 0, "L", false, 0, "")
+		pdf.CellFormat(half, 5, fmt.Sprint(pdf.PageNo()), "", 0, "R", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+
+	writeCover(pdf, tr, doc)
+
+	for i, section := range doc.Sections {
+		pdf.AddPage()
+		pdf.SetFont("Helvetica", "B", 18)
+		pdf.CellFormat(0, 10, tr(section.Title), "B", 1, "L", false, 0, "")
+		pdf.Ln(6)
+
+		for j, product := range section.Products {
+			// Products are kept whole on a page
+			if pdf.GetY()+photoSize+6 > pageHeight-margin-5 {
+				pdf.AddPage()
+			}
+			writeProduct(pdf, tr, product, fmt.Sprintf("photo-%d-%d", i, j))
+		}
+	}
+
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+	return pdf.Output(w)
+}
+
+// writeCover writes the first page, with the title and description
+func writeCover(pdf *fpdf.Fpdf, tr func(string) string, doc Document) {
+	pdf.AddPage()
+	pdf.SetY(90)
+	pdf.SetFont("Helvetica", "B", 28)
+	pdf.MultiCell(0, 12, tr(doc.Title), "", "C", false)
+
+	if description := plainText(doc.Description); description != "" {
+		pdf.Ln(6)
+		pdf.SetFont("Helvetica", "", 12)
+		pdf.MultiCell(0, 6, tr(description), "", "C", false)
+	}
+
+	pdf.Ln(10)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.SetTextColor(128, 128, 128)
+	pdf.CellFormat(0, 6, tr(doc.Date.Format("01/2006")), "", 1, "C", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+}
+
+// writeProduct writes a product: its photo on the left, its details on the
+// right, then a separator
+func writeProduct(pdf *fpdf.Fpdf, tr func(string) string, product Product, photoName string) {
+	pageWidth, _ := pdf.GetPageSize()
+	x, y := pdf.GetX(), pdf.GetY()
+
+	if !writePhoto(pdf, photoName, product.Photo, x, y) {
+		pdf.SetFillColor(240, 240, 240)
+		pdf.Rect(x, y, photoSize, photoSize, "F")
+	}
+
+	textX := x + photoSize + 6
+	width := pageWidth - margin - textX
+	pdf.SetXY(textX, y)
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.MultiCell(width, 6, tr(product.Name), "", "L", false)
+
+	if description := truncate(plainText(product.Description), maxDescLen); description != "" {
+		pdf.Ln(1)
+		pdf.SetX(textX)
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.MultiCell(width, 4.5, tr(description), "", "L", false)
+	}
+
+	if len(product.Sizes) > 0 {
+		pdf.Ln(2)
+		pdf.SetX(textX)
+		pdf.SetFont("Helvetica", "B", 9)
+		pdf.CellFormat(18, 5, tr("Tamanhos:"), "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.MultiCell(width-18, 5, tr(strings.Join(product.Sizes, ", ")), "", "L", false)
+	}
+
+	if len(product.Prices) > 0 {
+		pdf.Ln(2)
+		writePrices(pdf, tr, product.Prices, textX, width)
+	}
+
+	bottom := max(pdf.GetY(), y+photoSize) + 4
+	pdf.SetDrawColor(220, 220, 220)
+	pdf.Line(x, bottom, pageWidth-margin, bottom)
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetXY(x, bottom+4)
+}
+
+// writePrices writes the quantity breaks as a table of two rows, as many
+// breaks as fit in the width
+func writePrices(pdf *fpdf.Fpdf, tr func(string) string, prices valueobject.PriceTable, x, width float64) {
+	labelWidth := 24.0
+	fit := max(int((width-labelWidth)/priceColumn), 1)
+	prices = prices[:min(len(prices), fit)]
+
+	pdf.SetX(x)
+	pdf.SetFont("Helvetica", "B", 8)
+	pdf.SetFillColor(245, 245, 245)
+	pdf.CellFormat(labelWidth, 5, tr("Quantidade"), "1", 0, "L", true, 0, "")
+	pdf.SetFont("Helvetica", "", 8)
+	for _, tier := range prices {
+		pdf.CellFormat(priceColumn, 5, fmt.Sprintf("%d+", tier.Quantity), "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetX(x)
+	pdf.SetFont("Helvetica", "B", 8)
+	pdf.CellFormat(labelWidth, 5, tr("Preço unit."), "1", 0, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 8)
+	for _, tier := range prices {
+		pdf.CellFormat(priceColumn, 5, strings.Replace(tier.Price.String(), ".", ",", 1), "1", 0, "C", false, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+// writePhoto fits the photo in the photo box at x, y. It returns false when
+// there is no photo or it cannot be decoded.
+func writePhoto(pdf *fpdf.Fpdf, name string, data []byte, x, y float64) bool {
+	if len(data) == 0 {
+		return false
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return false
+	}
+
+	// The PDF gets a JPEG on white, as transparency is lost in JPEG
+	bounds := img.Bounds()
+	flat := image.NewRGBA(bounds)
+	draw.Draw(flat, bounds, &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(flat, bounds, img, bounds.Min, draw.Over)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: 85}); err != nil {
+		return false
+	}
+
+	info := pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: "JPG"}, &buf)
+	if info == nil || !pdf.Ok() {
+		return false
+	}
+
+	// Fit the longest side in the box, centered
+	width, height := photoSize, photoSize
+	if info.Width() >= info.Height() {
+		height = photoSize * info.Height() / info.Width()
+	} else {
+		width = photoSize * info.Width() / info.Height()
+	}
+	pdf.ImageOptions(name, x+(photoSize-width)/2, y+(photoSize-height)/2, width, height, false, fpdf.ImageOptions{ImageType: "JPG"}, 0, "")
+	return true
+}
+
+var (
+	tags   = regexp.MustCompile(`<[^>]*>`)
+	spaces = regexp.MustCompile(`\s+`)
+)
+
+// plainText returns the text of a description without its HTML tags
+func plainText(s string) string {
+	s = tags.ReplaceAllString(s, " ")
+	return strings.TrimSpace(spaces.ReplaceAllString(html.UnescapeString(s), " "))
+}
+
+// truncate cuts the text to n characters at most, at a word boundary
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	cut := string(runes[:n])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + "..."
+}
@@ -0,0 +1,191 @@
+// Package convert parses text, such as spreadsheet cells and form values,
+// into Go values.
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Set parses non-empty text into a value of any supported type: strings,
+// booleans, numbers, times, and JSON documents for slices, maps and structs
+func Set(v reflect.Value, text string) error {
+	if v.Kind() == reflect.Ptr {
+		elem := reflect.New(v.Type().Elem())
+		if err := Set(elem.Elem(), text); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+
+	if v.Type() == timeType {
+		t, err := ParseTime(text)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(text)
+	case reflect.Bool:
+		b, err := ParseBool(text)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := ParseInt(text)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := ParseInt(text)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%q is not a positive whole number", text)
+		}
+		v.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", text)
+		}
+		v.SetFloat(f)
+	case reflect.Slice, reflect.Map, reflect.Struct:
+		data, err := JSON(text)
+		if err != nil {
+			return err
+		}
+		// Raw JSON columns, e.g. entity.JSONField, keep the encoded document
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes(data)
+			return nil
+		}
+		if err := json.Unmarshal(data, v.Addr().Interface()); err != nil {
+			return fmt.Errorf("unexpected value %s", data)
+		}
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// JSON reads a JSON document. Besides plain JSON, "1=12.50; 10=11.90" is read
+// as an object and "S, M, L" as a list.
+func JSON(text string) ([]byte, error) {
+	if strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[") {
+		if !json.Valid([]byte(text)) {
+			return nil, fmt.Errorf("invalid JSON")
+		}
+		return []byte(text), nil
+	}
+
+	if strings.Contains(text, "=") {
+		object := map[string]string{}
+		for _, pair := range strings.Split(text, ";") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("expected key=value pairs, got %q", pair)
+			}
+			object[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+		return json.Marshal(object)
+	}
+
+	list := []string{}
+	for _, item := range strings.Split(text, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return json.Marshal(list)
+}
+
+// ParseBool reads yes/no style booleans
+func ParseBool(text string) (bool, error) {
+	switch strings.ToLower(text) {
+	case "1", "true", "yes", "y", "active":
+		return true, nil
+	case "0", "false", "no", "n", "inactive":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q is not yes or no", text)
+}
+
+// ParseInt reads whole numbers, including "3.0" as written by spreadsheets
+func ParseInt(text string) (int64, error) {
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil || f != float64(int64(f)) {
+		return 0, fmt.Errorf("%q is not a whole number", text)
+	}
+	return int64(f), nil
+}
+
+var timeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02", "02/01/2006"}
+
+// ParseTime reads dates and times, including XLSX serial numbers
+func ParseTime(text string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, nil
+		}
+	}
+	if serial, err := strconv.ParseFloat(text, 64); err == nil {
+		return excelize.ExcelDateToTime(serial, false)
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date (YYYY-MM-DD)", text)
+}
+
+// Text formats a value for display in a form input. Times use layout, nil
+// pointers are empty and JSON documents are written back as JSON.
+func Text(v reflect.Value, layout string) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(layout)
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Struct:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes())
+		}
+		if v.IsZero() {
+			return ""
+		}
+		data, err := json.Marshal(v.Interface())
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
@@ -2,19 +2,45 @@
 package database
 
 import (
+	"database/sql"
 	"fmt"
-	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
 // DB is the database instance
 var DB *gorm.DB
 
+// PoolConfig holds the connection pool settings shared by the primary and
+// the replicas
+type PoolConfig struct {
+	MaxIdleConns    int
+	MaxOpenConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// LoadPoolConfig reads the pool settings from the environment
+func LoadPoolConfig() PoolConfig {
+	return PoolConfig{
+		MaxIdleConns:    envInt("DB_MAX_IDLE_CONNS", 10),
+		MaxOpenConns:    envInt("DB_MAX_OPEN_CONNS", 100),
+		ConnMaxLifetime: envDuration("DB_CONN_MAX_LIFETIME", time.Hour),
+		ConnMaxIdleTime: envDuration("DB_CONN_MAX_IDLE_TIME", 0),
+	}
+}
+
+func (c PoolConfig) apply(db *sql.DB) {
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+}
+
 // Initialize sets up the database connection
 func Initialize() (*gorm.DB, error) {
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
@@ -25,19 +51,11 @@ func Initialize() (*gorm.DB, error) {
 		os.Getenv("DB_NAME"),
 	)
 
-	// Configure custom logger
-	newLogger := logger.New(
-		log.New(os.Stdout, "\r\n", log.LstdFlags),
-		logger.Config{
-			SlowThreshold:             time.Second,
-			LogLevel:                  logger.Info,
-			IgnoreRecordNotFoundError: true,
-			Colorful:                  true,
-		},
-	)
+	// Log queries through slog, tagging slow ones with the originating request
+	slowThreshold := time.Duration(envInt("DB_SLOW_QUERY_MS", 1000)) * time.Millisecond
 
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
-		Logger: newLogger,
+		Logger: newGormLogger(slowThreshold),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %v", err)
@@ -50,9 +68,8 @@ func Initialize() (*gorm.DB, error) {
 	}
 
 	// Set pool settings
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(100)
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	pool := LoadPoolConfig()
+	pool.apply(sqlDB)
 
 	// Test the connection
 	err = sqlDB.Ping()
@@ -60,6 +77,28 @@ func Initialize() (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %v", err)
 	}
 
+	// Route reads to the replicas, if any are configured
+	if err := registerReplicas(db, pool); err != nil {
+		return nil, err
+	}
+
 	DB = db
 	return db, nil
 }
+
+// envInt reads an integer from the environment with a fallback
+func envInt(key string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return fallback
+}
+
+// envDuration reads a duration such as "30s" or "1h" from the environment
+// with a fallback
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"belcamp/internal/logging"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// gormLogger adapts GORM logging to slog so queries carry the request ID
+// of the request that issued them
+type gormLogger struct {
+	logger        *slog.Logger
+	level         logger.LogLevel
+	slowThreshold time.Duration
+}
+
+func newGormLogger(slowThreshold time.Duration) logger.Interface {
+	return &gormLogger{
+		logger:        logging.For("db"),
+		level:         logger.Info,
+		slowThreshold: slowThreshold,
+	}
+}
+
+// LogMode returns a copy of the logger with the given level
+func (l *gormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	clone := *l
+	clone.level = level
+	return &clone
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, args ...any) {
+	if l.level >= logger.Info {
+		l.logger.InfoContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, args ...any) {
+	if l.level >= logger.Warn {
+		l.logger.WarnContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, args ...any) {
+	if l.level >= logger.Error {
+		l.logger.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+// Trace logs every executed query. Failed queries are logged as errors, slow
+// queries as warnings and everything else at debug level.
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= logger.Error:
+		sql, rows := fc()
+		l.logger.LogAttrs(ctx, slog.LevelError, "query failed",
+			slog.String("error", err.Error()),
+			slog.Duration("elapsed", elapsed),
+			slog.Int64("rows", rows),
+			slog.String("sql", sql),
+		)
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= logger.Warn:
+		sql, rows := fc()
+		l.logger.LogAttrs(ctx, slog.LevelWarn, "slow query",
+			slog.Duration("elapsed", elapsed),
+			slog.Duration("threshold", l.slowThreshold),
+			slog.Int64("rows", rows),
+			slog.String("sql", sql),
+		)
+	case l.level >= logger.Info && l.logger.Enabled(ctx, slog.LevelDebug):
+		sql, rows := fc()
+		l.logger.LogAttrs(ctx, slog.LevelDebug, "query",
+			slog.Duration("elapsed", elapsed),
+			slog.Int64("rows", rows),
+			slog.String("sql", sql),
+		)
+	}
+}
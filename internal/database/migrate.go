@@ -0,0 +1,85 @@
+package database
+
+import (
+	"belcamp/internal/domain/entity"
+
+	"gorm.io/gorm"
+)
+
+// Migrate creates or updates the tables owned by the admin panel. The shop
+// tables, such as products and users, are managed elsewhere; only the
+// columns the admin panel needs are added to them.
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&entity.SavedView{}, &entity.StockMovement{}, &entity.Shipment{}, &entity.ShipmentLine{}, &entity.SlugHistory{}, &entity.UserPermission{}); err != nil {
+		return err
+	}
+
+	// Low-stock alerts are configured per variant
+	if err := addColumns(db, &entity.ProductVariant{}, "LowStockThreshold"); err != nil {
+		return err
+	}
+
+	// Catalogs remember what their generated PDF contains
+	if !db.Migrator().HasTable(&entity.Catalog{}) {
+		if err := db.AutoMigrate(&entity.Catalog{}); err != nil {
+			return err
+		}
+	} else if err := addColumns(db, &entity.Catalog{}, "CategoryIDs", "ProductIDs", "GeneratedAt"); err != nil {
+		return err
+	}
+
+	// Slugs are generated from the names unless locked
+	for _, model := range []any{&entity.Product{}, &entity.Category{}, &entity.Catalog{}} {
+		if err := addSlugLock(db, model); err != nil {
+			return err
+		}
+	}
+	// Two records saved at once cannot take the same slug
+	if err := addUniqueSlug(db, &entity.Product{}, "products", "idx_products_slug"); err != nil {
+		return err
+	}
+	return addUniqueSlug(db, &entity.Category{}, "categories", "idx_categories_slug")
+}
+
+// addSlugLock adds the slug lock of the model. The slugs typed before they
+// were generated are locked, so they do not change on the next save.
+func addSlugLock(db *gorm.DB, model any) error {
+	if db.Migrator().HasColumn(model, "SlugLocked") {
+		return nil
+	}
+	if err := db.Migrator().AddColumn(model, "SlugLocked"); err != nil {
+		return err
+	}
+	return db.Model(model).Unscoped().Where("slug IS NOT NULL AND slug <> ''").Update("slug_locked", true).Error
+}
+
+// addUniqueSlug adds a unique index on the slugs of the table. Slugs used
+// more than once, or empty, are first replaced by the slug followed by the
+// ID of the record, and unlocked so they are generated again on the next
+// save.
+func addUniqueSlug(db *gorm.DB, model any, table, index string) error {
+	if db.Migrator().HasIndex(model, index) {
+		return nil
+	}
+	err := db.Exec(`UPDATE ` + table + ` t
+		JOIN (SELECT slug, MIN(id) AS first FROM ` + table + ` GROUP BY slug HAVING COUNT(*) > 1 OR slug = '') d
+			ON t.slug = d.slug AND (t.id <> d.first OR t.slug = '')
+		SET t.slug = CONCAT_WS('-', NULLIF(t.slug, ''), t.id), t.slug_locked = false`).Error
+	if err != nil {
+		return err
+	}
+	return db.Exec("CREATE UNIQUE INDEX " + index + " ON " + table + " (slug)").Error
+}
+
+// addColumns adds the fields of the model missing from its table
+func addColumns(db *gorm.DB, model any, fields ...string) error {
+	for _, field := range fields {
+		if db.Migrator().HasColumn(model, field) {
+			continue
+		}
+		if err := db.Migrator().AddColumn(model, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"belcamp/internal/logging"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// replicas holds the read replica pools by host
+var replicas = map[string]*sql.DB{}
+
+// Replicas returns the read replica connection pools by host
+func Replicas() map[string]*sql.DB {
+	return replicas
+}
+
+// ReplicaResolver names the resolver of the read replicas. Queries use the
+// primary unless they ask for a replica with
+// Clauses(dbresolver.Use(ReplicaResolver), dbresolver.Read), as the lists do.
+const ReplicaResolver = "read-replicas"
+
+// registerReplicas opens the replicas listed in DB_REPLICA_HOSTS for the
+// reads that ask for them. Everything else, writes and transactions
+// included, keeps using the primary and sees the latest data.
+func registerReplicas(db *gorm.DB, pool PoolConfig) error {
+	hosts := splitList(os.Getenv("DB_REPLICA_HOSTS"))
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	primary, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	policy := newHealthPolicy(primary)
+	dialectors := make([]gorm.Dialector, 0, len(hosts))
+
+	for _, host := range hosts {
+		sqlDB, err := sql.Open("mysql", replicaDSN(host))
+		if err != nil {
+			return fmt.Errorf("failed to open replica %s: %v", host, err)
+		}
+		pool.apply(sqlDB)
+
+		replicas[host] = sqlDB
+		policy.add(host, sqlDB)
+		dialectors = append(dialectors, mysql.New(mysql.Config{Conn: sqlDB}))
+	}
+
+	// The primary is listed last for the policy to fall back to. dbresolver
+	// skips the policy when there is a single replica, so it also keeps the
+	// health check in use with one replica.
+	dialectors = append(dialectors, mysql.New(mysql.Config{Conn: primary}))
+
+	if err := db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: dialectors,
+		Policy:   policy,
+	}, ReplicaResolver)); err != nil {
+		return fmt.Errorf("failed to register replicas: %v", err)
+	}
+
+	policy.check()
+	go policy.watch(envDuration("DB_REPLICA_HEALTH_INTERVAL", 10*time.Second))
+
+	return nil
+}
+
+// replicaDSN builds the DSN for a replica host, reusing the primary
+// credentials unless DB_REPLICA_USER / DB_REPLICA_PASSWORD are set
+func replicaDSN(host string) string {
+	user := os.Getenv("DB_REPLICA_USER")
+	password := os.Getenv("DB_REPLICA_PASSWORD")
+	if user == "" {
+		user = os.Getenv("DB_USER")
+		password = os.Getenv("DB_PASSWORD")
+	}
+
+	if !strings.Contains(host, ":") {
+		host += ":" + os.Getenv("DB_PORT")
+	}
+
+	return fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		user, password, host, os.Getenv("DB_NAME"))
+}
+
+// replica is a read replica and its last known health
+type replica struct {
+	host    string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// healthPolicy balances reads over the healthy replicas and falls back to
+// the primary when none of them answers
+type healthPolicy struct {
+	primary  *sql.DB
+	mu       sync.RWMutex
+	replicas map[gorm.ConnPool]*replica
+	next     atomic.Uint64
+	logger   *slog.Logger
+}
+
+func newHealthPolicy(primary *sql.DB) *healthPolicy {
+	return &healthPolicy{
+		primary:  primary,
+		replicas: map[gorm.ConnPool]*replica{},
+		logger:   logging.For("db"),
+	}
+}
+
+func (p *healthPolicy) add(host string, db *sql.DB) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.replicas[db] = &replica{host: host, db: db}
+}
+
+// Resolve picks the next healthy replica in round-robin order. pools also
+// holds the primary, which is never in the rotation.
+func (p *healthPolicy) Resolve(pools []gorm.ConnPool) gorm.ConnPool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	healthy := make([]gorm.ConnPool, 0, len(pools))
+	for _, pool := range pools {
+		if r, ok := p.replicas[pool]; ok && r.healthy.Load() {
+			healthy = append(healthy, pool)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return p.primary
+	}
+
+	return healthy[p.next.Add(1)%uint64(len(healthy))]
+}
+
+// watch checks the replicas periodically
+func (p *healthPolicy) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		p.check()
+	}
+}
+
+// check pings every replica and logs health transitions
+func (p *healthPolicy) check() {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, r := range p.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		err := r.db.PingContext(ctx)
+		cancel()
+
+		healthy := err == nil
+		if r.healthy.Swap(healthy) == healthy {
+			continue
+		}
+
+		if healthy {
+			p.logger.Info("replica is healthy", slog.String("host", r.host))
+		} else {
+			p.logger.Warn("replica is unhealthy, reads fall back to the primary when none is left",
+				slog.String("host", r.host), slog.String("error", err.Error()))
+		}
+	}
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
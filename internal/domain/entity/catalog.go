@@ -1,12 +1,120 @@
 package entity
 
-import "gorm.io/gorm"
+import (
+	"belcamp/internal/domain/valueobject"
+	"database/sql/driver"
+	"time"
 
-// Catalog model
+	"gorm.io/gorm"
+)
+
+// JSONIDs is a list of record IDs stored as a JSON array
+type JSONIDs []uint
+
+func (j *JSONIDs) Scan(value any) error {
+	return scanJSON(value, j)
+}
+
+func (j JSONIDs) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return valueJSON(j)
+}
+
+// Catalog model. The PDF is either uploaded or generated from the selected
+// categories and products.
 type Catalog struct {
 	gorm.Model
-	Name        string  `json:"name"`
-	Slug        string  `gorm:"unique" json:"slug"`
-	Description *string `json:"description,omitempty"`
-	PDFPath     string  `json:"pdf_path"`
+	Name        string     `json:"name"`
+	Slug        string     `gorm:"unique" json:"slug"`
+	SlugLocked  bool       `gorm:"default:false" json:"slug_locked"` // Keeps the slug when the name changes
+	Description *string    `json:"description,omitempty"`
+	PDFPath     string     `json:"pdf_path" form:"-"`
+	CategoryIDs JSONIDs    `gorm:"type:json" json:"category_ids,omitempty" form:"-"` // Categories included with their subcategories
+	ProductIDs  JSONIDs    `gorm:"type:json" json:"product_ids,omitempty" form:"-"`  // Products included on their own
+	GeneratedAt *time.Time `json:"generated_at,omitempty" form:"-"`                  // Set when the PDF was generated rather than uploaded
+}
+
+// PDFLabel returns how the PDF was made, as shown in the catalogs list
+func (c Catalog) PDFLabel() string {
+	switch {
+	case c.PDFPath == "":
+		return "None"
+	case c.GeneratedAt != nil:
+		return "Generated " + c.GeneratedAt.Format("2006-01-02 15:04")
+	}
+	return "Uploaded"
+}
+
+// SlugSource returns the name the slug is generated from
+func (c Catalog) SlugSource() string { return c.Name }
+
+func (c Catalog) GetSlug() string      { return c.Slug }
+func (c *Catalog) SetSlug(slug string) { c.Slug = slug }
+func (c Catalog) IsSlugLocked() bool   { return c.SlugLocked }
+func (c *Catalog) LockSlug()           { c.SlugLocked = true }
+
+func (c Catalog) GetSmartTableConfig() valueobject.SmartTableConfig {
+	return valueobject.SmartTableConfig{
+		Columns: []valueobject.SmartTableColumn{
+			{
+				Field:      "Name",
+				Label:      "Name",
+				Sortable:   true,
+				Filterable: true,
+				FilterType: "text",
+				Visible:    true,
+			},
+			{
+				Field:      "Slug",
+				Label:      "Slug",
+				Sortable:   true,
+				Filterable: true,
+				FilterType: "text",
+				Visible:    true,
+			},
+			{
+				Field:   "PDFLabel",
+				Label:   "PDF",
+				Visible: true,
+			},
+			{
+				Field:      "UpdatedAt",
+				Label:      "Updated",
+				Sortable:   true,
+				Filterable: true,
+				FilterType: "date",
+				Formatter:  "formatDate",
+				Visible:    true,
+			},
+		},
+		DefaultSort:  "Name",
+		DefaultOrder: "asc",
+		PageSizes:    []int{10, 25, 50, 100},
+	}
+}
+
+func (c Catalog) GetFormConfig() valueobject.FormConfig {
+	return valueobject.FormConfig{
+		Groups: []valueobject.FormGroup{
+			{
+				Label: "General",
+				Fields: []valueobject.FormField{
+					{Field: "Name", Label: "Name", Required: true},
+					{Field: "Slug", Label: "Slug", Help: "Used in the catalog URL and the name of its PDF. Generated from the name unless locked; typing one locks it."},
+					{Field: "SlugLocked", Label: "Lock slug", Widget: "checkbox", Help: "Keep the slug when the name changes"},
+					{Field: "Description", Label: "Description", Widget: "textarea", Help: "Printed on the cover of generated catalogs"},
+				},
+			},
+		},
+	}
+}
+
+func (c Catalog) GetSearchConfig() valueobject.SearchConfig {
+	return valueobject.SearchConfig{
+		Columns:  []string{"name", "slug"},
+		Title:    "Name",
+		Subtitle: "Slug",
+	}
 }
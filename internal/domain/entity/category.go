@@ -1,19 +1,22 @@
 package entity
 
 import (
+	"belcamp/internal/domain/valueobject"
+
 	"gorm.io/gorm"
 )
 
 type Category struct {
 	gorm.Model
 	// ID        uint           `gorm:"primaryKey" json:"id"`
-	Name     string  `json:"name"`
-	Slug     *string `json:"slug,omitempty"`
-	Icon     *string `json:"icon,omitempty"`
-	IsActive bool    `gorm:"default:true" json:"is_active"`
-	ParentID *uint   `json:"parent_id,omitempty"`
-	Order    *int16  `gorm:"default:0" json:"order,omitempty"`
-	InMenu   bool    `gorm:"default:true" json:"in_menu"`
+	Name       string  `json:"name"`
+	Slug       *string `json:"slug,omitempty"`
+	SlugLocked bool    `gorm:"default:false" json:"slug_locked"` // Keeps the slug when the name changes
+	Icon       *string `json:"icon,omitempty"`
+	IsActive   bool    `gorm:"default:true" json:"is_active"`
+	ParentID   *uint   `json:"parent_id,omitempty"`
+	Order      *int16  `gorm:"default:0" json:"order,omitempty"`
+	InMenu     bool    `gorm:"default:true" json:"in_menu"`
 	// DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 	// CreatedAt time.Time      `json:"created_at"`
 	// UpdatedAt time.Time      `json:"updated_at"`
@@ -23,3 +26,63 @@ type Category struct {
 	Children []Category `gorm:"foreignKey:ParentID" json:"children,omitempty"`
 	Products []Product  `gorm:"foreignKey:CategoryID" json:"products,omitempty"`
 }
+
+// IconClass returns the icon of the category, a tag when it has none
+func (c Category) IconClass() string {
+	if c.Icon == nil || *c.Icon == "" {
+		return "fas fa-tag"
+	}
+	return *c.Icon
+}
+
+// SlugSource returns the name the slug is generated from
+func (c Category) SlugSource() string { return c.Name }
+
+func (c Category) GetSlug() string {
+	if c.Slug == nil {
+		return ""
+	}
+	return *c.Slug
+}
+
+func (c *Category) SetSlug(slug string) { c.Slug = &slug }
+func (c Category) IsSlugLocked() bool   { return c.SlugLocked }
+func (c *Category) LockSlug()           { c.SlugLocked = true }
+
+func (c Category) GetFormConfig() valueobject.FormConfig {
+	return valueobject.FormConfig{
+		Groups: []valueobject.FormGroup{
+			{
+				Label: "General",
+				Fields: []valueobject.FormField{
+					{Field: "Name", Label: "Name", Required: true},
+					{Field: "Slug", Label: "Slug", Help: "Used in the category URL. Generated from the name unless locked; typing one locks it."},
+					{Field: "SlugLocked", Label: "Lock slug", Widget: "checkbox", Help: "Keep the slug when the name changes"},
+					{
+						Field:        "ParentID",
+						Label:        "Parent",
+						Widget:       "select",
+						OptionSource: &valueobject.OptionSource{Model: &Category{}, Label: "name"},
+					},
+					{Field: "Icon", Label: "Icon", Placeholder: "fas fa-tag"},
+				},
+			},
+			{
+				Label: "Display",
+				Fields: []valueobject.FormField{
+					{Field: "Order", Label: "Order", Widget: "number"},
+					{Field: "IsActive", Label: "Active", Widget: "checkbox"},
+					{Field: "InMenu", Label: "Show in menu", Widget: "checkbox"},
+				},
+			},
+		},
+	}
+}
+
+func (c Category) GetSearchConfig() valueobject.SearchConfig {
+	return valueobject.SearchConfig{
+		Columns:  []string{"name", "slug"},
+		Title:    "Name",
+		Subtitle: "Slug",
+	}
+}
@@ -1,6 +1,7 @@
 package entity
 
 import (
+	"belcamp/internal/domain/valueobject"
 	"time"
 
 	"gorm.io/gorm"
@@ -22,3 +23,11 @@ type Company struct {
 	Orders  []Order `gorm:"foreignKey:CompanyID" json:"orders,omitempty"`
 	Users   []User  `gorm:"foreignKey:CompanyID" json:"users,omitempty"`
 }
+
+func (c Company) GetSearchConfig() valueobject.SearchConfig {
+	return valueobject.SearchConfig{
+		Columns:  []string{"name", "nif"},
+		Title:    "Name",
+		Subtitle: "NIF",
+	}
+}
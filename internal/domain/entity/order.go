@@ -1,6 +1,15 @@
 package entity
 
-import "gorm.io/gorm"
+import (
+	"belcamp/internal/domain/valueobject"
+
+	"gorm.io/gorm"
+)
+
+// OrderNew is the status of an order that is not placed yet. Its cart is
+// priced again when the cart or the shipping changes; placed orders keep the
+// prices they were placed at.
+const OrderNew int16 = 0
 
 type Order struct {
 	gorm.Model
@@ -21,3 +30,123 @@ type Order struct {
 	User    User    `gorm:"foreignKey:UserID" json:"user,omitempty"`
 	Company Company `gorm:"foreignKey:CompanyID" json:"company,omitempty"`
 }
+
+func (o Order) GetSmartTableConfig() valueobject.SmartTableConfig {
+	return valueobject.SmartTableConfig{
+		Columns: []valueobject.SmartTableColumn{
+			{
+				Field:    "ID",
+				Label:    "Order",
+				Sortable: true,
+				Visible:  true,
+			},
+			{
+				Field:      "Status",
+				Label:      "Status",
+				Sortable:   true,
+				Filterable: true,
+				FilterType: "text",
+				Visible:    true,
+			},
+			{
+				Field:      "Total",
+				Label:      "Total",
+				Sortable:   true,
+				Filterable: true,
+				FilterType: "number",
+				Formatter:  "formatMoney",
+				Visible:    true,
+			},
+			{
+				Field:      "Withdraw",
+				Label:      "Withdraw",
+				Sortable:   true,
+				Filterable: true,
+				FilterType: "boolean",
+				Visible:    true,
+			},
+			{
+				Field:      "CreatedAt",
+				Label:      "Date",
+				Sortable:   true,
+				Filterable: true,
+				FilterType: "date",
+				Formatter:  "formatDate",
+				Visible:    true,
+			},
+		},
+		DefaultSort:  "CreatedAt",
+		DefaultOrder: "desc",
+		PageSizes:    []int{10, 25, 50, 100},
+		// Orders grow without bound, so page with cursors instead of counting
+		Pagination:       valueobject.PaginationCursor,
+		ApproximateCount: true,
+		Actions: []valueobject.SmartTableAction{
+			{
+				Label:  "View",
+				Icon:   "fas fa-eye",
+				Action: "/orders/{{.ID}}",
+				Class:  "text-blue-600 hover:text-blue-900",
+			},
+		},
+		BulkActions: []valueobject.SmartTableBulkAction{
+			{
+				Name:   "export",
+				Label:  "Export selected",
+				Export: true,
+				Class:  "text-gray-700 hover:bg-gray-100",
+			},
+		},
+	}
+}
+
+func (o Order) GetFormConfig() valueobject.FormConfig {
+	return valueobject.FormConfig{
+		Groups: []valueobject.FormGroup{
+			{
+				Label: "General",
+				Fields: []valueobject.FormField{
+					{Field: "CartID", Label: "Cart", Widget: "number", Required: true},
+					{
+						Field:        "CompanyID",
+						Label:        "Company",
+						Widget:       "select",
+						Required:     true,
+						OptionSource: &valueobject.OptionSource{Model: &Company{}, Label: "name"},
+					},
+					{
+						Field:        "UserID",
+						Label:        "User",
+						Widget:       "select",
+						Required:     true,
+						OptionSource: &valueobject.OptionSource{Model: &User{}, Label: "name"},
+					},
+					{Field: "Status", Label: "Status", Widget: "number"},
+					{Field: "Notes", Label: "Notes", Widget: "textarea"},
+				},
+			},
+			{
+				Label: "Totals",
+				Fields: []valueobject.FormField{
+					{Field: "ShippingCost", Label: "Shipping cost", Widget: "number"},
+					{Field: "Taxes", Label: "Taxes", Widget: "number"},
+					{Field: "Weight", Label: "Weight", Widget: "number"},
+					{Field: "Withdraw", Label: "Withdraw", Widget: "checkbox"},
+					{Field: "Total", Label: "Total", ReadOnly: true, Help: "The cart items at the prices the order was placed at, plus shipping"},
+				},
+			},
+		},
+	}
+}
+
+func (o Order) GetSearchConfig() valueobject.SearchConfig {
+	return valueobject.SearchConfig{
+		MatchID: true,
+		Related: []valueobject.SearchRelation{
+			{Table: "companies", Column: "name", Key: "id", Local: "company_id"},
+		},
+		Title:    "Company.Name",
+		Subtitle: "CreatedAt",
+		Preload:  []string{"Company"},
+	}
+}
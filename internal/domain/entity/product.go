@@ -5,8 +5,9 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
-	"math"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -49,6 +50,82 @@ func (j JSONField) Value() (driver.Value, error) {
 	return string(j), nil
 }
 
+// Typed JSON columns, e.g. the prices and colors of product variants
+func (j *JSONPrices) Scan(value any) error {
+	return scanJSON(value, j)
+}
+
+func (j JSONPrices) Value() (driver.Value, error) {
+	// Variants without prices of their own store NULL
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return valueJSON(j)
+}
+
+// Table parses the quantity breaks, e.g. {"1": "12.50", "10": "11.90"}, in
+// ascending order. The table is not validated.
+func (j JSONPrices) Table() (valueobject.PriceTable, error) {
+	table := make(valueobject.PriceTable, 0, len(j))
+	for quantity, price := range j {
+		q, err := strconv.Atoi(strings.TrimSpace(quantity))
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity %q", quantity)
+		}
+		amount, err := valueobject.ParseMoney(price)
+		if err != nil {
+			return nil, fmt.Errorf("invalid price for %d: %w", q, err)
+		}
+		table = append(table, valueobject.PriceTier{Quantity: q, Price: amount})
+	}
+	sort.Slice(table, func(a, b int) bool { return table[a].Quantity < table[b].Quantity })
+	return table, nil
+}
+
+// NewJSONPrices stores a price table as quantity breaks
+func NewJSONPrices(table valueobject.PriceTable) JSONPrices {
+	prices := make(JSONPrices, len(table))
+	for _, tier := range table {
+		prices[strconv.Itoa(tier.Quantity)] = tier.Price.String()
+	}
+	return prices
+}
+
+func (j *JSONColors) Scan(value any) error {
+	return scanJSON(value, j)
+}
+
+func (j JSONColors) Value() (driver.Value, error) {
+	return valueJSON(j)
+}
+
+func (j *JSONPhotos) Scan(value any) error {
+	return scanJSON(value, j)
+}
+
+func (j JSONPhotos) Value() (driver.Value, error) {
+	return valueJSON(j)
+}
+
+func scanJSON(value any, dst any) error {
+	var data JSONField
+	if err := data.Scan(value); err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, dst)
+}
+
+func valueJSON(value any) (driver.Value, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
 // Product model
 type Product struct {
 	ID               uint      `gorm:"primarykey" json:"id" form:"id"`
@@ -57,6 +134,7 @@ type Product struct {
 	Description      *string   `json:"description,omitempty" form:"description"`
 	Status           bool      `gorm:"default:true" json:"status" form:"status"`
 	Slug             string    `json:"slug" form:"slug"`
+	SlugLocked       bool      `gorm:"default:false" json:"slug_locked" form:"slug_locked"` // Keeps the slug when the name changes
 	Prices           JSONField `gorm:"type:json" json:"prices" form:"-"`
 	Measures         JSONField `gorm:"type:json" json:"measures,omitempty" form:"-"`
 	Photos           JSONField `gorm:"type:json" json:"photos,omitempty" form:"-"`
@@ -232,42 +310,36 @@ func (p Product) CategoryName() string {
 	return p.Category.Name
 }
 
-func (p Product) FullPrice() (float64, error) {
+// PriceTable returns the quantity breaks of the product, which apply to the
+// variants without prices of their own
+func (p Product) PriceTable() (valueobject.PriceTable, error) {
 	prices, err := p.GetPrices()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	return prices.Table()
+}
 
-	// Try to get the price for quantity 1
-	if priceStr, exists := prices["1"]; exists {
-		return strconv.ParseFloat(priceStr, 64)
+// FullPrice returns the unit price for a single item
+func (p Product) FullPrice() (float64, error) {
+	table, err := p.PriceTable()
+	if err != nil {
+		return 0, err
 	}
 
-	return 0, nil
+	price, _ := table.PriceFor(1)
+	return price.Float64(), nil
 }
 
+// MinimumPrice returns the lowest unit price of any quantity break
 func (p Product) MinimumPrice() (float64, error) {
-	prices, err := p.GetPrices()
+	table, err := p.PriceTable()
 	if err != nil {
 		return 0, err
 	}
 
-	minPrice := math.MaxFloat64
-	for _, priceStr := range prices {
-		price, err := strconv.ParseFloat(priceStr, 64)
-		if err != nil {
-			continue
-		}
-		if price < minPrice {
-			minPrice = price
-		}
-	}
-
-	if minPrice == math.MaxFloat64 {
-		return 0, nil
-	}
-
-	return minPrice, nil
+	price, _ := table.Min()
+	return price.Float64(), nil
 }
 
 func (p Product) InStock() bool {
@@ -312,6 +384,7 @@ func (p Product) GetSmartTableConfig() valueobject.SmartTableConfig {
 				Filterable: true,
 				FilterType: "text",
 				Visible:    true,
+				Editable:   "text",
 			},
 			{
 				Field:      "ShortDescription",
@@ -326,38 +399,49 @@ func (p Product) GetSmartTableConfig() valueobject.SmartTableConfig {
 				Label:      "Status",
 				Sortable:   true,
 				Filterable: true,
-				FilterType: "select",
+				FilterType: "boolean",
 				FilterOpts: []valueobject.FilterOption{
 					{Value: "true", Label: "Active"},
 					{Value: "false", Label: "Inactive"},
 				},
+				Visible:  true,
+				Editable: "select",
+			},
+			{
+				Field:       "CategoryName",
+				Label:       "Category",
+				Sortable:    true,
+				Filterable:  true,
+				FilterType:  "select",
+				FilterField: "CategoryID",
+				FilterSource: &valueobject.OptionSource{
+					Model:  &Category{},
+					Label:  "name",
+					Parent: "parent_id",
+				},
 				Visible: true,
 			},
 			{
-				Field:      "CategoryName",
-				Label:      "Category",
+				Field:      "CreatedAt",
+				Label:      "Date Added",
 				Sortable:   true,
 				Filterable: true,
-				FilterType: "select",
+				FilterType: "date",
+				Formatter:  "formatDate",
 				Visible:    true,
 			},
 			{
-				Field:     "CreatedAt",
-				Label:     "Date Added",
-				Sortable:  true,
-				Formatter: "formatDate",
+				Field:     "FullPrice",
+				Label:     "Price (Base)",
+				Sortable:  false,
+				Formatter: "formatMoney",
 				Visible:   true,
 			},
-			{
-				Field:    "FullPrice",
-				Label:    "Price (Base)",
-				Sortable: false,
-				Visible:  true,
-			},
 		},
 		DefaultSort:  "Name",
 		DefaultOrder: "asc",
 		PageSizes:    []int{10, 25, 50, 100},
+		Preload:      []string{"Category"},
 		Actions: []valueobject.SmartTableAction{
 			{
 				Label:  "View",
@@ -372,6 +456,89 @@ func (p Product) GetSmartTableConfig() valueobject.SmartTableConfig {
 				Class:  "text-green-600 hover:text-green-900",
 			},
 		},
+		BulkActions: []valueobject.SmartTableBulkAction{
+			{
+				Name:    "activate",
+				Label:   "Activate",
+				Updates: map[string]any{"status": true},
+				Class:   "text-green-600 hover:bg-green-50",
+			},
+			{
+				Name:    "deactivate",
+				Label:   "Deactivate",
+				Updates: map[string]any{"status": false},
+				Class:   "text-yellow-600 hover:bg-yellow-50",
+			},
+			{
+				Name:        "move",
+				Label:       "Move to category",
+				Param:       "category_id",
+				ParamLabel:  "Category",
+				ParamSource: &valueobject.OptionSource{Model: &Category{}, Label: "name"},
+				Class:       "text-blue-600 hover:bg-blue-50",
+			},
+			{
+				Name:   "export",
+				Label:  "Export selected",
+				Export: true,
+				Class:  "text-gray-700 hover:bg-gray-100",
+			},
+			{
+				Name:    "delete",
+				Label:   "Delete",
+				Delete:  true,
+				Confirm: true,
+				Message: "Are you sure you want to delete the selected products?",
+				Class:   "text-red-600 hover:bg-red-50",
+			},
+		},
+	}
+}
+
+// SlugSource returns the name the slug is generated from
+func (p Product) SlugSource() string {
+	if p.Name == nil {
+		return ""
+	}
+	return *p.Name
+}
+
+func (p Product) GetSlug() string      { return p.Slug }
+func (p *Product) SetSlug(slug string) { p.Slug = slug }
+func (p Product) IsSlugLocked() bool   { return p.SlugLocked }
+func (p *Product) LockSlug()           { p.SlugLocked = true }
+
+func (p Product) GetSearchConfig() valueobject.SearchConfig {
+	return valueobject.SearchConfig{
+		Columns: []string{"name", "slug"},
+		MatchID: true,
+		Related: []valueobject.SearchRelation{
+			{Table: "product_variants", Column: "sku", Key: "product_id"},
+		},
+		Title:    "Name",
+		Subtitle: "Slug",
+	}
+}
+
+func (p Product) GetImportConfig() valueobject.ImportConfig {
+	return valueobject.ImportConfig{
+		Key: "Slug",
+		Fields: []valueobject.ImportField{
+			{Field: "Slug", Label: "Slug", Required: true},
+			{Field: "Name", Label: "Name", Required: true},
+			{Field: "ShortDescription", Label: "Short Description"},
+			{Field: "Description", Label: "Description"},
+			{Field: "Status", Label: "Status", Help: "yes/no"},
+			{
+				Field:  "CategoryID",
+				Label:  "Category",
+				Help:   "Category name or ID",
+				Lookup: &valueobject.OptionSource{Model: &Category{}, Label: "name"},
+			},
+			{Field: "Prices", Label: "Prices", Help: "quantity=price pairs, e.g. 1=12.50; 10=11.90"},
+			{Field: "Sizes", Label: "Sizes", Help: "comma separated, e.g. S, M, L"},
+			{Field: "Measures", Label: "Measures", Help: "name=value pairs or JSON"},
+		},
 	}
 }
 
@@ -1,27 +1,91 @@
 package entity
 
 import (
+	"belcamp/internal/domain/valueobject"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 type ProductVariant struct {
-	ID              uint           `gorm:"primaryKey" json:"id"`
-	ProductID       uint           `json:"product_id"`
-	SKU             string         `gorm:"size:20" json:"sku"`
-	Prices          JSONPrices     `gorm:"type:json" json:"prices"`
-	Size            *string        `gorm:"size:20" json:"size,omitempty"`
-	Availability    int            `gorm:"default:0" json:"availability"`
-	Status          bool           `gorm:"default:true" json:"status"`
-	Colors          *JSONColors    `gorm:"type:json" json:"colors,omitempty"`
-	NextArrivalQty  *int           `json:"next_arrival_qty,omitempty"`
-	NextArrivalDate *time.Time     `json:"next_arrival_date,omitempty"`
-	DeletedAt       gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
-	CreatedAt       time.Time      `json:"created_at"`
-	UpdatedAt       time.Time      `json:"updated_at"`
+	ID                uint           `gorm:"primaryKey" json:"id"`
+	ProductID         uint           `json:"product_id"`
+	SKU               string         `gorm:"size:20" json:"sku"`
+	Prices            JSONPrices     `gorm:"type:json" json:"prices"`
+	Size              *string        `gorm:"size:20" json:"size,omitempty"`
+	Availability      int            `gorm:"default:0" json:"availability"` // Moved through the stock ledger
+	Status            bool           `gorm:"default:true" json:"status"`
+	Colors            *JSONColors    `gorm:"type:json" json:"colors,omitempty"`
+	NextArrivalQty    *int           `json:"next_arrival_qty,omitempty"`           // Computed from the pending shipment lines
+	NextArrivalDate   *time.Time     `json:"next_arrival_date,omitempty"`          // Computed from the pending shipment lines
+	LowStockThreshold int            `gorm:"default:0" json:"low_stock_threshold"` // Alert at or below this availability
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
 
 	// Relations
 	Product   Product    `gorm:"foreignKey:ProductID" json:"product,omitempty"`
 	CartItems []CartItem `gorm:"foreignKey:ProductVariantID" json:"cart_items,omitempty"`
 }
+
+// ColorName returns the name of the first color of the variant
+func (v ProductVariant) ColorName() string {
+	if v.Colors == nil || len(*v.Colors) == 0 {
+		return ""
+	}
+	name, _ := (*v.Colors)[0]["name"].(string)
+	return name
+}
+
+// ColorCode returns the code of the first color of the variant, e.g. "#000000"
+func (v ProductVariant) ColorCode() string {
+	if v.Colors == nil || len(*v.Colors) == 0 {
+		return ""
+	}
+	code, _ := (*v.Colors)[0]["code"].(string)
+	return code
+}
+
+// SetColor makes the color the only color of the variant; nil clears it
+func (v *ProductVariant) SetColor(color *Color) {
+	if color == nil {
+		v.Colors = nil
+		return
+	}
+	v.Colors = &JSONColors{{"name": color.Name, "code": color.Code}}
+}
+
+// PriceTable returns the quantity breaks of the variant; it is empty when the
+// variant is sold at the prices of its product
+func (v ProductVariant) PriceTable() (valueobject.PriceTable, error) {
+	return v.Prices.Table()
+}
+
+// IsLowStock reports whether the availability of the variant is at or below
+// its low-stock threshold
+func (v ProductVariant) IsLowStock() bool {
+	return v.Availability <= v.LowStockThreshold
+}
+
+// GetImportConfig leaves out Availability, which only changes through the
+// stock ledger, and the next arrival, which is computed from the shipments
+func (v ProductVariant) GetImportConfig() valueobject.ImportConfig {
+	return valueobject.ImportConfig{
+		Key:     "SKU",
+		ListURL: "/products",
+		Fields: []valueobject.ImportField{
+			{Field: "SKU", Label: "SKU", Required: true},
+			{
+				Field:    "ProductID",
+				Label:    "Product",
+				Required: true,
+				Help:     "Product slug or ID",
+				Lookup:   &valueobject.OptionSource{Model: &Product{}, Label: "slug"},
+			},
+			{Field: "Size", Label: "Size"},
+			{Field: "Prices", Label: "Prices", Help: "quantity=price pairs, e.g. 1=12.50; 10=11.90"},
+			{Field: "Status", Label: "Status", Help: "yes/no"},
+			{Field: "Colors", Label: "Colors", Help: "JSON list of colors"},
+		},
+	}
+}
@@ -0,0 +1,16 @@
+package entity
+
+import "time"
+
+// SavedView is a named SmartTable view of a resource saved by a user. Query
+// is the URL query of the view: filters, sort, page size and columns.
+type SavedView struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index:idx_saved_views_owner" json:"user_id"`
+	Resource  string    `gorm:"size:64;index:idx_saved_views_owner" json:"resource"`
+	Name      string    `gorm:"size:100" json:"name"`
+	Query     string    `gorm:"type:text" json:"query"`
+	IsDefault bool      `gorm:"default:false" json:"is_default"` // Applied when the list is opened without a query
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,155 @@
+package entity
+
+import (
+	"belcamp/internal/domain/valueobject"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Shipment statuses
+const (
+	ShipmentOpen     = "open"     // Nothing received yet
+	ShipmentPartial  = "partial"  // Some lines are partially received
+	ShipmentReceived = "received" // Every line is fully received
+)
+
+// Shipment is an incoming purchase from a supplier. Its pending lines set the
+// next arrival of their variants.
+type Shipment struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	Reference  string         `gorm:"size:64" json:"reference" form:"reference"`
+	Supplier   *string        `gorm:"size:255" json:"supplier,omitempty" form:"supplier"`
+	ExpectedAt *time.Time     `json:"expected_at,omitempty" form:"expected_at"`
+	Status     string         `gorm:"size:20;default:open" json:"status" form:"-"`
+	Notes      *string        `gorm:"type:text" json:"notes,omitempty" form:"notes"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+
+	// Relations
+	Lines []ShipmentLine `gorm:"foreignKey:ShipmentID" json:"lines,omitempty"`
+}
+
+// ShipmentLine is the quantity of a variant expected in a shipment.
+// ExpectedAt overrides the date of the shipment for the line.
+type ShipmentLine struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	ShipmentID       uint       `gorm:"index" json:"shipment_id"`
+	ProductVariantID uint       `gorm:"index" json:"product_variant_id"`
+	Quantity         int        `json:"quantity"`
+	Received         int        `gorm:"default:0" json:"received"`
+	ExpectedAt       *time.Time `json:"expected_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+
+	// Relations
+	Shipment       *Shipment      `gorm:"foreignKey:ShipmentID" json:"shipment,omitempty"`
+	ProductVariant ProductVariant `gorm:"foreignKey:ProductVariantID" json:"product_variant,omitempty"`
+}
+
+// Pending returns the units of the line still to be received
+func (l ShipmentLine) Pending() int {
+	return max(l.Quantity-l.Received, 0)
+}
+
+// StatusLabel returns the status as shown in the shipments list
+func (s Shipment) StatusLabel() string {
+	switch s.Status {
+	case ShipmentPartial:
+		return "Partially received"
+	case ShipmentReceived:
+		return "Received"
+	}
+	return "Open"
+}
+
+func (s Shipment) GetSmartTableConfig() valueobject.SmartTableConfig {
+	return valueobject.SmartTableConfig{
+		Columns: []valueobject.SmartTableColumn{
+			{
+				Field:      "Reference",
+				Label:      "Reference",
+				Sortable:   true,
+				Filterable: true,
+				FilterType: "text",
+				Visible:    true,
+			},
+			{
+				Field:      "Supplier",
+				Label:      "Supplier",
+				Sortable:   true,
+				Filterable: true,
+				FilterType: "text",
+				Visible:    true,
+			},
+			{
+				Field:      "ExpectedAt",
+				Label:      "Expected",
+				Sortable:   true,
+				Filterable: true,
+				FilterType: "date",
+				Formatter:  "formatDate",
+				Visible:    true,
+			},
+			{
+				Field:      "Status",
+				Label:      "Status",
+				Sortable:   true,
+				Filterable: true,
+				FilterType: "multiselect",
+				FilterOpts: []valueobject.FilterOption{
+					{Value: ShipmentOpen, Label: "Open"},
+					{Value: ShipmentPartial, Label: "Partially received"},
+					{Value: ShipmentReceived, Label: "Received"},
+				},
+				Visible: true,
+			},
+			{
+				Field:      "CreatedAt",
+				Label:      "Created",
+				Sortable:   true,
+				Filterable: true,
+				FilterType: "date",
+				Formatter:  "formatDate",
+				Visible:    true,
+			},
+		},
+		DefaultSort:  "ExpectedAt",
+		DefaultOrder: "asc",
+		PageSizes:    []int{10, 25, 50, 100},
+		BulkActions: []valueobject.SmartTableBulkAction{
+			{
+				Name:   "export",
+				Label:  "Export selected",
+				Export: true,
+				Class:  "text-gray-700 hover:bg-gray-100",
+			},
+		},
+	}
+}
+
+func (s Shipment) GetFormConfig() valueobject.FormConfig {
+	return valueobject.FormConfig{
+		Groups: []valueobject.FormGroup{
+			{
+				Label: "General",
+				Fields: []valueobject.FormField{
+					{Field: "Reference", Label: "Reference", Required: true, Placeholder: "Supplier order or invoice number"},
+					{Field: "Supplier", Label: "Supplier"},
+					{Field: "ExpectedAt", Label: "Expected", Widget: "date", Help: "Lines without a date of their own are expected on this date"},
+					{Field: "Notes", Label: "Notes", Widget: "textarea"},
+				},
+			},
+		},
+	}
+}
+
+func (s Shipment) GetSearchConfig() valueobject.SearchConfig {
+	return valueobject.SearchConfig{
+		Columns:  []string{"reference", "supplier"},
+		MatchID:  true,
+		Title:    "Reference",
+		Subtitle: "Supplier",
+	}
+}
@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+// SlugHistory is a slug an entity had before it changed, so old addresses
+// can be redirected to the entity. RecordType is the table of the entity,
+// e.g. "products".
+type SlugHistory struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	RecordType string    `gorm:"size:64;index:idx_slug_histories_lookup" json:"record_type"`
+	Slug       string    `gorm:"size:191;index:idx_slug_histories_lookup" json:"slug"`
+	RecordID   uint      `gorm:"index" json:"record_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,48 @@
+package entity
+
+import "time"
+
+// Kinds of stock movements
+const (
+	StockOpening      = "opening"      // Availability of a variant when it entered the ledger
+	StockAdjustment   = "adjustment"   // Manual change or stock count, with a reason
+	StockReservation  = "reservation"  // Reserved by an order
+	StockCancellation = "cancellation" // Returned by a cancelled order
+	StockIncoming     = "incoming"     // Received from an incoming shipment
+)
+
+// StockMovement is an entry of the stock ledger of a variant. Quantity is
+// signed and Balance is the availability of the variant after the movement,
+// so the latest movement holds the availability the ledger accounts for.
+type StockMovement struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	ProductVariantID uint      `gorm:"index" json:"product_variant_id"`
+	Kind             string    `gorm:"size:20" json:"kind"`
+	Quantity         int       `json:"quantity"`
+	Balance          int       `json:"balance"`
+	Reason           *string   `gorm:"size:255" json:"reason,omitempty"`
+	OrderID          *uint     `gorm:"index" json:"order_id,omitempty"`
+	ShipmentID       *uint     `gorm:"index" json:"shipment_id,omitempty"`
+	UserID           *uint     `json:"user_id,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+
+	// Relations
+	ProductVariant ProductVariant `gorm:"foreignKey:ProductVariantID" json:"product_variant,omitempty"`
+}
+
+// KindLabel returns the kind of the movement as shown in the stock history
+func (m StockMovement) KindLabel() string {
+	switch m.Kind {
+	case StockOpening:
+		return "Opening balance"
+	case StockAdjustment:
+		return "Adjustment"
+	case StockReservation:
+		return "Order reservation"
+	case StockCancellation:
+		return "Order cancellation"
+	case StockIncoming:
+		return "Incoming shipment"
+	}
+	return m.Kind
+}
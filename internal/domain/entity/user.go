@@ -1,6 +1,7 @@
 package entity
 
 import (
+	"belcamp/internal/domain/valueobject"
 	"time"
 
 	"gorm.io/gorm"
@@ -23,3 +24,129 @@ type User struct {
 	Company Company `gorm:"foreignKey:CompanyID" json:"company,omitempty"`
 	Orders  []Order `gorm:"foreignKey:UserID" json:"orders,omitempty"`
 }
+
+func (u User) GetSmartTableConfig() valueobject.SmartTableConfig {
+	return valueobject.SmartTableConfig{
+		Columns: []valueobject.SmartTableColumn{
+			{
+				Field:      "Name",
+				Label:      "Name",
+				Sortable:   true,
+				Filterable: true,
+				FilterType: "text",
+				Visible:    true,
+			},
+			{
+				Field:      "Email",
+				Label:      "Email",
+				Sortable:   true,
+				Filterable: true,
+				FilterType: "text",
+				Visible:    true,
+			},
+			{
+				Field:      "Status",
+				Label:      "Status",
+				Sortable:   true,
+				Filterable: true,
+				FilterType: "multiselect",
+				FilterOpts: []valueobject.FilterOption{
+					{Value: "new", Label: "New"},
+					{Value: "approved", Label: "Approved"},
+					{Value: "rejected", Label: "Rejected"},
+				},
+				Visible:  true,
+				Editable: "select",
+			},
+			{
+				Field:      "CreatedAt",
+				Label:      "Registered",
+				Sortable:   true,
+				Filterable: true,
+				FilterType: "date",
+				Formatter:  "formatDate",
+				Visible:    true,
+			},
+		},
+		DefaultSort:  "CreatedAt",
+		DefaultOrder: "desc",
+		PageSizes:    []int{10, 25, 50, 100},
+		Actions: []valueobject.SmartTableAction{
+			{
+				Label:  "Edit",
+				Icon:   "fas fa-edit",
+				Action: "/users/{{.ID}}/edit",
+				Class:  "text-green-600 hover:text-green-900",
+			},
+		},
+		BulkActions: []valueobject.SmartTableBulkAction{
+			{
+				Name:    "approve",
+				Label:   "Approve",
+				Updates: map[string]any{"status": "approved"},
+				Class:   "text-green-600 hover:bg-green-50",
+			},
+			{
+				Name:    "reject",
+				Label:   "Reject",
+				Updates: map[string]any{"status": "rejected"},
+				Confirm: true,
+				Message: "Reject the selected users?",
+				Class:   "text-yellow-600 hover:bg-yellow-50",
+			},
+			{
+				Name:   "export",
+				Label:  "Export selected",
+				Export: true,
+				Class:  "text-gray-700 hover:bg-gray-100",
+			},
+			{
+				Name:    "delete",
+				Label:   "Delete",
+				Delete:  true,
+				Confirm: true,
+				Message: "Are you sure you want to delete the selected users?",
+				Class:   "text-red-600 hover:bg-red-50",
+			},
+		},
+	}
+}
+
+func (u User) GetFormConfig() valueobject.FormConfig {
+	return valueobject.FormConfig{
+		Groups: []valueobject.FormGroup{
+			{
+				Label: "General",
+				Fields: []valueobject.FormField{
+					{Field: "Name", Label: "Name", Required: true},
+					{Field: "Email", Label: "Email", Widget: "email", Required: true},
+					{
+						Field:        "CompanyID",
+						Label:        "Company",
+						Widget:       "select",
+						OptionSource: &valueobject.OptionSource{Model: &Company{}, Label: "name"},
+					},
+					{
+						Field:    "Status",
+						Label:    "Status",
+						Widget:   "select",
+						Required: true,
+						Options: []valueobject.FilterOption{
+							{Value: "new", Label: "New"},
+							{Value: "approved", Label: "Approved"},
+							{Value: "rejected", Label: "Rejected"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (u User) GetSearchConfig() valueobject.SearchConfig {
+	return valueobject.SearchConfig{
+		Columns:  []string{"name", "email"},
+		Title:    "Name",
+		Subtitle: "Email",
+	}
+}
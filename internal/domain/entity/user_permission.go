@@ -0,0 +1,12 @@
+package entity
+
+import "time"
+
+// UserPermission grants a permission of the admin panel to a user, e.g.
+// "users.manage". The "*" permission grants them all.
+type UserPermission struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"uniqueIndex:idx_user_permissions_grant" json:"user_id"`
+	Permission string    `gorm:"size:64;uniqueIndex:idx_user_permissions_grant" json:"permission"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,10 @@
+package interfaces
+
+import (
+	"belcamp/internal/domain/valueobject"
+)
+
+// FormProvider is an interface that entities can implement to provide their own create and edit form
+type FormProvider interface {
+	GetFormConfig() valueobject.FormConfig
+}
@@ -0,0 +1,10 @@
+package interfaces
+
+import (
+	"belcamp/internal/domain/valueobject"
+)
+
+// ImportProvider is an interface that entities can implement to be imported from CSV or XLSX files
+type ImportProvider interface {
+	GetImportConfig() valueobject.ImportConfig
+}
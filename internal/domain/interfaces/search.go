@@ -0,0 +1,10 @@
+package interfaces
+
+import (
+	"belcamp/internal/domain/valueobject"
+)
+
+// SearchProvider is an interface that entities can implement to be found by the global search
+type SearchProvider interface {
+	GetSearchConfig() valueobject.SearchConfig
+}
@@ -0,0 +1,11 @@
+package interfaces
+
+// Sluggable is an interface that entities can implement to have their slug
+// generated from their name, unless locked
+type Sluggable interface {
+	SlugSource() string // Text the slug is generated from, e.g. the name
+	GetSlug() string
+	SetSlug(slug string)
+	IsSlugLocked() bool
+	LockSlug()
+}
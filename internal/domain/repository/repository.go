@@ -1,12 +1,27 @@
 // Package repository provides the repository interface for the application.
 package repository
 
-import "context"
+import (
+	"belcamp/internal/domain/valueobject"
+	"context"
+)
 
 type Repository[T any] interface {
 	Create(ctx context.Context, entity *T) error
 	FindByID(ctx context.Context, id uint) (*T, error)
 	Update(ctx context.Context, entity *T) error
+	UpdateFields(ctx context.Context, entity *T, fields []string) error
 	Delete(ctx context.Context, id uint) error
-	List(ctx context.Context, page, pageSize int, preloadFields ...string) ([]T, int64, error)
+	List(ctx context.Context, query valueobject.ListQuery, page, pageSize int) ([]T, int64, error)
+	ListCursor(ctx context.Context, query valueobject.ListQuery, page *valueobject.CursorPagination) ([]T, error)
+	FindAll(ctx context.Context, query valueobject.ListQuery) ([]T, error)
+	Count(ctx context.Context, query valueobject.ListQuery) (int64, error)
+	UpdateWhere(ctx context.Context, query valueobject.ListQuery, updates map[string]any) (int64, error)
+	DeleteWhere(ctx context.Context, query valueobject.ListQuery) (int64, error)
+	Upsert(ctx context.Context, entity *T, key string, fields []string) (bool, error)
+	EstimateCount(ctx context.Context) (int64, error)
+	Options(ctx context.Context, source valueobject.OptionSource) ([]valueobject.FilterOption, error)
+	Search(ctx context.Context, config valueobject.SearchConfig, term string, limit int) ([]T, error)
+	HasColumn(field string) bool
+	Transaction(ctx context.Context, fn func(repo Repository[T]) error) error
 }
@@ -0,0 +1,83 @@
+package valueobject
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// PaginationMode selects how a smart table pages through its rows
+type PaginationMode string
+
+const (
+	// PaginationOffset pages with OFFSET/LIMIT and a total count
+	PaginationOffset PaginationMode = "offset"
+	// PaginationCursor pages with keyset cursors and no COUNT(*)
+	PaginationCursor PaginationMode = "cursor"
+)
+
+// CursorPagination is a keyset page request and, once listed, its result.
+// After and Before are opaque tokens taken from Next and Prev of a previous
+// page; at most one of them should be set.
+type CursorPagination struct {
+	PageSize    int
+	After       string
+	Before      string
+	Approximate bool // estimate the total from table statistics
+
+	Next        string // token for the following page, empty on the last page
+	Prev        string // token for the preceding page, empty on the first page
+	ApproxTotal int64  // estimated number of rows when Approximate is set
+}
+
+// NewCursorPagination creates a cursor page request
+func NewCursorPagination(pageSize int, after, before string) *CursorPagination {
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	if after != "" {
+		before = ""
+	}
+	return &CursorPagination{
+		PageSize: pageSize,
+		After:    after,
+		Before:   before,
+	}
+}
+
+// HasNext reports whether a following page exists
+func (p *CursorPagination) HasNext() bool {
+	return p.Next != ""
+}
+
+// HasPrev reports whether a preceding page exists
+func (p *CursorPagination) HasPrev() bool {
+	return p.Prev != ""
+}
+
+// Cursor is the position of a row in a keyset ordering: the value of the
+// sort field and the primary key as a tie-breaker
+type Cursor struct {
+	Sort  string          `json:"s"`
+	Value json.RawMessage `json:"v"`
+	ID    uint            `json:"id"`
+}
+
+// EncodeCursor turns a cursor into an opaque URL-safe token
+func EncodeCursor(c Cursor) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor parses a token created by EncodeCursor
+func DecodeCursor(token string) (Cursor, error) {
+	var c Cursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(data, &c)
+	return c, err
+}
@@ -0,0 +1,25 @@
+package valueobject
+
+// FormConfig defines the create and edit form of an entity
+type FormConfig struct {
+	Groups []FormGroup // Rendered as tabs when there is more than one
+}
+
+// FormGroup is a set of fields shown together
+type FormGroup struct {
+	Label  string
+	Fields []FormField
+}
+
+// FormField defines an input of the form bound to a struct field
+type FormField struct {
+	Field        string
+	Label        string
+	Widget       string // "text" (default), "textarea", "number", "checkbox", "select", "date", "datetime", "email", "json"
+	Required     bool
+	ReadOnly     bool
+	Placeholder  string
+	Help         string
+	Options      []FilterOption
+	OptionSource *OptionSource // Loads Options from a related table, e.g. the parent category
+}
@@ -0,0 +1,23 @@
+package valueobject
+
+// ImportConfig defines how spreadsheet rows are imported into an entity
+type ImportConfig struct {
+	Key     string // Field matching rows to existing entities, e.g. "Slug"
+	Fields  []ImportField
+	ListURL string // Linked once the import is done; the resource list by default
+}
+
+// ImportField is an entity field that can be filled from a spreadsheet column
+type ImportField struct {
+	Field    string // Struct field, e.g. "Prices"
+	Label    string
+	Required bool
+	Help     string        // Expected cell format shown while mapping columns
+	Lookup   *OptionSource // Resolves cells by label in a related table, e.g. a category name to its ID
+}
+
+// ImportOutcome is the result of importing one row
+type ImportOutcome struct {
+	Created bool // A new entity was inserted rather than an existing one updated
+	Err     error
+}
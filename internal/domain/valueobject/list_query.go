@@ -0,0 +1,35 @@
+package valueobject
+
+import "strings"
+
+// ListQuery describes which entities are listed and how they are sorted.
+// Sort and the Filters keys hold struct field names, as used by
+// SmartTableColumn.Field, optionally followed by a filter operator, e.g.
+// "CreatedAt.from". IDs, when set, restricts the list to those rows.
+type ListQuery struct {
+	Sort    string
+	Order   string
+	Filters map[string]string
+	IDs     []uint
+	Preload []string
+}
+
+// Descending reports whether the list is sorted in descending order
+func (q ListQuery) Descending() bool {
+	return q.Order == "desc"
+}
+
+// Filter operators, appended to the field of a filter key. Keys without one
+// match text partially and other values exactly.
+const (
+	FilterFrom = "from" // Greater than or equal
+	FilterTo   = "to"   // Less than or equal; dates include the whole day
+	FilterIn   = "in"   // One of the comma separated values
+	FilterNull = "null" // "set" or "unset"
+)
+
+// ParseFilterKey splits a filter key into its field and operator
+func ParseFilterKey(key string) (field, operator string) {
+	field, operator, _ = strings.Cut(key, ".")
+	return field, operator
+}
@@ -0,0 +1,82 @@
+package valueobject
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in cents, so prices are added and multiplied exactly
+// instead of accumulating float64 rounding errors
+type Money int64
+
+// maxUnits is the largest whole amount ParseMoney reads, so its cents still
+// fit in a Money
+const maxUnits = (math.MaxInt64 - 99) / 100
+
+// ParseMoney reads an amount such as "12", "12.5" or "12,50". More than two
+// decimals are rejected rather than rounded.
+func ParseMoney(s string) (Money, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", ".")
+	if s == "" {
+		return 0, fmt.Errorf("empty amount")
+	}
+
+	negative := strings.HasPrefix(s, "-")
+	units, cents, _ := strings.Cut(strings.TrimPrefix(s, "-"), ".")
+	if units == "" && cents == "" {
+		return 0, fmt.Errorf("%q is not an amount", s)
+	}
+	if units == "" {
+		units = "0"
+	}
+	if len(cents) > 2 {
+		return 0, fmt.Errorf("%q has more than two decimals", s)
+	}
+	cents += strings.Repeat("0", 2-len(cents))
+
+	u, err := strconv.ParseUint(units, 10, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("%q is not an amount", s)
+	}
+	if err != nil || u > maxUnits {
+		return 0, fmt.Errorf("%q is too large", s)
+	}
+	c, err := strconv.ParseUint(cents, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an amount", s)
+	}
+
+	m := Money(u*100 + c)
+	if negative {
+		m = -m
+	}
+	return m, nil
+}
+
+// MoneyFromFloat converts a float64 column, e.g. CartItem.UnitPrice, rounding
+// to the nearest cent
+func MoneyFromFloat(f float64) Money {
+	return Money(math.Round(f * 100))
+}
+
+// Mul returns the amount multiplied by a quantity
+func (m Money) Mul(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// Float64 returns the amount for float64 columns and templates
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+// String formats the amount with two decimals, e.g. "12.50"
+func (m Money) String() string {
+	sign := ""
+	if m < 0 {
+		sign, m = "-", -m
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, m/100, m%100)
+}
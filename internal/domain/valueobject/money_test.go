@@ -0,0 +1,67 @@
+package valueobject
+
+import "testing"
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{in: "12", want: 1200},
+		{in: "12.5", want: 1250},
+		{in: "12,50", want: 1250},
+		{in: " 12,05 ", want: 1205},
+		{in: "0,99", want: 99},
+		{in: ",5", want: 50},
+		{in: "12.", want: 1200},
+		{in: "-12,5", want: -1250},
+		{in: "-0.01", want: -1},
+		{in: "92233720368547757", want: 9223372036854775700},
+		{in: "", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "+12", wantErr: true},
+		{in: "--12", wantErr: true},
+		{in: "12.345", wantErr: true},
+		{in: "12,5,0", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: "12a", wantErr: true},
+		{in: "1 000", wantErr: true},
+		{in: "92233720368547758", wantErr: true},
+		{in: "99999999999999999999999", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseMoney(%q) = %d, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMoney(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		in   Money
+		want string
+	}{
+		{in: 0, want: "0.00"},
+		{in: 5, want: "0.05"},
+		{in: 1250, want: "12.50"},
+		{in: -1205, want: "-12.05"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q, want %q", int64(tt.in), got, tt.want)
+		}
+	}
+}
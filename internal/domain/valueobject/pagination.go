@@ -18,3 +18,28 @@ func NewPagination(page, pageSize int) *Pagination {
 		PageSize: pageSize,
 	}
 }
+
+// TotalPages returns the number of pages
+func (p *Pagination) TotalPages() int {
+	if p.Total == 0 {
+		return 1
+	}
+	return int((p.Total + int64(p.PageSize) - 1) / int64(p.PageSize))
+}
+
+// From returns the position of the first row on the page
+func (p *Pagination) From() int64 {
+	if p.Total == 0 {
+		return 0
+	}
+	return int64((p.Page-1)*p.PageSize) + 1
+}
+
+// To returns the position of the last row on the page
+func (p *Pagination) To() int64 {
+	to := int64(p.Page * p.PageSize)
+	if to > p.Total {
+		return p.Total
+	}
+	return to
+}
@@ -0,0 +1,57 @@
+package valueobject
+
+import "fmt"
+
+// PriceTier is the unit price from a minimum quantity on
+type PriceTier struct {
+	Quantity int
+	Price    Money
+}
+
+// PriceTable holds the quantity breaks of a product or variant, ordered by
+// ascending quantity
+type PriceTable []PriceTier
+
+// Validate checks that the table starts at quantity 1, that quantities
+// ascend and that larger quantities never cost more per unit
+func (t PriceTable) Validate() error {
+	for i, tier := range t {
+		switch {
+		case tier.Quantity < 1:
+			return fmt.Errorf("quantity %d must be at least 1", tier.Quantity)
+		case tier.Price < 0:
+			return fmt.Errorf("the price for %d cannot be negative", tier.Quantity)
+		case i == 0 && tier.Quantity != 1:
+			return fmt.Errorf("the first price must be for quantity 1")
+		case i > 0 && tier.Quantity <= t[i-1].Quantity:
+			return fmt.Errorf("quantity %d must be greater than %d", tier.Quantity, t[i-1].Quantity)
+		case i > 0 && tier.Price > t[i-1].Price:
+			return fmt.Errorf("the price for %d (%s) is higher than for %d (%s)", tier.Quantity, tier.Price, t[i-1].Quantity, t[i-1].Price)
+		}
+	}
+	return nil
+}
+
+// PriceFor returns the unit price of the highest break not above quantity
+func (t PriceTable) PriceFor(quantity int) (Money, bool) {
+	for i := len(t) - 1; i >= 0; i-- {
+		if t[i].Quantity <= quantity {
+			return t[i].Price, true
+		}
+	}
+	return 0, false
+}
+
+// Min returns the lowest unit price of the table
+func (t PriceTable) Min() (Money, bool) {
+	if len(t) == 0 {
+		return 0, false
+	}
+	lowest := t[0].Price
+	for _, tier := range t[1:] {
+		if tier.Price < lowest {
+			lowest = tier.Price
+		}
+	}
+	return lowest, true
+}
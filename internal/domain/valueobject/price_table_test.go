@@ -0,0 +1,59 @@
+package valueobject
+
+import "testing"
+
+func TestPriceTableValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		table   PriceTable
+		wantErr bool
+	}{
+		{name: "empty", table: nil},
+		{name: "single tier", table: PriceTable{{1, 1250}}},
+		{name: "descending prices", table: PriceTable{{1, 1250}, {10, 1190}, {50, 1100}}},
+		{name: "equal prices", table: PriceTable{{1, 1250}, {10, 1250}}},
+		{name: "free tier", table: PriceTable{{1, 0}}},
+		{name: "zero quantity", table: PriceTable{{0, 1250}}, wantErr: true},
+		{name: "negative price", table: PriceTable{{1, -1}}, wantErr: true},
+		{name: "first tier above 1", table: PriceTable{{5, 1250}}, wantErr: true},
+		{name: "repeated quantity", table: PriceTable{{1, 1250}, {1, 1190}}, wantErr: true},
+		{name: "unordered quantities", table: PriceTable{{1, 1250}, {50, 1100}, {10, 1190}}, wantErr: true},
+		{name: "rising price", table: PriceTable{{1, 1250}, {10, 1300}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		err := tt.table.Validate()
+		if tt.wantErr && err == nil {
+			t.Errorf("%s: Validate() passed, want an error", tt.name)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("%s: Validate() failed: %v", tt.name, err)
+		}
+	}
+}
+
+func TestPriceTablePriceFor(t *testing.T) {
+	table := PriceTable{{1, 1250}, {10, 1190}, {50, 1100}}
+	tests := []struct {
+		quantity int
+		want     Money
+		wantOK   bool
+	}{
+		{quantity: 0, wantOK: false},
+		{quantity: 1, want: 1250, wantOK: true},
+		{quantity: 9, want: 1250, wantOK: true},
+		{quantity: 10, want: 1190, wantOK: true},
+		{quantity: 49, want: 1190, wantOK: true},
+		{quantity: 50, want: 1100, wantOK: true},
+		{quantity: 1000, want: 1100, wantOK: true},
+	}
+	for _, tt := range tests {
+		got, ok := table.PriceFor(tt.quantity)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("PriceFor(%d) = %d, %t, want %d, %t", tt.quantity, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	if _, ok := PriceTable(nil).PriceFor(1); ok {
+		t.Errorf("PriceFor(1) on an empty table found a price")
+	}
+}
@@ -0,0 +1,34 @@
+package valueobject
+
+// SearchConfig defines how an entity is found by the global search
+type SearchConfig struct {
+	Columns  []string         // Columns matched against the term, e.g. "name", "slug"
+	MatchID  bool             // Numeric terms also match the primary key
+	Related  []SearchRelation // Columns of related tables, e.g. the SKU of variants
+	Title    string           // Field shown as the result title, e.g. "Name" or "Company.Name"
+	Subtitle string           // Optional field shown below the title
+	Preload  []string         // Relations loaded for Title and Subtitle
+}
+
+// SearchRelation matches the rows whose Local column is the Key of a row of
+// Table with Column matching the term, e.g. products whose id is the
+// product_id of a variant with that SKU
+type SearchRelation struct {
+	Table  string // e.g. "product_variants"
+	Column string // e.g. "sku"
+	Key    string // Column of Table, e.g. "product_id"
+	Local  string // Column of the searched table, "id" when empty
+}
+
+// SearchResult is an entity found by the global search
+type SearchResult struct {
+	ID       uint
+	Title    string
+	Subtitle string
+}
+
+// SearchGroup holds the results found in one resource
+type SearchGroup struct {
+	Source  string // Name of the resource
+	Results []SearchResult
+}
@@ -2,25 +2,40 @@ package valueobject
 
 // SmartTableConfig defines configuration for a smart table
 type SmartTableConfig struct {
-	Columns      []SmartTableColumn
-	DefaultSort  string
-	DefaultOrder string
-	PageSizes    []int
-	Actions      []SmartTableAction
+	Columns          []SmartTableColumn
+	DefaultSort      string
+	DefaultOrder     string
+	PageSizes        []int
+	Actions          []SmartTableAction
+	BulkActions      []SmartTableBulkAction
+	Pagination       PaginationMode // PaginationOffset when empty
+	ApproximateCount bool           // Show an estimated total with cursor pagination
+	Preload          []string       // Relations loaded for computed columns, e.g. "Category"
 }
 
 // SmartTableColumn defines a column in the smart table
 type SmartTableColumn struct {
-	Field      string
-	Label      string
-	Sortable   bool
-	Filterable bool
-	FilterType string // "text", "select", "date", etc.
-	FilterOpts []FilterOption
-	Formatter  string // Optional JS function name for client-side formatting
-	Width      string // CSS width
-	Visible    bool
-	Template   string // Optional custom template for rendering
+	Field        string
+	Label        string
+	Sortable     bool
+	Filterable   bool
+	FilterType   string // "text" (default), "select", "multiselect", "boolean", "date" and "number" ranges, or "null"
+	FilterOpts   []FilterOption
+	FilterField  string        // Column filtered when Field is computed, e.g. "CategoryID" for CategoryName
+	FilterSource *OptionSource // Loads FilterOpts from a related table
+	Formatter    string        // Optional JS function name for client-side formatting
+	Width        string        // CSS width
+	Visible      bool
+	Template     string // Optional custom template for rendering
+	Editable     string // Inline edit widget: "text", "number", "checkbox" or "select" (FilterOpts); empty when read-only
+}
+
+// FilterKey returns the field the column is filtered on
+func (c SmartTableColumn) FilterKey() string {
+	if c.FilterField != "" {
+		return c.FilterField
+	}
+	return c.Field
 }
 
 // FilterOption for select filters
@@ -39,3 +54,31 @@ type SmartTableAction struct {
 	Class    string
 	ShowWhen func(entity interface{}) bool
 }
+
+// SmartTableBulkAction defines an action applied to all selected rows in a
+// single transaction
+type SmartTableBulkAction struct {
+	Name        string // Identifier posted back to the bulk endpoint
+	Label       string
+	Icon        string
+	Class       string
+	Confirm     bool
+	Message     string
+	Delete      bool           // Delete the selected rows
+	Export      bool           // Download the selected rows as CSV
+	Updates     map[string]any // Column values set on the selected rows
+	Param       string         // Column set to a value chosen by the user, e.g. "category_id"
+	ParamLabel  string
+	ParamOpts   []FilterOption
+	ParamSource *OptionSource // Loads ParamOpts from a related table
+}
+
+// OptionSource describes select options loaded from a related table
+type OptionSource struct {
+	Model any    // Model of the related table, e.g. &entity.Category{}
+	Label string // Label column, e.g. "name"
+	Value string // Value column, "id" when empty
+	// Parent column of tree tables, e.g. "parent_id"; the options are then
+	// listed depth first and labelled with their path
+	Parent string
+}
@@ -0,0 +1,94 @@
+package importer
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"belcamp/internal/convert"
+	"belcamp/internal/domain/valueobject"
+)
+
+// Mapping maps entity fields to the index of the column they are read from
+type Mapping map[string]int
+
+// Lookups holds, per field, the values of a related table by lowercased label
+type Lookups map[string]map[string]string
+
+// AutoMap maps each field to the column whose header matches its label or
+// field name, ignoring case, spaces and underscores
+func AutoMap(fields []valueobject.ImportField, header []string) Mapping {
+	mapping := Mapping{}
+	for _, field := range fields {
+		for i, title := range header {
+			if normalize(title) == normalize(field.Label) || normalize(title) == normalize(field.Field) {
+				mapping[field.Field] = i
+				break
+			}
+		}
+	}
+	return mapping
+}
+
+// NewLookup indexes select options by label and by value, so cells may hold
+// either, e.g. a category name or its ID
+func NewLookup(options []valueobject.FilterOption) map[string]string {
+	lookup := make(map[string]string, len(options)*2)
+	for _, option := range options {
+		lookup[strings.ToLower(strings.TrimSpace(option.Label))] = option.Value
+	}
+	for _, option := range options {
+		lookup[strings.ToLower(option.Value)] = option.Value
+	}
+	return lookup
+}
+
+// Decode fills the mapped fields of dst, a pointer to a struct, from the
+// cells of a row. It returns one message per invalid cell.
+func Decode(dst any, fields []valueobject.ImportField, mapping Mapping, lookups Lookups, cells []string) []string {
+	var problems []string
+
+	v := reflect.ValueOf(dst).Elem()
+	for _, field := range fields {
+		column, ok := mapping[field.Field]
+		if !ok {
+			continue
+		}
+
+		cell := ""
+		if column >= 0 && column < len(cells) {
+			cell = strings.TrimSpace(cells[column])
+		}
+
+		if cell == "" {
+			if field.Required {
+				problems = append(problems, field.Label+" is required")
+			}
+			continue
+		}
+
+		if field.Lookup != nil {
+			value, ok := lookups[field.Field][strings.ToLower(cell)]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: unknown value %q", field.Label, cell))
+				continue
+			}
+			cell = value
+		}
+
+		target := v.FieldByName(field.Field)
+		if !target.IsValid() || !target.CanSet() {
+			problems = append(problems, fmt.Sprintf("%s: no such field %s", field.Label, field.Field))
+			continue
+		}
+		if err := convert.Set(target, cell); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", field.Label, err))
+		}
+	}
+
+	return problems
+}
+
+func normalize(s string) string {
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.ToLower(strings.TrimSpace(s)))
+}
@@ -0,0 +1,117 @@
+// Package importer reads CSV and XLSX files and decodes their rows into
+// entities.
+package importer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Extensions lists the supported file extensions
+var Extensions = []string{".csv", ".xlsx"}
+
+// Sheet is the content of an imported file: the header row and the data rows
+type Sheet struct {
+	Header []string
+	Rows   [][]string
+	Lines  []int // Line of each row in the file, starting at 1
+}
+
+// ReadFile reads the first sheet of a CSV or XLSX file. Empty rows are
+// skipped and every row is padded to the width of the header.
+func ReadFile(path string) (*Sheet, error) {
+	var (
+		records [][]string
+		err     error
+	)
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		records, err = readCSV(path)
+	case ".xlsx":
+		records, err = readXLSX(path)
+	default:
+		return nil, fmt.Errorf("unsupported file type %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sheet := &Sheet{}
+	for i, record := range records {
+		if isEmpty(record) {
+			continue
+		}
+		if sheet.Header == nil {
+			sheet.Header = trimAll(record)
+			continue
+		}
+		if len(record) < len(sheet.Header) {
+			record = append(record, make([]string, len(sheet.Header)-len(record))...)
+		}
+		sheet.Rows = append(sheet.Rows, record)
+		sheet.Lines = append(sheet.Lines, i+1)
+	}
+
+	if len(sheet.Header) == 0 {
+		return nil, fmt.Errorf("the file has no header row")
+	}
+	return sheet, nil
+}
+
+func readCSV(path string) ([][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	records, err := r.ReadAll()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	// Spreadsheet programs often start UTF-8 CSV files with a byte order mark
+	if len(records) > 0 && len(records[0]) > 0 {
+		records[0][0] = strings.TrimPrefix(records[0][0], "\ufeff")
+	}
+	return records, nil
+}
+
+func readXLSX(path string) ([][]string, error) {
+	file, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// Raw values keep dates as serial numbers instead of locale formatted text
+	return file.GetRows(file.GetSheetName(0), excelize.Options{RawCellValue: true})
+}
+
+func isEmpty(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func trimAll(record []string) []string {
+	trimmed := make([]string, len(record))
+	for i, cell := range record {
+		trimmed[i] = strings.TrimSpace(cell)
+	}
+	return trimmed
+}
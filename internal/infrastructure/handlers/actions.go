@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Action names a route of the CRUD handler that can be replaced or decorated
+type Action string
+
+const (
+	ActionList   Action = "list"   // GET /path
+	ActionShow   Action = "show"   // GET /path/:id
+	ActionEdit   Action = "edit"   // GET /path/:id/edit
+	ActionNew    Action = "new"    // GET /path/new
+	ActionCreate Action = "create" // POST /path
+	ActionUpdate Action = "update" // POST and PUT /path/:id
+	ActionDelete Action = "delete" // DELETE /path/:id
+)
+
+// Decorator wraps an action, calling next to run the action it decorates
+type Decorator func(next gin.HandlerFunc) gin.HandlerFunc
+
+// Override replaces an action. Overrides must be set before the routes are
+// registered.
+func (h *CRUDHandler[T]) Override(action Action, handler gin.HandlerFunc) {
+	if h.actions == nil {
+		h.actions = map[Action]gin.HandlerFunc{}
+	}
+	h.actions[action] = handler
+}
+
+// Decorate wraps the current handler of an action, e.g. to add checks before
+// the default behaviour runs
+func (h *CRUDHandler[T]) Decorate(action Action, decorator Decorator) {
+	h.Override(action, decorator(h.Action(action)))
+}
+
+// Action returns the handler of an action: its override if any, otherwise
+// the default of the CRUD handler
+func (h *CRUDHandler[T]) Action(action Action) gin.HandlerFunc {
+	if handler, ok := h.actions[action]; ok {
+		return handler
+	}
+
+	switch action {
+	case ActionList:
+		return h.SmartTableList
+	case ActionShow:
+		return h.Show
+	case ActionEdit:
+		return h.Get
+	case ActionNew:
+		return h.New
+	case ActionCreate:
+		return h.Create
+	case ActionUpdate:
+		return h.Update
+	case ActionDelete:
+		return h.Delete
+	}
+	panic("handlers: unknown action " + string(action))
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strings"
 
+	"belcamp/internal/metrics"
 	"belcamp/internal/service"
 
 	"github.com/gin-contrib/sessions"
@@ -50,6 +51,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	// Get user by email
 	user, err := h.authService.GetUserByEmail(email)
 	if err != nil {
+		metrics.RecordLogin(false)
 		h.Render(c, "auth.login", gin.H{
 			"error": "Invalid credentials",
 		}, "")
@@ -57,6 +59,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	if !service.CheckPassword(form.Password, user.Password) {
+		metrics.RecordLogin(false)
 		h.Render(c, "auth.login", gin.H{
 			"title": "Login",
 			"error": "Invalid credentials",
@@ -74,6 +77,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	metrics.RecordLogin(true)
 	c.Redirect(http.StatusFound, "/")
 }
 
@@ -2,6 +2,7 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
 
 	"belcamp/internal/utils"
@@ -21,6 +22,12 @@ type MenuItem struct {
 
 // Render renders a template with the common template data
 func (h *BaseHandler) Render(c *gin.Context, templateName string, data gin.H, partial string) {
+	h.RenderStatus(c, http.StatusOK, templateName, data, partial)
+}
+
+// RenderStatus renders a template with the common template data and the
+// status, e.g. 422 for a form with errors
+func (h *BaseHandler) RenderStatus(c *gin.Context, status int, templateName string, data gin.H, partial string) {
 
 	// Get base template data
 	templateData := utils.NewTemplateData(c)
@@ -34,11 +41,11 @@ func (h *BaseHandler) Render(c *gin.Context, templateName string, data gin.H, pa
 	}
 
 	if c.GetHeader("HX-Request") == "true" && partial != "" {
-		c.HTML(http.StatusOK, partial, data)
+		c.HTML(status, partial, data)
 		return
 	}
 
-	c.HTML(http.StatusOK, templateName, data)
+	c.HTML(status, templateName, data)
 }
 
 // RenderError renders an error page
@@ -58,3 +65,17 @@ func (h *BaseHandler) Redirect(c *gin.Context, path string) {
 
 	c.Redirect(http.StatusFound, path)
 }
+
+// Toast shows a toast message through the showToast event of layouts.footer.
+// Extra events, e.g. "smartTableRefresh", are triggered alongside it.
+func (h *BaseHandler) Toast(c *gin.Context, message, kind string, events ...string) {
+	triggers := map[string]any{
+		"showToast": gin.H{"message": message, "type": kind},
+	}
+	for _, event := range events {
+		triggers[event] = true
+	}
+
+	data, _ := json.Marshal(triggers)
+	c.Header("HX-Trigger", string(data))
+}
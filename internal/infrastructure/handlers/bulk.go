@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"belcamp/internal/domain/valueobject"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Bulk applies a bulk action declared in the smart table config to the
+// selected rows, or to every row matching the filters when "all" is set
+func (h *CRUDHandler[T]) Bulk(c *gin.Context) {
+	config := h.tableConfig()
+
+	var action *valueobject.SmartTableBulkAction
+	for i := range config.BulkActions {
+		if config.BulkActions[i].Name == c.PostForm("action") {
+			action = &config.BulkActions[i]
+			break
+		}
+	}
+	if action == nil {
+		h.bulkError(c, http.StatusBadRequest, "Unknown bulk action")
+		return
+	}
+
+	selection := valueobject.ListQuery{
+		Sort:    c.DefaultQuery("sort", config.DefaultSort),
+		Order:   c.DefaultQuery("order", config.DefaultOrder),
+		Filters: filterParams(c.Request.PostForm),
+		Preload: config.Preload,
+	}
+	if c.PostForm("all") != "true" {
+		selection.IDs = []uint{}
+		for _, raw := range c.PostFormArray("ids") {
+			if id, err := strconv.ParseUint(raw, 10, 32); err == nil {
+				selection.IDs = append(selection.IDs, uint(id))
+			}
+		}
+	}
+
+	if action.Export {
+		h.exportSelection(c, config, selection)
+		return
+	}
+
+	affected, err := h.service.Bulk(c.Request.Context(), *action, selection, c.PostFormMap("param")[action.Name])
+	if err != nil {
+		h.bulkError(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	h.Toast(c, fmt.Sprintf("%s: %d row(s) updated", action.Label, affected), "success", "smartTableRefresh")
+	c.Status(http.StatusNoContent)
+}
+
+// bulkError reports a failed bulk action as a toast for HTMX requests
+func (h *CRUDHandler[T]) bulkError(c *gin.Context, status int, message string) {
+	if c.GetHeader("HX-Request") == "true" {
+		// HTMX ignores error responses, so report through a toast instead
+		h.Toast(c, message, "error")
+		c.Status(http.StatusNoContent)
+		return
+	}
+	h.RenderError(c, status, message)
+}
+
+// exportSelection downloads the selected rows as CSV using the visible columns
+func (h *CRUDHandler[T]) exportSelection(c *gin.Context, config valueobject.SmartTableConfig, selection valueobject.ListQuery) {
+	if selection.IDs != nil && len(selection.IDs) == 0 {
+		h.bulkError(c, http.StatusBadRequest, "No rows selected")
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.csv", h.tmpl, time.Now().Format("20060102-150405"))
+	setDownloadHeaders(c, "csv", filename)
+
+	if err := h.writeExport(c.Request.Context(), c.Writer, "csv", visibleColumns(config), selection, nil); err != nil {
+		c.Error(err)
+	}
+}
+
+// resolveOptions loads the options of filters and bulk action parameters
+// backed by a related table, and gives boolean filters their Yes/No options
+func (h *CRUDHandler[T]) resolveOptions(c *gin.Context, config *valueobject.SmartTableConfig) error {
+	for i := range config.Columns {
+		column := &config.Columns[i]
+		if column.FilterSource != nil {
+			options, err := h.service.Options(c.Request.Context(), *column.FilterSource)
+			if err != nil {
+				return err
+			}
+			column.FilterOpts = options
+		} else if column.FilterType == "boolean" && len(column.FilterOpts) == 0 {
+			column.FilterOpts = []valueobject.FilterOption{{Value: "true", Label: "Yes"}, {Value: "false", Label: "No"}}
+		}
+	}
+
+	for i := range config.BulkActions {
+		action := &config.BulkActions[i]
+		if action.ParamSource == nil {
+			continue
+		}
+
+		options, err := h.service.Options(c.Request.Context(), *action.ParamSource)
+		if err != nil {
+			return err
+		}
+		action.ParamOpts = options
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"belcamp/internal/domain/entity"
+	"belcamp/internal/jobs"
+	"belcamp/internal/logging"
+	"belcamp/internal/service"
+	"belcamp/internal/slug"
+	"belcamp/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCatalogSize is the largest catalog PDF accepted, in bytes
+const maxCatalogSize = 50 << 20
+
+// catalogOption is a category or product that can be selected for a catalog
+type catalogOption struct {
+	ID       uint
+	Label    string
+	Selected bool
+}
+
+// CatalogHandler extends the generic CRUD handler with the PDF of the
+// catalogs, uploaded or generated in the background
+type CatalogHandler struct {
+	*CRUDHandler[entity.Catalog]
+	catalogs service.CatalogService
+}
+
+// NewCatalogHandler creates a handler storing the PDF of the catalogs
+func NewCatalogHandler(svc *service.CRUDService[entity.Catalog], catalogs service.CatalogService, tmpl string) *CatalogHandler {
+	h := &CatalogHandler{
+		CRUDHandler: NewCRUDHandler(svc, tmpl),
+		catalogs:    catalogs,
+	}
+
+	// Catalogs are saved with their PDF and selection, and deleted with
+	// their PDF
+	h.Override(ActionCreate, h.Create)
+	h.Override(ActionUpdate, h.Update)
+	h.Override(ActionDelete, h.Delete)
+	h.ExtendForm(h.selectionData)
+
+	return h
+}
+
+// RegisterCatalogRoutes registers the generation and the download of the
+// catalog PDFs
+func (h *CatalogHandler) RegisterCatalogRoutes(r *gin.RouterGroup, path string) {
+	group := r.Group(path + "/:id")
+	group.POST("/generate", h.Generate)
+	group.GET("/pdf", h.PDF)
+}
+
+// Create creates a catalog
+func (h *CatalogHandler) Create(c *gin.Context) {
+	h.save(c, &entity.Catalog{}, true)
+}
+
+// Update updates a catalog and replaces or removes its PDF
+func (h *CatalogHandler) Update(c *gin.Context) {
+	catalog, err := h.service.Get(c.Request.Context(), convertToUint(c.Param("id")))
+	if err != nil {
+		h.RenderError(c, http.StatusNotFound, "Catalog not found")
+		return
+	}
+
+	h.save(c, catalog, false)
+}
+
+// Delete deletes a catalog and its PDF
+func (h *CatalogHandler) Delete(c *gin.Context) {
+	if err := h.catalogs.Delete(c.Request.Context(), convertToUint(c.Param("id"))); err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// The SmartTable removes the row itself
+	if c.GetHeader("HX-Request") == "true" {
+		h.Toast(c, "Deleted", "success")
+		c.Status(http.StatusOK)
+		return
+	}
+	h.Redirect(c, h.listPath(c))
+}
+
+// Generate starts laying out the PDF of the catalog in the background and
+// opens the status page of the job
+func (h *CatalogHandler) Generate(c *gin.Context) {
+	id := convertToUint(c.Param("id"))
+	if _, err := h.service.Get(c.Request.Context(), id); err != nil {
+		h.RenderError(c, http.StatusNotFound, "Catalog not found")
+		return
+	}
+
+	// The request path is <list>/<id>/generate
+	editURL := strings.TrimSuffix(c.Request.URL.Path, "/generate") + "/edit"
+	job := jobs.Default.Start(c.Request.Context(), "catalog", func(ctx context.Context, job *jobs.Job) error {
+		job.SetURL(editURL)
+		return h.catalogs.Generate(ctx, id, job.SetProgress)
+	})
+	h.Redirect(c, "/jobs/"+job.ID)
+}
+
+// PDF opens the PDF of the catalog from the storage
+func (h *CatalogHandler) PDF(c *gin.Context) {
+	catalog, err := h.service.Get(c.Request.Context(), convertToUint(c.Param("id")))
+	if err != nil || catalog.PDFPath == "" {
+		h.RenderError(c, http.StatusNotFound, "The catalog has no PDF")
+		return
+	}
+
+	url := storage.URL(catalog.PDFPath)
+	if url == "" {
+		h.RenderError(c, http.StatusInternalServerError, "The PDF cannot be opened")
+		return
+	}
+	c.Redirect(http.StatusFound, url)
+}
+
+// save binds the posted form, selection and PDF upload, then stores the
+// catalog with its slug. New catalogs open their page, where their PDF can
+// be generated.
+func (h *CatalogHandler) save(c *gin.Context, catalog *entity.Catalog, isNew bool) {
+	ctx := c.Request.Context()
+
+	catalog.CategoryIDs = postedIDs(c, "category_ids")
+	catalog.ProductIDs = postedIDs(c, "product_ids")
+	if errs := h.bindForm(c, catalog); len(errs) > 0 {
+		h.renderForm(c, catalog, isNew, errs)
+		return
+	}
+	previous := catalog.PDFPath
+	if err := h.bindPDF(c, catalog); err != nil {
+		h.renderForm(c, catalog, isNew, map[string]string{"": fmt.Sprintf("Error uploading the PDF: %v", err)})
+		return
+	}
+
+	replaced, err := h.catalogs.Save(ctx, catalog, isNew, catalog.PDFPath != previous)
+	if err != nil {
+		// The new PDF is not kept when the catalog cannot be saved
+		if catalog.PDFPath != previous && catalog.PDFPath != "" {
+			h.catalogs.RemoveFile(ctx, catalog.PDFPath)
+		}
+		catalog.PDFPath = previous
+		h.renderForm(c, catalog, isNew, map[string]string{"": err.Error()})
+		return
+	}
+	if replaced != "" {
+		if err := h.catalogs.RemoveFile(ctx, replaced); err != nil {
+			logging.For("catalogs").ErrorContext(ctx, "removing replaced catalog PDF failed", "file", replaced, "error", err)
+		}
+	}
+
+	if isNew {
+		h.Redirect(c, fmt.Sprintf("%s/%d/edit", h.listPath(c), catalog.ID))
+		return
+	}
+	h.Redirect(c, h.listPath(c))
+}
+
+// bindPDF stores an uploaded PDF on the catalog, or removes its PDF when
+// asked. Uploaded PDFs replace generated ones.
+func (h *CatalogHandler) bindPDF(c *gin.Context, catalog *entity.Catalog) error {
+	if c.PostForm("remove_pdf") == "true" {
+		catalog.PDFPath = ""
+		catalog.GeneratedAt = nil
+	}
+
+	file, err := c.FormFile("pdf")
+	if err != nil {
+		return nil // No upload
+	}
+	if file.Size > maxCatalogSize {
+		return fmt.Errorf("file size exceeds %dMB limit", maxCatalogSize>>20)
+	}
+	if strings.ToLower(filepath.Ext(file.Filename)) != ".pdf" {
+		return fmt.Errorf("invalid file type, only .pdf is allowed")
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// The content must be a PDF whatever the name of the file
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(f, header); err != nil || string(header) != "%PDF-" {
+		return fmt.Errorf("the file is not a PDF")
+	}
+
+	// The slug of a new catalog is only set once it is saved
+	name := catalog.Slug
+	if name == "" {
+		name = slug.Make(catalog.Name)
+	}
+	key, err := h.catalogs.Upload(c.Request.Context(), name, io.MultiReader(bytes.NewReader(header), f), file.Size)
+	if err != nil {
+		return err
+	}
+	catalog.PDFPath = key
+	catalog.GeneratedAt = nil
+	return nil
+}
+
+// selectionData adds the categories and products that can be selected, and
+// the PDF of the catalog, to the form
+func (h *CatalogHandler) selectionData(c *gin.Context, catalog *entity.Catalog, data gin.H) error {
+	categories, products, err := h.catalogs.Selectable(c.Request.Context())
+	if err != nil {
+		return err
+	}
+
+	selectedCategories := idSet(catalog.CategoryIDs)
+	tree := service.NewCategoryTree(categories, nil)
+	categoryOptions := make([]catalogOption, len(categories))
+	for i, category := range categories {
+		categoryOptions[i] = catalogOption{ID: category.ID, Label: tree.Path(category.ID), Selected: selectedCategories[category.ID]}
+	}
+
+	selectedProducts := idSet(catalog.ProductIDs)
+	productOptions := make([]catalogOption, len(products))
+	for i, product := range products {
+		label := fmt.Sprintf("#%d", product.ID)
+		if product.Name != nil {
+			label = *product.Name
+		}
+		if product.CategoryID != nil {
+			if node := tree.Node(*product.CategoryID); node != nil {
+				label += " (" + node.Category.Name + ")"
+			}
+		}
+		productOptions[i] = catalogOption{ID: product.ID, Label: label, Selected: selectedProducts[product.ID]}
+	}
+
+	data["catalog"] = gin.H{
+		"categories": categoryOptions,
+		"products":   productOptions,
+		"url":        fmt.Sprintf("%s/%d", data["listUrl"], catalog.ID),
+		"maxSize":    strconv.Itoa(maxCatalogSize >> 20),
+	}
+	return nil
+}
+
+// postedIDs returns the IDs posted in the input, without duplicates
+func postedIDs(c *gin.Context, input string) entity.JSONIDs {
+	ids := entity.JSONIDs{}
+	seen := map[uint]bool{}
+	for _, raw := range c.PostFormArray(input) {
+		if id := convertToUint(raw); id != 0 && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// idSet returns the IDs as a set
+func idSet(ids []uint) map[uint]bool {
+	set := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"belcamp/internal/domain/entity"
+	"belcamp/internal/infrastructure/errors"
+	"belcamp/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// treeRefresh is the event reloading the category tree after a change made
+// in the modal
+const treeRefresh = "categoryTreeRefresh"
+
+// CategoryHandler extends the generic CRUD handler with the category tree,
+// where categories are reordered and moved by drag and drop
+type CategoryHandler struct {
+	*CRUDHandler[entity.Category]
+	categories service.CategoryService
+}
+
+// NewCategoryHandler creates a handler showing the categories as a tree
+func NewCategoryHandler(svc *service.CRUDService[entity.Category], categories service.CategoryService, tmpl string) *CategoryHandler {
+	h := &CategoryHandler{
+		CRUDHandler: NewCRUDHandler(svc, tmpl),
+		categories:  categories,
+	}
+
+	// The list is the tree, saving checks the parent, and deleting moves the
+	// products and subcategories
+	h.Override(ActionList, h.Tree)
+	h.Override(ActionCreate, h.Create)
+	h.Override(ActionUpdate, h.Update)
+	h.Override(ActionDelete, h.Delete)
+	h.ExtendForm(h.parentData)
+
+	return h
+}
+
+// RegisterCategoryRoutes registers moving and deleting categories from the
+// tree
+func (h *CategoryHandler) RegisterCategoryRoutes(r *gin.RouterGroup, path string) {
+	group := r.Group(path + "/:id")
+	group.POST("/move", h.Move)
+	group.GET("/delete", h.ConfirmDelete)
+}
+
+// Tree renders the categories as a tree with their number of products
+func (h *CategoryHandler) Tree(c *gin.Context) {
+	tree, err := h.categories.Tree(c.Request.Context())
+	if err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.Render(c, h.tmpl+".index", gin.H{
+		"title":   "Categories",
+		"tree":    tree,
+		"baseUrl": h.listPath(c),
+	}, h.tmpl+".tree")
+}
+
+// Create creates a category
+func (h *CategoryHandler) Create(c *gin.Context) {
+	h.save(c, &entity.Category{}, true)
+}
+
+// Update updates a category
+func (h *CategoryHandler) Update(c *gin.Context) {
+	category, err := h.service.Get(c.Request.Context(), convertToUint(c.Param("id")))
+	if err != nil {
+		h.RenderError(c, http.StatusNotFound, "Category not found")
+		return
+	}
+
+	h.save(c, category, false)
+}
+
+// Move places the category under the posted parent_id, at the root when
+// empty, at the posted position among its siblings. The tree is rendered
+// again, also when the move is refused, so the page matches the database.
+func (h *CategoryHandler) Move(c *gin.Context) {
+	var parentID *uint
+	if id := convertToUint(c.PostForm("parent_id")); id != 0 {
+		parentID = &id
+	}
+	position, _ := strconv.Atoi(c.PostForm("position"))
+
+	if err := h.categories.Move(c.Request.Context(), convertToUint(c.Param("id")), parentID, position); err != nil {
+		h.Toast(c, err.Error(), "error")
+	} else {
+		h.Toast(c, "Category moved", "success")
+	}
+
+	tree, err := h.categories.Tree(c.Request.Context())
+	if err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.HTML(http.StatusOK, h.tmpl+".tree", gin.H{
+		"tree":    tree,
+		"baseUrl": treePath(c, "/move"),
+	})
+}
+
+// ConfirmDelete renders the modal asking where the products of the category
+// go before deleting it
+func (h *CategoryHandler) ConfirmDelete(c *gin.Context) {
+	tree, err := h.categories.Tree(c.Request.Context())
+	if err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	node := tree.Node(convertToUint(c.Param("id")))
+	if node == nil {
+		h.RenderError(c, http.StatusNotFound, "Category not found")
+		return
+	}
+
+	// Products can go to any other category, subcategories included as they
+	// are kept
+	var targets []*service.CategoryNode
+	for _, other := range tree.Nodes() {
+		if other != node {
+			targets = append(targets, other)
+		}
+	}
+
+	c.HTML(http.StatusOK, h.tmpl+".delete", gin.H{
+		"node":    node,
+		"targets": targets,
+		"baseUrl": treePath(c, "/delete"),
+	})
+}
+
+// Delete deletes a category, moving its products to the move_to category
+// and its subcategories up to its parent
+func (h *CategoryHandler) Delete(c *gin.Context) {
+	// hx-delete sends its parameters in the query string
+	var moveTo *uint
+	if id := convertToUint(c.Query("move_to")); id != 0 {
+		moveTo = &id
+	}
+
+	if err := h.categories.Delete(c.Request.Context(), convertToUint(c.Param("id")), moveTo); err != nil {
+		if c.GetHeader("HX-Request") == "true" {
+			h.Toast(c, err.Error(), "error")
+			c.Status(http.StatusNoContent)
+			return
+		}
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// The modal is emptied and the tree reloaded
+	if c.GetHeader("HX-Request") == "true" {
+		h.Toast(c, "Deleted", "success", treeRefresh)
+		c.Status(http.StatusOK)
+		return
+	}
+	h.Redirect(c, h.listPath(c))
+}
+
+// save binds the posted form and stores the category, with its slug, once
+// its parent is checked. Saves from the modal close it and reload the
+// tree.
+func (h *CategoryHandler) save(c *gin.Context, category *entity.Category, isNew bool) {
+	ctx := c.Request.Context()
+
+	if errs := h.bindForm(c, category); len(errs) > 0 {
+		h.renderForm(c, category, isNew, errs)
+		return
+	}
+	if err := h.categories.Save(ctx, category, isNew); err != nil {
+		field := ""
+		if domainErr, ok := err.(*errors.DomainError); ok && domainErr.Code == service.InvalidParent {
+			field = "ParentID"
+		}
+		h.renderForm(c, category, isNew, map[string]string{field: err.Error()})
+		return
+	}
+
+	if isModal(c) {
+		message := "Saved"
+		if isNew {
+			message = "Created"
+		}
+		h.Toast(c, message, "success", treeRefresh)
+		c.Status(http.StatusOK)
+		return
+	}
+	h.Redirect(c, h.listPath(c))
+}
+
+// parentData labels the parent options with their path and leaves out the
+// subcategories of the category, which cannot become its parent. The title
+// shows the path of the category.
+func (h *CategoryHandler) parentData(c *gin.Context, category *entity.Category, data gin.H) error {
+	tree, err := h.categories.Tree(c.Request.Context())
+	if err != nil {
+		return err
+	}
+
+	node := tree.Node(category.ID)
+	if node != nil {
+		data["title"] = "Edit category: " + node.Path()
+	}
+
+	groups, _ := data["groups"].([]formGroup)
+	for _, group := range groups {
+		for i := range group.Fields {
+			field := &group.Fields[i]
+			if field.Field != "ParentID" {
+				continue
+			}
+
+			options := field.Options[:0]
+			for _, option := range field.Options {
+				id := convertToUint(option.Value)
+				if node != nil && node.Contains(id) {
+					continue
+				}
+				if path := tree.Path(id); path != "" {
+					option.Label = path
+				}
+				options = append(options, option)
+			}
+			sort.SliceStable(options, func(i, j int) bool { return options[i].Label < options[j].Label })
+			field.Options = options
+		}
+	}
+	return nil
+}
+
+// treePath returns the path of the tree from a route of a category, e.g.
+// /categories from /categories/4/move
+func treePath(c *gin.Context, route string) string {
+	path := strings.TrimSuffix(c.Request.URL.Path, route)
+	return strings.TrimSuffix(path, "/"+c.Param("id"))
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"belcamp/internal/convert"
+	"belcamp/internal/domain/valueobject"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterCellRoutes registers the inline editing of the editable SmartTable
+// columns
+func (h *CRUDHandler[T]) RegisterCellRoutes(r *gin.RouterGroup, path string) {
+	group := r.Group(path + "/:id/cells/:field")
+	group.GET("", h.EditCell)
+	group.PATCH("", h.UpdateCell)
+}
+
+// EditCell renders the input of an editable cell, or the cell itself when
+// editing is cancelled
+func (h *CRUDHandler[T]) EditCell(c *gin.Context) {
+	column, id, ok := h.editableCell(c)
+	if !ok {
+		return
+	}
+
+	entity, err := h.service.Get(c.Request.Context(), id)
+	if err != nil {
+		h.RenderError(c, http.StatusNotFound, "Entity not found")
+		return
+	}
+
+	if c.Query("cancel") != "" {
+		h.renderCell(c, "table.cell", entity, column, "")
+		return
+	}
+	h.renderCell(c, "table.cell.edit", entity, column, "")
+}
+
+// UpdateCell saves the single field posted by an editable cell and renders
+// the updated cell, or the input again with the error
+func (h *CRUDHandler[T]) UpdateCell(c *gin.Context) {
+	column, id, ok := h.editableCell(c)
+	if !ok {
+		return
+	}
+
+	value := strings.TrimSpace(c.PostForm("value"))
+	if column.Editable == "checkbox" {
+		// Unchecked boxes are not posted at all
+		value = strconv.FormatBool(value == "true")
+	}
+
+	entity, err := h.service.UpdateField(c.Request.Context(), id, column.Field, value)
+	if err != nil {
+		current, getErr := h.service.Get(c.Request.Context(), id)
+		if getErr != nil {
+			h.RenderError(c, http.StatusNotFound, "Entity not found")
+			return
+		}
+		h.renderCell(c, "table.cell.edit", current, column, err.Error())
+		return
+	}
+
+	h.Toast(c, column.Label+" saved", "success")
+	h.renderCell(c, "table.cell", entity, column, "")
+}
+
+// editableCell returns the editable column and the entity ID of the request
+func (h *CRUDHandler[T]) editableCell(c *gin.Context) (valueobject.SmartTableColumn, uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.RenderError(c, http.StatusBadRequest, "Invalid ID")
+		return valueobject.SmartTableColumn{}, 0, false
+	}
+
+	for _, column := range h.tableConfig().Columns {
+		if column.Field == c.Param("field") && column.Editable != "" {
+			return column, uint(id), true
+		}
+	}
+
+	h.RenderError(c, http.StatusBadRequest, "This column cannot be edited")
+	return valueobject.SmartTableColumn{}, 0, false
+}
+
+// renderCell renders a cell template of the SmartTable for the entity. The
+// error is shown below the input of a cell being edited.
+func (h *CRUDHandler[T]) renderCell(c *gin.Context, name string, entity *T, column valueobject.SmartTableColumn, message string) {
+	value := ""
+	if field := reflect.ValueOf(entity).Elem().FieldByName(column.Field); field.IsValid() {
+		value = convert.Text(field, "2006-01-02")
+	}
+
+	c.HTML(http.StatusOK, name, gin.H{
+		"entity":  *entity,
+		"column":  column,
+		"value":   value,
+		"error":   message,
+		"baseUrl": strings.TrimSuffix(c.Request.URL.Path, "/"+c.Param("id")+"/cells/"+c.Param("field")),
+	})
+}
@@ -11,7 +11,11 @@ import (
 
 type CRUDHandler[T any] struct {
 	service *service.CRUDService[T]
-	tmpl    string // Base template name for the entity
+	tmpl    string                     // Base template name for the entity
+	actions map[Action]gin.HandlerFunc // Overridden actions, see Override
+	views   service.ViewService        // Saved table views, see EnableViews
+	forms   []FormExtension[T]         // Extra data of the form pages, see ExtendForm
+	checks  []ImportCheck[T]           // Extra checks of imported rows, see CheckImport
 	BaseHandler
 }
 
@@ -38,12 +42,20 @@ func (h *CRUDHandler[T]) RegisterRoute(r *gin.RouterGroup, path string, method s
 
 func (h *CRUDHandler[T]) RegisterDefaultRoutes(r *gin.RouterGroup, path string) {
 	group := r.Group(path)
-	group.GET("", h.SmartTableList)
-	group.GET("/:id", h.Get)
-	group.GET("/new", h.Get)
-	group.POST("", h.Create)
-	group.PUT("/:id", h.Update)
-	group.DELETE("/:id", h.Delete)
+	group.GET("", h.Action(ActionList))
+	group.GET("/new", h.Action(ActionNew))
+	group.GET("/export", h.Export)
+	group.GET("/:id", h.Action(ActionShow))
+	group.GET("/:id/edit", h.Action(ActionEdit))
+	group.POST("", h.Action(ActionCreate))
+	group.POST("/bulk", h.Bulk)
+	h.RegisterViewRoutes(r, path)
+	group.POST("/:id", h.Action(ActionUpdate)) // HTML forms can only POST
+	group.PUT("/:id", h.Action(ActionUpdate))
+	group.DELETE("/:id", h.Action(ActionDelete))
+
+	h.RegisterImportRoutes(r, path)
+	h.RegisterCellRoutes(r, path)
 }
 
 func (h *CRUDHandler[T]) List(c *gin.Context) {
@@ -51,7 +63,7 @@ func (h *CRUDHandler[T]) List(c *gin.Context) {
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
 
 	pagination := valueobject.NewPagination(page, pageSize)
-	entities, pagination, err := h.service.List(c.Request.Context(), pagination)
+	entities, pagination, err := h.service.List(c.Request.Context(), pagination, valueobject.ListQuery{})
 	if err != nil {
 		c.HTML(http.StatusInternalServerError, "error", gin.H{"error": err.Error()})
 		return
@@ -63,6 +75,24 @@ func (h *CRUDHandler[T]) List(c *gin.Context) {
 	}, h.tmpl+".table")
 }
 
+// Show renders the entity read-only
+func (h *CRUDHandler[T]) Show(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "error", gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	entity, err := h.service.Get(c.Request.Context(), uint(id))
+	if err != nil {
+		c.HTML(http.StatusNotFound, "error", gin.H{"error": err.Error()})
+		return
+	}
+
+	h.renderView(c, entity)
+}
+
+// Get renders the form editing the entity
 func (h *CRUDHandler[T]) Get(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -76,22 +106,26 @@ func (h *CRUDHandler[T]) Get(c *gin.Context) {
 		return
 	}
 
-	h.Render(c, h.tmpl+".edit", gin.H{"entity": entity}, h.tmpl+".show")
+	h.renderForm(c, entity, false, nil)
 }
 
 func (h *CRUDHandler[T]) Create(c *gin.Context) {
-	var entity T
-	if err := c.ShouldBind(&entity); err != nil {
-		c.HTML(http.StatusBadRequest, "error", gin.H{"error": err.Error()})
+	entity := new(T)
+	if errs := h.bindForm(c, entity); len(errs) > 0 {
+		h.renderForm(c, entity, true, errs)
 		return
 	}
 
-	if err := h.service.Create(c.Request.Context(), &entity); err != nil {
-		c.HTML(http.StatusInternalServerError, "error", gin.H{"error": err.Error()})
+	if err := h.service.Create(c.Request.Context(), entity); err != nil {
+		h.renderForm(c, entity, true, map[string]string{"": err.Error()})
 		return
 	}
 
-	h.Redirect(c, c.Request.URL.Path)
+	if isModal(c) {
+		h.modalSaved(c, entity, true)
+		return
+	}
+	h.Redirect(c, h.listPath(c))
 }
 
 func (h *CRUDHandler[T]) Update(c *gin.Context) {
@@ -103,21 +137,25 @@ func (h *CRUDHandler[T]) Update(c *gin.Context) {
 
 	existingEntity, err := h.service.Get(c.Request.Context(), uint(id))
 	if err != nil {
-		c.HTML(http.StatusNotFound, "error.", gin.H{"error": "Entity not found"})
+		c.HTML(http.StatusNotFound, "error", gin.H{"error": "Entity not found"})
 		return
 	}
 
-	if err := c.ShouldBind(existingEntity); err != nil {
-		c.HTML(http.StatusBadRequest, "error", gin.H{"error": err.Error()})
+	if errs := h.bindForm(c, existingEntity); len(errs) > 0 {
+		h.renderForm(c, existingEntity, false, errs)
 		return
 	}
 
 	if err := h.service.Update(c.Request.Context(), existingEntity); err != nil {
-		c.HTML(http.StatusInternalServerError, "error", gin.H{"error": err.Error()})
+		h.renderForm(c, existingEntity, false, map[string]string{"": err.Error()})
 		return
 	}
 
-	h.Redirect(c, c.Request.URL.Path)
+	if isModal(c) {
+		h.modalSaved(c, existingEntity, false)
+		return
+	}
+	h.Redirect(c, h.listPath(c))
 }
 
 func (h *CRUDHandler[T]) Delete(c *gin.Context) {
@@ -132,5 +170,11 @@ func (h *CRUDHandler[T]) Delete(c *gin.Context) {
 		return
 	}
 
-	h.Redirect(c, c.Request.URL.Path)
+	// The SmartTable removes the row itself
+	if c.GetHeader("HX-Request") == "true" {
+		h.Toast(c, "Deleted", "success")
+		c.Status(http.StatusOK)
+		return
+	}
+	h.Redirect(c, h.listPath(c))
 }
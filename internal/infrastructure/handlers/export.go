@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"belcamp/internal/domain/valueobject"
+	"belcamp/internal/jobs"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+// exportBatchSize is the number of rows loaded per query while exporting
+const exportBatchSize = 500
+
+// Export downloads the current smart table view, with its sort and filters,
+// as CSV or XLSX. Exports larger than EXPORT_ASYNC_ROWS rows (10000 by
+// default) run as a background job and redirect to its status page.
+func (h *CRUDHandler[T]) Export(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		h.RenderError(c, http.StatusBadRequest, "Unsupported export format")
+		return
+	}
+
+	config := withColumns(h.tableConfig(), c.Query("columns"))
+	query := valueobject.ListQuery{
+		Sort:    c.DefaultQuery("sort", config.DefaultSort),
+		Order:   c.DefaultQuery("order", config.DefaultOrder),
+		Filters: filterParams(c.Request.URL.Query()),
+		Preload: config.Preload,
+	}
+	columns := visibleColumns(config)
+	filename := fmt.Sprintf("%s-%s.%s", h.tmpl, time.Now().Format("20060102-150405"), format)
+
+	total, err := h.exportSize(c.Request.Context(), config, query)
+	if err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if total > int64(exportAsyncRows()) {
+		job := jobs.Default.Start(c.Request.Context(), "export", func(ctx context.Context, job *jobs.Job) error {
+			path := filepath.Join(jobs.Default.Dir(), job.ID+"."+format)
+			file, err := os.Create(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			if err := h.writeExport(ctx, file, format, columns, query, job.SetProgress); err != nil {
+				os.Remove(path)
+				return err
+			}
+			job.SetFile(path, filename)
+			return nil
+		})
+
+		h.Redirect(c, "/jobs/"+job.ID)
+		return
+	}
+
+	setDownloadHeaders(c, format, filename)
+	if err := h.writeExport(c.Request.Context(), c.Writer, format, columns, query, nil); err != nil {
+		// The download has started, so the error can only be logged
+		c.Error(err)
+	}
+}
+
+// exportSize returns the number of rows an export will write. Tables paged
+// with an approximate count use the table statistics when unfiltered.
+func (h *CRUDHandler[T]) exportSize(ctx context.Context, config valueobject.SmartTableConfig, query valueobject.ListQuery) (int64, error) {
+	if config.ApproximateCount && len(query.Filters) == 0 && query.IDs == nil {
+		return h.service.EstimateCount(ctx)
+	}
+	return h.service.Count(ctx, query)
+}
+
+// writeExport streams every entity matching the query to w in batches.
+// Computed columns cannot be sorted in the database, so those exports are in
+// primary key order. progress, when set, receives the number of rows written.
+func (h *CRUDHandler[T]) writeExport(ctx context.Context, w io.Writer, format string, columns []valueobject.SmartTableColumn, query valueobject.ListQuery, progress func(int)) error {
+	out, err := newExportWriter(format, w, columns)
+	if err != nil {
+		return err
+	}
+
+	written := 0
+	err = h.service.Each(ctx, query, exportBatchSize, func(batch []T) error {
+		for i := range batch {
+			values := make([]any, len(columns))
+			for j, column := range columns {
+				values[j] = columnValue(&batch[i], column.Field)
+			}
+			if err := out.Row(values); err != nil {
+				return err
+			}
+		}
+
+		written += len(batch)
+		if progress != nil {
+			progress(written)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return out.Close()
+}
+
+// visibleColumns returns the columns shown in the table
+func visibleColumns(config valueobject.SmartTableConfig) []valueobject.SmartTableColumn {
+	var columns []valueobject.SmartTableColumn
+	for _, column := range config.Columns {
+		if column.Visible {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// setDownloadHeaders marks the response as a file download
+func setDownloadHeaders(c *gin.Context, format, filename string) {
+	contentType := "text/csv; charset=utf-8"
+	if format == "xlsx" {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+}
+
+// exportWriter writes the rows of an export in a file format. The header is
+// written when the writer is created.
+type exportWriter interface {
+	Row(values []any) error
+	Close() error
+}
+
+func newExportWriter(format string, w io.Writer, columns []valueobject.SmartTableColumn) (exportWriter, error) {
+	switch format {
+	case "csv":
+		return newCSVWriter(w, columns)
+	case "xlsx":
+		return newXLSXWriter(w, columns)
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// csvWriter writes CSV, formatting values as text
+type csvWriter struct {
+	w       *csv.Writer
+	columns []valueobject.SmartTableColumn
+}
+
+func newCSVWriter(w io.Writer, columns []valueobject.SmartTableColumn) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w), columns: columns}
+
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Label
+	}
+	return cw, cw.w.Write(header)
+}
+
+func (cw *csvWriter) Row(values []any) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = exportText(value, cw.columns[i].Formatter)
+		switch value.(type) {
+		case nil, time.Time, float32, float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, bool:
+		default:
+			// Spreadsheets opening the CSV must not run text as a formula
+			record[i] = escapeFormula(record[i])
+		}
+	}
+	return cw.w.Write(record)
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// xlsxWriter writes a single sheet workbook with typed cells, so dates and
+// amounts stay sortable in the spreadsheet. Rows are streamed to a temporary
+// file by excelize rather than kept in memory.
+type xlsxWriter struct {
+	w       io.Writer
+	file    *excelize.File
+	stream  *excelize.StreamWriter
+	columns []valueobject.SmartTableColumn
+	styles  map[string]int
+	row     int
+}
+
+func newXLSXWriter(w io.Writer, columns []valueobject.SmartTableColumn) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	sheet := file.GetSheetName(0)
+
+	stream, err := file.NewStreamWriter(sheet)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	xw := &xlsxWriter{w: w, file: file, stream: stream, columns: columns, styles: map[string]int{}, row: 1}
+	for name, format := range map[string]string{
+		"header":         "",
+		"formatDate":     "yyyy-mm-dd",
+		"formatMoney":    "$#,##0.00",
+		"formatCurrency": "$#,##0.00",
+		"datetime":       "yyyy-mm-dd hh:mm:ss",
+	} {
+		style := &excelize.Style{}
+		if name == "header" {
+			style.Font = &excelize.Font{Bold: true}
+		} else {
+			style.CustomNumFmt = &format
+		}
+		if xw.styles[name], err = file.NewStyle(style); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	header := make([]any, len(columns))
+	for i, column := range columns {
+		header[i] = excelize.Cell{StyleID: xw.styles["header"], Value: column.Label}
+	}
+	if err := xw.write(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return xw, nil
+}
+
+func (xw *xlsxWriter) Row(values []any) error {
+	cells := make([]any, len(values))
+	for i, value := range values {
+		cells[i] = xw.cell(value, xw.columns[i].Formatter)
+	}
+	return xw.write(cells)
+}
+
+func (xw *xlsxWriter) Close() error {
+	defer xw.file.Close()
+
+	if err := xw.stream.Flush(); err != nil {
+		return err
+	}
+	return xw.file.Write(xw.w)
+}
+
+func (xw *xlsxWriter) write(cells []any) error {
+	cell, err := excelize.CoordinatesToCellName(1, xw.row)
+	if err != nil {
+		return err
+	}
+	xw.row++
+	return xw.stream.SetRow(cell, cells)
+}
+
+// cell converts a column value to a typed cell styled after its formatter
+func (xw *xlsxWriter) cell(value any, formatter string) any {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		if v.IsZero() {
+			return nil
+		}
+		style, ok := xw.styles[formatter]
+		if !ok {
+			style = xw.styles["datetime"]
+		}
+		return excelize.Cell{StyleID: style, Value: v}
+	case float32, float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		if style, ok := xw.styles[formatter]; ok {
+			return excelize.Cell{StyleID: style, Value: v}
+		}
+		return v
+	case bool:
+		return v
+	default:
+		return exportText(v, formatter)
+	}
+}
+
+// exportText formats a column value as text. Money keeps two decimals
+// without the currency sign so spreadsheets still read it as a number.
+func exportText(value any, formatter string) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		if formatter == "formatDate" {
+			return v.Format("2006-01-02")
+		}
+		return v.Format("2006-01-02 15:04:05")
+	case float64:
+		if formatter == "formatMoney" || formatter == "formatCurrency" {
+			return strconv.FormatFloat(v, 'f', 2, 64)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return exportText(float64(v), formatter)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// escapeFormula prefixes text that a spreadsheet would read as a formula,
+// e.g. "=HYPERLINK(...)" typed in a name, with a quote
+func escapeFormula(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+// exportAsyncRows returns the number of rows above which exports run in the
+// background
+func exportAsyncRows() int {
+	if n, err := strconv.Atoi(os.Getenv("EXPORT_ASYNC_ROWS")); err == nil && n > 0 {
+		return n
+	}
+	return 10000
+}
@@ -4,6 +4,7 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"reflect"
 	"sort"
 	"strconv"
@@ -17,53 +18,110 @@ import (
 
 // SmartTableList is a generic handler for listing entities with smart table
 func (h *CRUDHandler[T]) SmartTableList(c *gin.Context) {
-	// Parse query parameters
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
-
-	// Get pagination
-	pagination := valueobject.NewPagination(page, pageSize)
-
-	// Get entities
-	entities, pagination, err := h.service.List(c.Request.Context(), pagination)
+	// Open the default view of the user when no query is given
+	views, err := h.applyDefaultView(c)
 	if err != nil {
-		c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": err.Error()})
+		c.HTML(http.StatusInternalServerError, "error", gin.H{"error": err.Error()})
 		return
 	}
 
-	// Get config - try to get from entity type first
-	var config valueobject.SmartTableConfig
+	// Parse query parameters
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
 
-	// Create a zero value of T to check if it implements SmartTableProvider
-	var zero T
-	if provider, ok := interface{}(zero).(interfaces.SmartTableProvider); ok {
-		config = provider.GetSmartTableConfig()
-	} else {
-		// Fallback to default config
-		config = getDefaultConfig[T]()
+	// Get config from the entity type, falling back to the default config,
+	// with the columns chosen by the user
+	config := withColumns(h.tableConfig(), c.Query("columns"))
+	if err := h.resolveOptions(c, &config); err != nil {
+		c.HTML(http.StatusInternalServerError, "error", gin.H{"error": err.Error()})
+		return
 	}
 
-	// Apply sorting if provided
+	// Sort and filter in the database when possible
 	sortField := c.DefaultQuery("sort", config.DefaultSort)
 	sortOrder := c.DefaultQuery("order", config.DefaultOrder)
-
-	if sortField != "" && len(entities) > 0 {
-		sortEntities(entities, sortField, sortOrder == "desc")
-	}
+	filter := filterParams(c.Request.URL.Query())
+	query := valueobject.ListQuery{Sort: sortField, Order: sortOrder, Filters: filter, Preload: config.Preload}
 
 	// Build view model with config from entity
 	viewModel := gin.H{
-		"entities":        entities,
-		"pagination":      pagination,
 		"config":          config,
 		"baseUrl":         c.Request.URL.Path,
+		"currentUrl":      c.Request.URL.RequestURI(),
+		"exportUrl":       c.Request.URL.Path + "/export?" + c.Request.URL.RawQuery,
 		"currentSort":     sortField,
 		"currentOrder":    sortOrder,
-		"filter":          c.QueryMap("filter"),
+		"filter":          filter,
 		"currentPageSize": pageSize,
+		"viewsEnabled":    h.views != nil,
+		"views":           views,
+		"viewQuery":       viewQuery(c.Request.URL.RawQuery),
+		"columnChoices":   columnChoices(config),
+	}
+
+	if config.Pagination == valueobject.PaginationCursor {
+		// Keyset pagination avoids OFFSET scans and COUNT(*) on large tables
+		cursor := valueobject.NewCursorPagination(pageSize, c.Query("after"), c.Query("before"))
+		cursor.Approximate = config.ApproximateCount
+
+		entities, err := h.service.ListCursor(c.Request.Context(), cursor, query)
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "error", gin.H{"error": err.Error()})
+			return
+		}
+
+		viewModel["entities"] = entities
+		viewModel["cursor"] = cursor
+	} else {
+		pagination := valueobject.NewPagination(page, pageSize)
+
+		entities, pagination, err := h.service.List(c.Request.Context(), pagination, query)
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "error", gin.H{"error": err.Error()})
+			return
+		}
+
+		// Computed fields can only be sorted in memory
+		if sortField != "" && len(entities) > 0 && !h.service.SortsInDatabase(sortField) {
+			sortEntities(entities, sortField, sortOrder == "desc")
+		}
+
+		viewModel["entities"] = entities
+		viewModel["pagination"] = pagination
+	}
+
+	h.Render(c, h.tmpl+".index", viewModel, "table")
+}
+
+// tableConfig returns the smart table config of T
+func (h *CRUDHandler[T]) tableConfig() valueobject.SmartTableConfig {
+	// Create a zero value of T to check if it implements SmartTableProvider
+	var zero T
+	if provider, ok := interface{}(zero).(interfaces.SmartTableProvider); ok {
+		return provider.GetSmartTableConfig()
 	}
+	return getDefaultConfig[T]()
+}
 
-	h.Render(c, h.tmpl+".index", viewModel, h.tmpl+".table")
+// filterParams collects the filter[...] parameters. Repeated parameters, as
+// posted by multi-selects, are joined with commas.
+func filterParams(values url.Values) map[string]string {
+	filters := make(map[string]string)
+	for key, list := range values {
+		if !strings.HasPrefix(key, "filter[") || !strings.HasSuffix(key, "]") {
+			continue
+		}
+		var kept []string
+		for _, value := range list {
+			if value = strings.TrimSpace(value); value != "" {
+				kept = append(kept, value)
+			}
+		}
+		if len(kept) > 0 {
+			filters[key[len("filter["):len(key)-1]] = strings.Join(kept, ",")
+		}
+	}
+	return filters
 }
 
 // sortEntities sorts a slice of entities by a given field
@@ -191,6 +249,7 @@ func getDefaultConfig[T any]() valueobject.SmartTableConfig {
 		DefaultOrder: "asc",
 		PageSizes:    []int{10, 25, 50},
 		Actions:      getDefaultActions(),
+		BulkActions:  getDefaultBulkActions(),
 	}
 }
 
@@ -216,3 +275,46 @@ func getDefaultActions() []valueobject.SmartTableAction {
 		// },
 	}
 }
+
+// getDefaultBulkActions creates the bulk actions available on every table
+func getDefaultBulkActions() []valueobject.SmartTableBulkAction {
+	return []valueobject.SmartTableBulkAction{
+		{
+			Name:   "export",
+			Label:  "Export selected",
+			Export: true,
+			Class:  "text-gray-700 hover:bg-gray-100",
+		},
+	}
+}
+
+// columnValue returns the value shown in a column: a struct field, or the
+// result of a method for computed columns such as CategoryName
+func columnValue(entity any, field string) any {
+	v := reflect.ValueOf(entity)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if f := getNestedField(v, strings.Split(field, ".")); f.IsValid() && f.CanInterface() {
+		if f.Kind() == reflect.Ptr {
+			if f.IsNil() {
+				return nil
+			}
+			f = f.Elem()
+		}
+		return f.Interface()
+	}
+
+	// Look the method up on a pointer so pointer receivers are found too
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	if method := ptr.MethodByName(field); method.IsValid() && method.Type().NumIn() == 0 && method.Type().NumOut() > 0 {
+		return method.Call(nil)[0].Interface()
+	}
+
+	return nil
+}
@@ -0,0 +1,396 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"belcamp/internal/convert"
+	"belcamp/internal/domain/interfaces"
+	"belcamp/internal/domain/valueobject"
+	"belcamp/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// formField is a form field with the value shown in its input
+type formField struct {
+	valueobject.FormField
+	Name    string // Name of the input, the form tag of the struct field if any
+	Value   string
+	Checked bool
+	Error   string
+}
+
+// Display returns the value as shown in the read-only view: the label of the
+// chosen option, or Yes and No for checkboxes
+func (f formField) Display() string {
+	switch {
+	case f.Widget == "checkbox" && f.Checked:
+		return "Yes"
+	case f.Widget == "checkbox":
+		return "No"
+	}
+	for _, option := range f.Options {
+		if option.Value == f.Value {
+			return option.Label
+		}
+	}
+	return f.Value
+}
+
+// formGroup is a group of the form as rendered by the "form" template
+type formGroup struct {
+	Label  string
+	Fields []formField
+}
+
+// FormExtension adds data to the create and edit pages of an entity
+type FormExtension[T any] func(c *gin.Context, entity *T, data gin.H) error
+
+// ExtendForm adds data to the create and edit pages, e.g. for the tabs of a
+// custom edit template
+func (h *CRUDHandler[T]) ExtendForm(extension FormExtension[T]) {
+	h.forms = append(h.forms, extension)
+}
+
+// New renders the form creating an entity
+func (h *CRUDHandler[T]) New(c *gin.Context) {
+	h.renderForm(c, new(T), true, nil)
+}
+
+// renderForm renders the entity form, with the validation errors by field.
+// The entity template "<tmpl>.edit" is used when there is one, otherwise the
+// form is generated from the FormConfig of T. HTMX requests for the modal get
+// the form as a modal fragment.
+func (h *CRUDHandler[T]) renderForm(c *gin.Context, entity *T, isNew bool, errs map[string]string) {
+	groups, activeTab, err := h.formGroups(c, entity, errs)
+	if err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	listURL := h.listPath(c)
+	action := listURL
+	title := "New " + h.tmpl
+	if !isNew {
+		id := fmt.Sprint(columnValue(entity, "ID"))
+		action = listURL + "/" + id
+		title = fmt.Sprintf("Edit %s #%s", h.tmpl, id)
+	}
+
+	data := gin.H{
+		"title":      title,
+		"entity":     entity,
+		"isNew":      isNew,
+		"groups":     groups,
+		"activeTab":  activeTab,
+		"formAction": action,
+		"listUrl":    listURL,
+		"formError":  errs[""],
+		"modal":      isModal(c),
+	}
+
+	for _, extend := range h.forms {
+		if err := extend(c, entity, data); err != nil {
+			h.RenderError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	partial := "form.body"
+	if isModal(c) {
+		modal, ok := h.modalTemplate()
+		if !ok {
+			// Open the custom edit page instead
+			h.Redirect(c, c.Request.URL.Path)
+			return
+		}
+		partial = modal
+	}
+
+	templateName := h.tmpl + ".edit"
+	if !utils.HasTemplate(templateName) {
+		templateName = "form"
+	}
+	status := http.StatusOK
+	if len(errs) > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	h.RenderStatus(c, status, templateName, data, partial)
+}
+
+// formGroups returns the groups of the form of the entity with the values of
+// its fields, and the tab of the first field with an error
+func (h *CRUDHandler[T]) formGroups(c *gin.Context, entity *T, errs map[string]string) ([]formGroup, int, error) {
+	var groups []formGroup
+	activeTab := -1
+	v := reflect.ValueOf(entity).Elem()
+	for i, group := range h.formConfig().Groups {
+		view := formGroup{Label: group.Label}
+		for _, field := range group.Fields {
+			if field.OptionSource != nil {
+				options, err := h.formOptions(c, *field.OptionSource, v)
+				if err != nil {
+					return nil, 0, err
+				}
+				field.Options = options
+			}
+
+			input := formField{FormField: field, Name: inputName(v.Type(), field.Field), Error: errs[field.Field]}
+			if value := v.FieldByName(field.Field); value.IsValid() {
+				input.Value = convert.Text(value, timeLayout(field.Widget))
+				input.Checked = input.Value == "true"
+			}
+			if input.Error != "" && activeTab < 0 {
+				activeTab = i
+			}
+			view.Fields = append(view.Fields, input)
+		}
+		groups = append(groups, view)
+	}
+	return groups, max(activeTab, 0), nil
+}
+
+// renderView renders the entity read-only, with the fields of its form. The
+// entity template "<tmpl>.show" is used when there is one, otherwise the
+// generated view. HTMX requests for the modal get the view as a modal
+// fragment.
+func (h *CRUDHandler[T]) renderView(c *gin.Context, entity *T) {
+	groups, _, err := h.formGroups(c, entity, nil)
+	if err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	listURL := h.listPath(c)
+	id := fmt.Sprint(columnValue(entity, "ID"))
+	_, editModal := h.modalTemplate()
+	data := gin.H{
+		"title":     fmt.Sprintf("%s #%s", h.tmpl, id),
+		"entity":    entity,
+		"groups":    groups,
+		"editUrl":   listURL + "/" + id + "/edit",
+		"editModal": editModal,
+		"listUrl":   listURL,
+		"modal":     isModal(c),
+	}
+
+	partial := "form.view"
+	if isModal(c) {
+		partial = "form.view.modal"
+	}
+	templateName := h.tmpl + ".show"
+	if !utils.HasTemplate(templateName) {
+		templateName = "form.show"
+	}
+	h.Render(c, templateName, data, partial)
+}
+
+// bindForm sets the editable fields of the form on the entity from the posted
+// values and returns the validation errors by field. Fields that are not
+// posted keep their value, except checkboxes, which are not posted when
+// unchecked.
+func (h *CRUDHandler[T]) bindForm(c *gin.Context, entity *T) map[string]string {
+	errs := map[string]string{}
+
+	v := reflect.ValueOf(entity).Elem()
+	for _, group := range h.formConfig().Groups {
+		for _, field := range group.Fields {
+			target := v.FieldByName(field.Field)
+			if field.ReadOnly || !target.IsValid() || !target.CanSet() {
+				continue
+			}
+
+			raw, posted := c.GetPostForm(inputName(v.Type(), field.Field))
+			raw = strings.TrimSpace(raw)
+			if field.Widget == "checkbox" {
+				// Unchecked boxes are not posted at all
+				raw = strconv.FormatBool(raw != "" && raw != "false")
+			} else if !posted {
+				// Fields left out of the form keep their value
+				if field.Required && target.IsZero() {
+					errs[field.Field] = field.Label + " is required"
+				}
+				continue
+			}
+
+			if raw == "" {
+				if field.Required {
+					errs[field.Field] = field.Label + " is required"
+					continue
+				}
+				target.Set(reflect.Zero(target.Type()))
+				continue
+			}
+
+			if err := convert.Set(target, raw); err != nil {
+				errs[field.Field] = err.Error()
+			}
+		}
+	}
+
+	return errs
+}
+
+// formConfig returns the form config of T
+func (h *CRUDHandler[T]) formConfig() valueobject.FormConfig {
+	var zero T
+	if provider, ok := interface{}(zero).(interfaces.FormProvider); ok {
+		return provider.GetFormConfig()
+	}
+	return getDefaultFormConfig[T]()
+}
+
+// formOptions loads the options of a relation select. For relations to T
+// itself, such as a parent category, the entity is left out of its options.
+func (h *CRUDHandler[T]) formOptions(c *gin.Context, source valueobject.OptionSource, entity reflect.Value) ([]valueobject.FilterOption, error) {
+	options, err := h.service.Options(c.Request.Context(), source)
+	if err != nil {
+		return nil, err
+	}
+
+	if reflect.TypeOf(source.Model) != reflect.TypeOf(new(T)) {
+		return options, nil
+	}
+
+	id := fmt.Sprint(columnValue(entity.Addr().Interface(), "ID"))
+	filtered := options[:0]
+	for _, option := range options {
+		if option.Value != id {
+			filtered = append(filtered, option)
+		}
+	}
+	return filtered, nil
+}
+
+// listPath returns the path of the list, the request path without the ID
+// and the trailing "/new" or "/edit"
+func (h *CRUDHandler[T]) listPath(c *gin.Context) string {
+	path := strings.TrimSuffix(c.Request.URL.Path, "/edit")
+	path = strings.TrimSuffix(path, "/new")
+	if id := c.Param("id"); id != "" {
+		path = strings.TrimSuffix(path, "/"+id)
+	}
+	if slug := c.Param("slug"); slug != "" {
+		path = strings.TrimSuffix(path, "/slug/"+slug)
+	}
+	return path
+}
+
+// inputName returns the input name of a struct field: its form tag, as used
+// by gin binding, or the field name
+func inputName(t reflect.Type, field string) string {
+	if sf, ok := t.FieldByName(field); ok {
+		if tag, _, _ := strings.Cut(sf.Tag.Get("form"), ","); tag != "" && tag != "-" {
+			return tag
+		}
+	}
+	return field
+}
+
+// timeLayout returns the layout of the time inputs of a widget
+func timeLayout(widget string) string {
+	if widget == "datetime" {
+		return "2006-01-02T15:04"
+	}
+	return "2006-01-02"
+}
+
+// getDefaultFormConfig creates a form with a field for every editable
+// column of T, skipping keys, timestamps and relations
+func getDefaultFormConfig[T any]() valueobject.FormConfig {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+
+	return valueobject.FormConfig{
+		Groups: []valueobject.FormGroup{
+			{Label: "General", Fields: defaultFormFields(t)},
+		},
+	}
+}
+
+func defaultFormFields(t reflect.Type) []valueobject.FormField {
+	var fields []valueobject.FormField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		// Skip unexported fields
+		if field.PkgPath != "" {
+			continue
+		}
+
+		// Handle embedded structs like gorm.Model
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			fields = append(fields, defaultFormFields(field.Type)...)
+			continue
+		}
+
+		switch field.Name {
+		case "ID", "CreatedAt", "UpdatedAt", "DeletedAt":
+			continue
+		}
+
+		// Fields excluded from binding, such as uploads, are edited elsewhere
+		if field.Tag.Get("form") == "-" {
+			continue
+		}
+
+		widget := defaultWidget(field.Type)
+		if widget == "" {
+			continue
+		}
+
+		fields = append(fields, valueobject.FormField{
+			Field:  field.Name,
+			Label:  fieldLabel(field.Name),
+			Widget: widget,
+		})
+	}
+	return fields
+}
+
+// defaultWidget picks the input of a field type; relations get none
+func defaultWidget(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return "date"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "checkbox"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "text"
+	case reflect.Map:
+		return "json"
+	case reflect.Slice:
+		// JSON columns are raw bytes or lists of plain values
+		if t.Elem().Kind() != reflect.Struct {
+			return "json"
+		}
+	}
+	return ""
+}
+
+// fieldLabel turns a field name such as "ShortDescription" into "Short Description"
+func fieldLabel(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			b.WriteRune(' ')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
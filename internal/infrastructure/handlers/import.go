@@ -0,0 +1,458 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"belcamp/internal/domain/interfaces"
+	"belcamp/internal/domain/valueobject"
+	"belcamp/internal/importer"
+	"belcamp/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// importPreviewRows is the number of rows listed in the import preview
+const importPreviewRows = 200
+
+// importRow is a spreadsheet row checked by the import wizard
+type importRow struct {
+	Line    int // Line in the file, counting the header
+	Key     string
+	Cells   []string
+	Created bool
+	Errors  []string
+}
+
+// importSummary counts what an import did, or would do on a dry run
+type importSummary struct {
+	Total   int
+	Created int
+	Updated int
+	Failed  int
+}
+
+// ImportCheck validates a decoded row before it is imported, e.g. rules the
+// entity form enforces on save
+type ImportCheck[T any] func(entity *T) error
+
+// CheckImport adds a check run on every decoded row of the preview, the dry
+// run and the import
+func (h *CRUDHandler[T]) CheckImport(check ImportCheck[T]) {
+	h.checks = append(h.checks, check)
+}
+
+// RegisterImportRoutes registers the import wizard under {path}/import when
+// T implements interfaces.ImportProvider
+func (h *CRUDHandler[T]) RegisterImportRoutes(r *gin.RouterGroup, path string) {
+	if _, ok := h.importConfig(); !ok {
+		return
+	}
+
+	group := r.Group(path + "/import")
+	group.GET("", h.ImportForm)
+	group.POST("", h.ImportUpload)
+	group.POST("/:token/preview", h.ImportPreview)
+	group.POST("/:token", h.ImportCommit)
+	group.GET("/:token/errors", h.ImportErrors)
+}
+
+// ImportForm renders the upload step of the import wizard
+func (h *CRUDHandler[T]) ImportForm(c *gin.Context) {
+	h.renderImport(c, "upload", gin.H{})
+}
+
+// ImportUpload stores the uploaded file and renders the column mapping step,
+// with columns matched to fields by their header
+func (h *CRUDHandler[T]) ImportUpload(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		h.renderImport(c, "upload", gin.H{"error": "Please choose a CSV or XLSX file"})
+		return
+	}
+
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if !slices.Contains(importer.Extensions, ext) {
+		h.renderImport(c, "upload", gin.H{"error": "Only CSV and XLSX files can be imported"})
+		return
+	}
+
+	dir := importDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	removeStaleImports(dir)
+
+	token := uuid.New().String()
+	path := filepath.Join(dir, token+ext)
+	if err := c.SaveUploadedFile(file, path); err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	metrics.RecordUpload("import", file.Size)
+
+	sheet, err := importer.ReadFile(path)
+	if err != nil {
+		os.Remove(path)
+		h.renderImport(c, "upload", gin.H{"error": "Could not read the file: " + err.Error()})
+		return
+	}
+
+	config, _ := h.importConfig()
+	h.renderImport(c, "mapping", gin.H{
+		"token":    token,
+		"filename": file.Filename,
+		"header":   sheet.Header,
+		"rowCount": len(sheet.Rows),
+		"mapping":  importer.AutoMap(config.Fields, sheet.Header),
+	})
+}
+
+// ImportPreview saves the column mapping and dry-runs the import, listing the
+// outcome of every row
+func (h *CRUDHandler[T]) ImportPreview(c *gin.Context) {
+	path, ok := h.importFile(c)
+	if !ok {
+		return
+	}
+	config, _ := h.importConfig()
+
+	sheet, err := importer.ReadFile(path)
+	if err != nil {
+		h.RenderError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	mapping := importer.Mapping{}
+	for field, raw := range c.PostFormMap("mapping") {
+		if column, err := strconv.Atoi(raw); err == nil && column >= 0 && column < len(sheet.Header) {
+			mapping[field] = column
+		}
+	}
+
+	var missing []string
+	for _, field := range config.Fields {
+		if _, ok := mapping[field.Field]; !ok && field.Required {
+			missing = append(missing, field.Label)
+		}
+	}
+	if len(missing) > 0 {
+		h.renderImport(c, "mapping", gin.H{
+			"token":    c.Param("token"),
+			"header":   sheet.Header,
+			"rowCount": len(sheet.Rows),
+			"mapping":  mapping,
+			"error":    "Please choose a column for " + strings.Join(missing, ", "),
+		})
+		return
+	}
+
+	if err := saveMapping(path, mapping); err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	rows, summary, err := h.runImport(c.Request.Context(), config, sheet, mapping, true)
+	if err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.renderPreview(c, rows, summary, "")
+}
+
+// ImportCommit imports every row in a single transaction. Nothing is saved
+// when any row is invalid.
+func (h *CRUDHandler[T]) ImportCommit(c *gin.Context) {
+	path, ok := h.importFile(c)
+	if !ok {
+		return
+	}
+	config, _ := h.importConfig()
+
+	sheet, mapping, err := loadImport(path)
+	if err != nil {
+		h.RenderError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rows, summary, err := h.runImport(c.Request.Context(), config, sheet, mapping, false)
+	if err != nil || summary.Failed > 0 {
+		message := "Fix the rows with errors and upload the file again; nothing was imported"
+		if err != nil && summary.Failed == 0 {
+			message = err.Error()
+		}
+		h.renderPreview(c, rows, summary, message)
+		return
+	}
+
+	os.Remove(path)
+	os.Remove(path + ".json")
+
+	h.renderImport(c, "done", gin.H{"summary": summary})
+}
+
+// ImportErrors downloads the rows that failed the dry run as CSV, with the
+// problems in an extra column, so they can be fixed and imported again
+func (h *CRUDHandler[T]) ImportErrors(c *gin.Context) {
+	path, ok := h.importFile(c)
+	if !ok {
+		return
+	}
+	config, _ := h.importConfig()
+
+	sheet, mapping, err := loadImport(path)
+	if err != nil {
+		h.RenderError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rows, _, err := h.runImport(c.Request.Context(), config, sheet, mapping, true)
+	if err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("%s-import-errors-%s.csv", h.tmpl, time.Now().Format("20060102-150405"))
+	setDownloadHeaders(c, "csv", filename)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write(escapeRecord(append(append([]string{"Line"}, sheet.Header...), "Errors")))
+	for _, row := range rows {
+		if len(row.Errors) > 0 {
+			w.Write(escapeRecord(append(append([]string{strconv.Itoa(row.Line)}, row.Cells...), strings.Join(row.Errors, "; "))))
+		}
+	}
+	w.Flush()
+}
+
+// escapeRecord escapes the cells of an uploaded sheet written back as CSV, so
+// spreadsheets opening the report never run them as formulas
+func escapeRecord(record []string) []string {
+	for i, cell := range record {
+		record[i] = escapeFormula(cell)
+	}
+	return record
+}
+
+// runImport decodes the rows of the sheet and imports the valid ones. With
+// dryRun nothing is saved, but every row is still checked against the
+// database.
+func (h *CRUDHandler[T]) runImport(ctx context.Context, config valueobject.ImportConfig, sheet *importer.Sheet, mapping importer.Mapping, dryRun bool) ([]importRow, importSummary, error) {
+	lookups := importer.Lookups{}
+	for _, field := range config.Fields {
+		if _, mapped := mapping[field.Field]; field.Lookup == nil || !mapped {
+			continue
+		}
+		options, err := h.service.Options(ctx, *field.Lookup)
+		if err != nil {
+			return nil, importSummary{}, err
+		}
+		lookups[field.Field] = importer.NewLookup(options)
+	}
+
+	// Only the mapped fields are written to existing entities
+	var fields []string
+	for _, field := range config.Fields {
+		if _, mapped := mapping[field.Field]; mapped {
+			fields = append(fields, field.Field)
+		}
+	}
+
+	rows := make([]importRow, len(sheet.Rows))
+	seen := map[string]int{}
+	var entities []*T
+	var positions []int
+
+	for i, cells := range sheet.Rows {
+		row := importRow{Line: sheet.Lines[i], Cells: cells}
+		if column, ok := mapping[config.Key]; ok {
+			row.Key = strings.TrimSpace(cells[column])
+		}
+
+		entity := new(T)
+		row.Errors = importer.Decode(entity, config.Fields, mapping, lookups, cells)
+		if len(row.Errors) == 0 {
+			for _, check := range h.checks {
+				if err := check(entity); err != nil {
+					row.Errors = append(row.Errors, err.Error())
+				}
+			}
+		}
+		if line, duplicate := seen[row.Key]; duplicate && row.Key != "" {
+			row.Errors = append(row.Errors, fmt.Sprintf("%s %q is repeated from line %d", config.Key, row.Key, line))
+		}
+		seen[row.Key] = row.Line
+
+		if len(row.Errors) == 0 {
+			entities = append(entities, entity)
+			positions = append(positions, i)
+		}
+		rows[i] = row
+	}
+
+	summary := importSummary{Total: len(rows)}
+	if len(entities) > 0 {
+		// Rows that failed to decode keep the whole import from being saved
+		outcomes, err := h.service.Import(ctx, entities, config.Key, fields, dryRun || len(entities) < len(rows))
+		for j, outcome := range outcomes {
+			row := &rows[positions[j]]
+			row.Created = outcome.Created
+			if outcome.Err != nil {
+				row.Errors = append(row.Errors, outcome.Err.Error())
+			}
+		}
+		if err != nil && !hasErrors(rows) {
+			return rows, summary, err
+		}
+	}
+
+	for _, row := range rows {
+		switch {
+		case len(row.Errors) > 0:
+			summary.Failed++
+		case row.Created:
+			summary.Created++
+		default:
+			summary.Updated++
+		}
+	}
+	return rows, summary, nil
+}
+
+// importConfig returns the import config of T, if it can be imported
+func (h *CRUDHandler[T]) importConfig() (valueobject.ImportConfig, bool) {
+	var zero T
+	if provider, ok := interface{}(zero).(interfaces.ImportProvider); ok {
+		return provider.GetImportConfig(), true
+	}
+	return valueobject.ImportConfig{}, false
+}
+
+// importFile returns the uploaded file of the token in the URL
+func (h *CRUDHandler[T]) importFile(c *gin.Context) (string, bool) {
+	token := c.Param("token")
+	if _, err := uuid.Parse(token); err == nil {
+		for _, ext := range importer.Extensions {
+			path := filepath.Join(importDir(), token+ext)
+			if _, err := os.Stat(path); err == nil {
+				return path, true
+			}
+		}
+	}
+
+	h.RenderError(c, http.StatusNotFound, "The uploaded file has expired, please upload it again")
+	return "", false
+}
+
+func (h *CRUDHandler[T]) renderImport(c *gin.Context, step string, data gin.H) {
+	config, _ := h.importConfig()
+
+	path := c.Request.URL.Path
+	base := path[:strings.LastIndex(path, "/import")]
+	if config.ListURL == "" {
+		config.ListURL = base
+	}
+
+	data["title"] = "Import " + h.tmpl
+	data["step"] = step
+	data["importUrl"] = base + "/import"
+	data["listUrl"] = config.ListURL
+	data["fields"] = config.Fields
+	data["key"] = config.Key
+	if _, ok := data["token"]; !ok {
+		data["token"] = c.Param("token")
+	}
+
+	h.Render(c, "import.wizard", data, "")
+}
+
+func (h *CRUDHandler[T]) renderPreview(c *gin.Context, rows []importRow, summary importSummary, message string) {
+	// List the failed rows first so they are not hidden past the preview limit
+	preview := make([]importRow, 0, len(rows))
+	for _, row := range rows {
+		if len(row.Errors) > 0 {
+			preview = append(preview, row)
+		}
+	}
+	for _, row := range rows {
+		if len(row.Errors) == 0 {
+			preview = append(preview, row)
+		}
+	}
+
+	h.renderImport(c, "preview", gin.H{
+		"rows":      preview[:min(len(preview), importPreviewRows)],
+		"truncated": len(preview) > importPreviewRows,
+		"summary":   summary,
+		"error":     message,
+	})
+}
+
+func hasErrors(rows []importRow) bool {
+	for _, row := range rows {
+		if len(row.Errors) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// loadImport reads an uploaded file and the mapping saved by the preview
+func loadImport(path string) (*importer.Sheet, importer.Mapping, error) {
+	data, err := os.ReadFile(path + ".json")
+	if err != nil {
+		return nil, nil, fmt.Errorf("please map the columns first")
+	}
+
+	var mapping importer.Mapping
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, nil, err
+	}
+
+	sheet, err := importer.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sheet, mapping, nil
+}
+
+func saveMapping(path string, mapping importer.Mapping) error {
+	data, err := json.Marshal(mapping)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path+".json", data, 0644)
+}
+
+// removeStaleImports deletes uploads abandoned for more than a day
+func removeStaleImports(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) > 24*time.Hour {
+			os.Remove(filepath.Join(dir, entry.Name()))
+		}
+	}
+}
+
+// importDir returns the directory holding uploaded imports, IMPORT_DIR or
+// "tmp/imports" by default
+func importDir() string {
+	if dir := os.Getenv("IMPORT_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join("tmp", "imports")
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"belcamp/internal/jobs"
+	"belcamp/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobHandler shows the progress of background jobs and serves their files
+type JobHandler struct {
+	BaseHandler
+	jobs *jobs.Manager
+}
+
+// NewJobHandler creates a handler for the jobs of the given manager
+func NewJobHandler(manager *jobs.Manager) *JobHandler {
+	return &JobHandler{jobs: manager}
+}
+
+// Show renders the status page of a job; HTMX polls the status partial
+// until the job has finished
+func (h *JobHandler) Show(c *gin.Context) {
+	job, ok := h.find(c)
+	if !ok {
+		return
+	}
+
+	h.Render(c, "jobs.show", gin.H{"title": jobTitle(job.Kind), "job": job}, "jobs.status")
+}
+
+// jobTitle returns the title of the status page of a kind of job
+func jobTitle(kind string) string {
+	if kind == "catalog" {
+		return "Catalog"
+	}
+	return "Export"
+}
+
+// Download sends the file produced by a finished job
+func (h *JobHandler) Download(c *gin.Context) {
+	job, ok := h.find(c)
+	if !ok {
+		return
+	}
+
+	if job.Status != jobs.StatusDone || job.File == "" {
+		h.RenderError(c, http.StatusConflict, "The file is not ready yet")
+		return
+	}
+
+	c.FileAttachment(job.File, job.Filename)
+}
+
+// find looks up the job of the request; jobs are only visible to the user
+// that started them
+func (h *JobHandler) find(c *gin.Context) (jobs.Info, bool) {
+	job, ok := h.jobs.Get(c.Param("id"))
+	if ok {
+		snapshot := job.Snapshot()
+		if fmt.Sprint(snapshot.OwnerID) == fmt.Sprint(logging.UserID(c.Request.Context())) {
+			return snapshot, true
+		}
+	}
+
+	h.RenderError(c, http.StatusNotFound, "Job not found")
+	return jobs.Info{}, false
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+
+	"belcamp/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// modalTarget is the id of the modal container of the layout
+const modalTarget = "modal"
+
+// isModal reports whether the request was made by HTMX for the modal, e.g.
+// by the View and Edit links of the SmartTable rows
+func isModal(c *gin.Context) bool {
+	return c.GetHeader("HX-Request") == "true" && c.GetHeader("HX-Target") == modalTarget
+}
+
+// modalTemplate returns the template rendering the form of T in the modal:
+// "<tmpl>.modal" when there is one, otherwise the generated form. Entities
+// with only a custom edit page have none, they are edited on that page.
+func (h *CRUDHandler[T]) modalTemplate() (string, bool) {
+	if utils.HasTemplate(h.tmpl + ".modal") {
+		return h.tmpl + ".modal", true
+	}
+	if utils.HasTemplate(h.tmpl + ".edit") {
+		return "", false
+	}
+	return "form.modal", true
+}
+
+// modalSaved closes the modal after a save. The row of an updated entity is
+// swapped in out of band; new entities appear by refreshing the table.
+func (h *CRUDHandler[T]) modalSaved(c *gin.Context, entity *T, isNew bool) {
+	if isNew {
+		h.Toast(c, "Created", "success", "smartTableRefresh")
+		c.Status(http.StatusOK)
+		return
+	}
+
+	h.Toast(c, "Saved", "success")
+	c.HTML(http.StatusOK, "form.saved", gin.H{
+		"entity":  *entity,
+		"config":  h.tableConfig(),
+		"baseUrl": h.listPath(c),
+	})
+}
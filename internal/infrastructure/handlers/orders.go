@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+
+	"belcamp/internal/domain/entity"
+	"belcamp/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrderHandler extends the generic CRUD handler to price orders from their
+// cart when they are saved
+type OrderHandler struct {
+	*CRUDHandler[entity.Order]
+	pricing service.PricingService
+}
+
+// NewOrderHandler creates a handler pricing orders with the pricing service
+func NewOrderHandler(svc *service.CRUDService[entity.Order], pricing service.PricingService, tmpl string) *OrderHandler {
+	h := &OrderHandler{
+		CRUDHandler: NewCRUDHandler(svc, tmpl),
+		pricing:     pricing,
+	}
+
+	// Saving an order prices its cart and sets its total
+	h.Override(ActionCreate, h.Create)
+	h.Override(ActionUpdate, h.Update)
+
+	return h
+}
+
+// Create creates a priced order
+func (h *OrderHandler) Create(c *gin.Context) {
+	h.save(c, &entity.Order{}, true)
+}
+
+// Update updates an order, pricing it again when its cart or shipping changes
+func (h *OrderHandler) Update(c *gin.Context) {
+	order, err := h.service.Get(c.Request.Context(), convertToUint(c.Param("id")))
+	if err != nil {
+		h.RenderError(c, http.StatusNotFound, "Order not found")
+		return
+	}
+
+	h.save(c, order, false)
+}
+
+// save binds the posted form, then stores the order with its total
+func (h *OrderHandler) save(c *gin.Context, order *entity.Order, isNew bool) {
+	if errs := h.bindForm(c, order); len(errs) > 0 {
+		h.renderForm(c, order, isNew, errs)
+		return
+	}
+
+	if err := h.pricing.SaveOrder(c.Request.Context(), order, isNew); err != nil {
+		h.renderForm(c, order, isNew, map[string]string{"": err.Error()})
+		return
+	}
+
+	if isModal(c) {
+		h.modalSaved(c, order, isNew)
+		return
+	}
+	h.Redirect(c, h.listPath(c))
+}
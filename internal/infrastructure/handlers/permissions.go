@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"belcamp/internal/logging"
+	"belcamp/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Authorizer returns the permission check of the resources: the signed in
+// user must have been granted the permission, or every permission. The
+// permissions are loaded once per request, as the menu checks each resource.
+func Authorizer(permissions service.PermissionService) func(c *gin.Context, permission string) bool {
+	return func(c *gin.Context, permission string) bool {
+		granted, ok := c.Get("permissions")
+		if !ok {
+			var err error
+			granted, err = permissions.Granted(c.Request.Context(), currentUserID(c))
+			if err != nil {
+				logging.For("permissions").ErrorContext(c.Request.Context(), "loading the permissions failed", "error", err)
+				return false
+			}
+			c.Set("permissions", granted)
+		}
+
+		allowed := granted.(map[string]bool)
+		return allowed[permission] || allowed[service.AllPermissions]
+	}
+}
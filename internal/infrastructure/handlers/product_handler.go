@@ -2,10 +2,16 @@ package handlers
 
 import (
 	"belcamp/internal/domain/entity"
+	"belcamp/internal/infrastructure/errors"
+	"belcamp/internal/logging"
+	"belcamp/internal/metrics"
 	"belcamp/internal/service"
+	"belcamp/internal/storage"
+	"context"
 	"fmt"
+	"mime"
 	"net/http"
-	"os"
+	"net/url"
 	"path/filepath"
 	"strings"
 
@@ -16,92 +22,161 @@ import (
 // ProductHandler extends the generic CRUD handler with product-specific functionality
 type ProductHandler struct {
 	*CRUDHandler[entity.Product]
-	uploadDir string
+	store    storage.Storage
+	pricing  service.PricingService
+	variants service.VariantService
+	media    service.MediaService
+	slugs    service.SlugService
 }
 
 // NewProductHandler creates a new product handler
-func NewProductHandler(service *service.CRUDService[entity.Product], uploadDir string) *ProductHandler {
-	return &ProductHandler{
-		CRUDHandler: NewCRUDHandler(service, "products"),
-		uploadDir:   uploadDir,
+func NewProductHandler(service *service.CRUDService[entity.Product], pricing service.PricingService, variants service.VariantService, media service.MediaService, slugs service.SlugService, tmpl string, store storage.Storage) *ProductHandler {
+	h := &ProductHandler{
+		CRUDHandler: NewCRUDHandler(service, tmpl),
+		store:       store,
+		pricing:     pricing,
+		variants:    variants,
+		media:       media,
+		slugs:       slugs,
 	}
+
+	// Saving a product also stores its datasheet
+	h.Override(ActionCreate, h.Create)
+	h.Override(ActionUpdate, h.Update)
+
+	// The pricing tab edits the price tables of the product and its variants
+	h.ExtendForm(h.pricingData)
+	// The variants tab edits the variants, saved together with the product
+	h.ExtendForm(h.variantsData)
+	// The media tab orders the photos of the product and of its colors
+	h.ExtendForm(h.mediaData)
+	// Imported prices follow the same tier rules as the pricing tab
+	h.CheckImport(h.checkImportedPrices)
+
+	return h
+}
+
+// RegisterSlugRoutes registers the product page found by its slug
+func (h *ProductHandler) RegisterSlugRoutes(r *gin.RouterGroup, path string) {
+	r.GET(path+"/slug/:slug", h.BySlug)
 }
 
-// Override the Get method to use your custom template structure
-func (h *ProductHandler) Get(c *gin.Context) {
-	id := c.Param("id")
-	if id == "new" {
-		// Render the new product form
-		h.Render(c, "products.edit", gin.H{
-			"entity": &entity.Product{},
-			"isNew":  true,
-		}, "")
+// BySlug shows the product with the slug. The slugs a product had before
+// lead permanently to its current one.
+func (h *ProductHandler) BySlug(c *gin.Context) {
+	ctx := c.Request.Context()
+	requested := c.Param("slug")
+
+	id, err := h.slugs.Resolve(ctx, &entity.Product{}, requested)
+	if err == errors.ErrNotFound {
+		h.RenderError(c, http.StatusNotFound, "Product not found")
+		return
+	}
+	if err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	product, err := h.service.Get(ctx, id)
+	if err != nil {
+		h.RenderError(c, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	if product.Slug != requested {
+		c.Redirect(http.StatusMovedPermanently, h.listPath(c)+"/slug/"+url.PathEscape(product.Slug))
 		return
 	}
+	h.renderView(c, product)
+}
 
-	// Use the parent Get method for existing products
-	h.CRUDHandler.Get(c)
+// Create creates a product with its datasheet
+func (h *ProductHandler) Create(c *gin.Context) {
+	h.save(c, &entity.Product{}, true)
 }
 
-// Override the Update method to handle file uploads
+// Update updates a product and replaces or removes its datasheet
 func (h *ProductHandler) Update(c *gin.Context) {
-	id := c.Param("id")
+	product, err := h.service.Get(c.Request.Context(), convertToUint(c.Param("id")))
+	if err != nil {
+		h.RenderError(c, http.StatusNotFound, "Product not found")
+		return
+	}
 
-	// Get the existing product
-	var existingProduct *entity.Product
-	var err error
+	h.save(c, product, false)
+}
 
-	if id != "new" {
-		existingProduct, err = h.service.Get(c.Request.Context(), convertToUint(id))
+// save binds the posted form, variants, prices, photos and datasheet upload,
+// then stores the product and its variants together with its slug
+func (h *ProductHandler) save(c *gin.Context, product *entity.Product, isNew bool) {
+	if errs := h.bindForm(c, product); len(errs) > 0 {
+		h.renderForm(c, product, isNew, errs)
+		return
+	}
+	// Every tab is bound so each keeps what was typed when another fails
+	variants, removed, variantsErr := h.bindVariants(c, product)
+	overrides, pricesErr := h.bindPrices(c, product)
+	previousPhotos, photosErr := h.bindPhotos(c, product)
+	var problems []string
+	for _, err := range []error{variantsErr, pricesErr, photosErr} {
 		if err != nil {
-			c.HTML(http.StatusNotFound, "error", gin.H{"error": "Product not found"})
-			return
+			problems = append(problems, err.Error())
 		}
-	} else {
-		existingProduct = &entity.Product{}
 	}
-
-	// Get the basic product data
-	if err := c.ShouldBind(existingProduct); err != nil {
-		c.HTML(http.StatusBadRequest, "error", gin.H{"error": err.Error()})
+	if len(problems) > 0 {
+		h.renderForm(c, product, isNew, map[string]string{"": strings.Join(problems, "; ")})
 		return
 	}
+	applyPriceOverrides(variants, overrides)
 
-	// Handle datasheet upload
-	datasheetFilename, err := h.handleFileUpload(c, "datasheet", *existingProduct.Datasheet, []string{".pdf"})
+	ctx := c.Request.Context()
+	existing := ""
+	if product.Datasheet != nil {
+		existing = *product.Datasheet
+	}
+	datasheet, err := h.handleFileUpload(c, "datasheet", existing, []string{".pdf"})
 	if err != nil {
-		c.HTML(http.StatusBadRequest, "error", gin.H{"error": fmt.Sprintf("Error uploading datasheet: %v", err)})
+		h.renderForm(c, product, isNew, map[string]string{"": fmt.Sprintf("Error uploading datasheet: %v", err)})
 		return
 	}
-
-	// Update the product with the new datasheet
-	existingProduct.Datasheet = &datasheetFilename
-
-	// Save the product
-	var saveErr error
-	if id == "new" {
-		saveErr = h.service.Create(c.Request.Context(), existingProduct)
-	} else {
-		saveErr = h.service.Update(c.Request.Context(), existingProduct)
+	product.Datasheet = nil
+	if datasheet != "" {
+		product.Datasheet = &datasheet
 	}
 
-	if saveErr != nil {
-		c.HTML(http.StatusInternalServerError, "error", gin.H{"error": saveErr.Error()})
+	if err := h.variants.SaveWithProduct(ctx, product, isNew, variants, removed); err != nil {
+		// The new datasheet is not kept when the product cannot be saved
+		if datasheet != existing && datasheet != "" {
+			h.removeFile(ctx, datasheet)
+		}
+		product.Datasheet = nil
+		if existing != "" {
+			product.Datasheet = &existing
+		}
+		h.renderForm(c, product, isNew, map[string]string{"": err.Error()})
 		return
 	}
+	// The replaced or removed datasheet goes once the product no longer uses it
+	if existing != "" && existing != datasheet {
+		h.removeFile(ctx, existing)
+	}
+	// Photos taken out of the galleries are removed once nothing uses them
+	if err := h.media.DeleteUnused(ctx, previousPhotos); err != nil {
+		logging.For("media").ErrorContext(ctx, "removing unused photos failed", "error", err)
+	}
 
-	// Redirect to the product list
-	h.Redirect(c, "/products")
+	if isModal(c) {
+		h.modalSaved(c, product, isNew)
+		return
+	}
+	h.Redirect(c, h.listPath(c))
 }
 
-// handleFileUpload processes a file upload for the given field
+// handleFileUpload processes a file upload for the given field and returns
+// the key of the file to keep. Nothing is deleted: the caller removes the
+// file it replaces once the record is saved.
 func (h *ProductHandler) handleFileUpload(c *gin.Context, fieldName, existingFile string, allowedExts []string) (string, error) {
 	// Check if there's a request to remove the existing file
 	if c.PostForm(fmt.Sprintf("remove_%s", fieldName)) == "true" {
-		// Remove the existing file if it exists
-		if existingFile != "" {
-			os.Remove(filepath.Join(h.uploadDir, existingFile))
-		}
 		return "", nil
 	}
 
@@ -137,24 +212,29 @@ func (h *ProductHandler) handleFileUpload(c *gin.Context, fieldName, existingFil
 
 	// Generate unique filename
 	filename := fmt.Sprintf("%s-%s%s", uuid.New().String(), sanitizeFilename(file.Filename), ext)
-	filePath := filepath.Join(h.uploadDir, filename)
-
-	// Ensure upload directory exists
-	os.MkdirAll(h.uploadDir, 0755)
 
 	// Save the file
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
+	f, err := file.Open()
+	if err != nil {
 		return "", err
 	}
-
-	// Remove old file if it exists and a new one was uploaded
-	if existingFile != "" {
-		os.Remove(filepath.Join(h.uploadDir, existingFile))
+	defer f.Close()
+	if err := h.store.Put(c.Request.Context(), filename, f, file.Size, mime.TypeByExtension(ext)); err != nil {
+		return "", err
 	}
+	metrics.RecordUpload(fieldName, file.Size)
 
 	return filename, nil
 }
 
+// removeFile deletes a datasheet from the store. Failures are only logged, as
+// they leave an unused file rather than a broken product.
+func (h *ProductHandler) removeFile(ctx context.Context, key string) {
+	if err := h.store.Delete(ctx, key); err != nil {
+		logging.For("products").ErrorContext(ctx, "removing datasheet failed", "file", key, "error", err)
+	}
+}
+
 // sanitizeFilename removes potentially dangerous characters from a filename
 func sanitizeFilename(filename string) string {
 	// Remove path information
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"belcamp/internal/domain/entity"
+	"belcamp/internal/logging"
+	"belcamp/internal/media"
+	"belcamp/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The media tab posts the photos of every gallery, in display order, as
+// hidden inputs: "photos" for the general gallery and color_photos.<color ID>
+// for the gallery of a color, whose ID is listed in gallery_color. Photos are
+// uploaded, converted and stored as soon as they are dropped; the product
+// only references them once it is saved.
+
+// galleryInput matches the input names of the galleries
+var galleryInput = regexp.MustCompile(`^(photos|color_photos\.[0-9]+)$`)
+
+// RegisterMediaRoutes registers the photo upload and the color galleries of
+// the media tab
+func (h *ProductHandler) RegisterMediaRoutes(r *gin.RouterGroup, path string) {
+	group := r.Group(path + "/photos")
+	group.POST("", h.UploadPhotos)
+	group.GET("/gallery", h.ColorGallery)
+}
+
+// UploadPhotos stores the posted photos and renders their tiles for the
+// gallery named by input. Files that are not supported images are reported
+// and skipped.
+func (h *ProductHandler) UploadPhotos(c *gin.Context) {
+	input := c.PostForm("input")
+	if !galleryInput.MatchString(input) {
+		h.Toast(c, "Unknown gallery", "error")
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		h.Toast(c, "No photos were uploaded", "error")
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	var photos, problems []string
+	for _, file := range form.File["photos"] {
+		name, err := h.uploadPhoto(c, file)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", file.Filename, err))
+			continue
+		}
+		photos = append(photos, name)
+	}
+
+	switch {
+	case len(problems) > 0:
+		h.Toast(c, strings.Join(problems, "; "), "error")
+	case len(photos) > 0:
+		h.Toast(c, fmt.Sprintf("%d photo(s) uploaded, save the product to keep them", len(photos)), "success")
+	}
+	c.HTML(http.StatusOK, "products.photo-tiles", gin.H{"photos": photos, "input": input})
+}
+
+// uploadPhoto reads an uploaded file and stores it as a photo
+func (h *ProductHandler) uploadPhoto(c *gin.Context, file *multipart.FileHeader) (string, error) {
+	if file.Size > media.MaxUploadSize {
+		return "", fmt.Errorf("file size exceeds %dMB limit", media.MaxUploadSize>>20)
+	}
+	f, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, media.MaxUploadSize+1))
+	if err != nil {
+		return "", err
+	}
+	name, err := h.media.Upload(c.Request.Context(), data)
+	if err != nil {
+		return "", err
+	}
+	logging.For("media").InfoContext(c.Request.Context(), "photo uploaded", "file", file.Filename, "photo", name)
+	return name, nil
+}
+
+// ColorGallery renders an empty gallery for the color
+func (h *ProductHandler) ColorGallery(c *gin.Context) {
+	id := convertToUint(c.Query("color"))
+	colors, err := h.variants.Colors(c.Request.Context())
+	if err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	for _, color := range colors {
+		if color.ID == id {
+			c.HTML(http.StatusOK, "products.color-gallery", gin.H{"Color": color})
+			return
+		}
+	}
+	h.Toast(c, "Choose a color", "error")
+	c.Status(http.StatusNoContent)
+}
+
+// bindPhotos sets the posted galleries on the product and returns the photos
+// it used before, whose files are removed after the save when nothing uses
+// them anymore. Forms without the media tab keep the saved galleries.
+func (h *ProductHandler) bindPhotos(c *gin.Context, product *entity.Product) ([]string, error) {
+	if c.PostForm("media") != "true" {
+		return nil, nil
+	}
+	ctx := c.Request.Context()
+
+	previous, err := product.GetPhotos()
+	if err != nil {
+		return nil, err
+	}
+	if product.ID != 0 {
+		galleries, err := h.media.Galleries(ctx, product.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, gallery := range galleries {
+			previous = append(previous, gallery.Photos...)
+		}
+	}
+
+	photos, err := postedPhotos(c, "photos")
+	if err != nil {
+		return nil, err
+	}
+	if err := product.SetPhotos(photos); err != nil {
+		return nil, err
+	}
+
+	colors, err := h.variants.Colors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]entity.Color, len(colors))
+	for _, color := range colors {
+		byID[color.ID] = color
+	}
+
+	// The galleries are also kept by color name in the color photos of the
+	// product, which tell the colors that have photos
+	galleries := []entity.ProductColorPhoto{}
+	byName := entity.JSONColorPhotos{}
+	seen := map[uint]bool{}
+	for _, raw := range c.PostFormArray("gallery_color") {
+		// A color added twice posts its photos once, under the same input
+		id := convertToUint(raw)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		color, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("unknown color %s", raw)
+		}
+		photos, err := postedPhotos(c, "color_photos."+raw)
+		if err != nil {
+			return nil, err
+		}
+		if len(photos) == 0 {
+			continue
+		}
+		galleries = append(galleries, entity.ProductColorPhoto{ProductID: product.ID, ColorID: id, Photos: photos, Color: color})
+		byName[color.Name] = photos
+	}
+	product.ProductColorPhotos = galleries
+	if err := product.SetColorPhotos(byName); err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+// postedPhotos returns the photos posted for a gallery, which must be plain
+// file names
+func postedPhotos(c *gin.Context, input string) (entity.JSONPhotos, error) {
+	photos := entity.JSONPhotos{}
+	for _, photo := range c.PostFormArray(input) {
+		if photo == "" || filepath.Base(photo) != photo || strings.HasPrefix(photo, ".") {
+			return nil, fmt.Errorf("invalid photo %q", photo)
+		}
+		photos = append(photos, photo)
+	}
+	return photos, nil
+}
+
+// mediaData adds the galleries of the product and the colors that can get
+// one to the form
+func (h *ProductHandler) mediaData(c *gin.Context, product *entity.Product, data gin.H) error {
+	ctx := c.Request.Context()
+
+	photos, err := product.GetPhotos()
+	if err != nil {
+		return err
+	}
+
+	// Posted galleries are shown as they were when the product cannot be saved
+	var galleries []service.ColorGallery
+	if product.ProductColorPhotos != nil {
+		for _, gallery := range product.ProductColorPhotos {
+			galleries = append(galleries, service.ColorGallery{Color: gallery.Color, Photos: gallery.Photos})
+		}
+	} else if product.ID != 0 {
+		if galleries, err = h.media.Galleries(ctx, product.ID); err != nil {
+			return err
+		}
+	}
+
+	colors, err := h.variants.Colors(ctx)
+	if err != nil {
+		return err
+	}
+
+	data["media"] = gin.H{
+		"photos":    photos,
+		"galleries": galleries,
+		"colors":    colors,
+		"url":       fmt.Sprint(data["listUrl"], "/photos"),
+		"maxSize":   strconv.Itoa(media.MaxUploadSize >> 20),
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"belcamp/internal/domain/entity"
+	"belcamp/internal/domain/valueobject"
+	"belcamp/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The pricing tab posts one table of tier rows per scope: "product" for the
+// product prices and the variant ID for the overrides of a variant. Row
+// inputs are named tier_qty.<scope> and tier_price.<scope>, and variants
+// only use their own rows when tier_override.<id> is checked.
+const productScope = "product"
+
+// priceRow is a tier row as typed in the pricing tab
+type priceRow struct {
+	Quantity string
+	Price    string
+}
+
+// variantPrices is the pricing table of a variant in the pricing tab
+type variantPrices struct {
+	Variant  entity.ProductVariant
+	Override bool
+	Rows     []priceRow
+}
+
+// checkImportedPrices validates the price tiers of an imported product
+func (h *ProductHandler) checkImportedPrices(product *entity.Product) error {
+	prices, err := product.GetPrices()
+	if err == nil {
+		_, err = h.pricing.Validate(prices)
+	}
+	return err
+}
+
+// VariantPriceCheck validates the price tiers of imported variants. Variants
+// without prices of their own are sold at the prices of their product.
+func VariantPriceCheck(pricing service.PricingService) ImportCheck[entity.ProductVariant] {
+	return func(variant *entity.ProductVariant) error {
+		_, err := pricing.Validate(variant.Prices)
+		return err
+	}
+}
+
+// RegisterPricingRoutes registers the tier rows and the price preview of the
+// pricing tab
+func (h *ProductHandler) RegisterPricingRoutes(r *gin.RouterGroup, path string) {
+	group := r.Group(path + "/pricing")
+	group.GET("/row", h.PriceRow)
+	group.POST("/preview", h.PricePreview)
+}
+
+// PriceRow renders an empty tier row for a price table
+func (h *ProductHandler) PriceRow(c *gin.Context) {
+	c.HTML(http.StatusOK, "products.price-row", gin.H{
+		"scope": c.DefaultQuery("scope", productScope),
+		"row":   priceRow{},
+	})
+}
+
+// PricePreview resolves the unit price of the posted, unsaved, tiers of a
+// table for the quantity typed below it. Variants without an override use
+// the product rows.
+func (h *ProductHandler) PricePreview(c *gin.Context) {
+	scope := c.DefaultPostForm("scope", productScope)
+	quantity, err := strconv.Atoi(strings.TrimSpace(c.PostForm("preview_qty." + scope)))
+	if scope != productScope && c.PostForm("tier_override."+scope) != "true" {
+		scope = productScope
+	}
+
+	data := gin.H{}
+	if err != nil {
+		data["error"] = "Invalid quantity"
+		c.HTML(http.StatusOK, "products.price-preview", data)
+		return
+	}
+
+	prices, err := postedPrices(c, scope)
+	if err == nil {
+		var product entity.Product
+		if err = product.SetPrices(prices); err == nil {
+			var price valueobject.Money
+			if price, err = h.pricing.UnitPrice(&product, nil, quantity); err == nil {
+				data["unitPrice"] = price
+				data["total"] = price.Mul(quantity)
+				data["quantity"] = quantity
+			}
+		}
+	}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	c.HTML(http.StatusOK, "products.price-preview", data)
+}
+
+// bindPrices sets the posted product prices and returns the validated
+// overrides of the variants, nil for variants using the product prices.
+// Forms without the pricing tab leave the prices untouched.
+func (h *ProductHandler) bindPrices(c *gin.Context, product *entity.Product) (map[uint]entity.JSONPrices, error) {
+	if c.PostForm("pricing") != "true" {
+		return nil, nil
+	}
+
+	prices, err := postedPrices(c, productScope)
+	if err != nil {
+		return nil, err
+	}
+	if err := product.SetPrices(prices); err != nil {
+		return nil, err
+	}
+	// Posted overrides are kept for the form when the product is invalid
+	overrides := map[uint]entity.JSONPrices{}
+	c.Set("variantPrices", overrides)
+
+	var problems []string
+	if table, err := h.pricing.Validate(prices); err != nil {
+		problems = append(problems, err.Error())
+	} else if err := product.SetPrices(entity.NewJSONPrices(table)); err != nil {
+		return nil, err
+	}
+
+	if product.ID != 0 {
+		variants, err := h.pricing.Variants(c.Request.Context(), product)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range variants {
+			scope := strconv.FormatUint(uint64(v.Variant.ID), 10)
+			if c.PostForm("tier_override."+scope) != "true" {
+				overrides[v.Variant.ID] = nil
+				continue
+			}
+
+			prices, err := postedPrices(c, scope)
+			if err == nil && len(prices) == 0 {
+				err = fmt.Errorf("add at least one price or use the product prices")
+			}
+			var table valueobject.PriceTable
+			if err == nil {
+				table, err = h.pricing.Validate(prices)
+			}
+			if err != nil {
+				problems = append(problems, v.Variant.SKU+": "+err.Error())
+				overrides[v.Variant.ID] = prices
+				continue
+			}
+			overrides[v.Variant.ID] = entity.NewJSONPrices(table)
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return overrides, nil
+}
+
+// postedPrices reads the tier rows of a scope, skipping empty rows
+func postedPrices(c *gin.Context, scope string) (entity.JSONPrices, error) {
+	quantities := c.PostFormArray("tier_qty." + scope)
+	amounts := c.PostFormArray("tier_price." + scope)
+
+	prices := entity.JSONPrices{}
+	for i, quantity := range quantities {
+		quantity = strings.TrimSpace(quantity)
+		amount := ""
+		if i < len(amounts) {
+			amount = strings.TrimSpace(amounts[i])
+		}
+		if quantity == "" && amount == "" {
+			continue
+		}
+		if quantity == "" || amount == "" {
+			return prices, fmt.Errorf("every price needs a quantity and an amount")
+		}
+		if _, exists := prices[quantity]; exists {
+			return prices, fmt.Errorf("quantity %s appears twice", quantity)
+		}
+		prices[quantity] = amount
+	}
+	return prices, nil
+}
+
+// pricingData adds the price tables of the product and its variants to the
+// form. Overrides posted with an invalid product are shown as typed.
+func (h *ProductHandler) pricingData(c *gin.Context, product *entity.Product, data gin.H) error {
+	prices, err := product.GetPrices()
+	if err != nil {
+		return err
+	}
+	pricing := gin.H{"scope": productScope, "rows": priceRows(prices)}
+
+	if product.ID != 0 {
+		variants, err := h.pricing.Variants(c.Request.Context(), product)
+		if err != nil {
+			return err
+		}
+
+		posted, _ := c.Get("variantPrices")
+		overrides, _ := posted.(map[uint]entity.JSONPrices)
+
+		tables := make([]variantPrices, len(variants))
+		for i, v := range variants {
+			table := variantPrices{Variant: v.Variant, Override: len(v.Variant.Prices) > 0, Rows: priceRows(v.Variant.Prices)}
+			if typed, ok := overrides[v.Variant.ID]; ok {
+				table.Override, table.Rows = typed != nil, priceRows(typed)
+			}
+			tables[i] = table
+		}
+		pricing["variants"] = tables
+	}
+
+	data["pricing"] = pricing
+	return nil
+}
+
+// priceRows lists the tiers by ascending quantity
+func priceRows(prices entity.JSONPrices) []priceRow {
+	rows := make([]priceRow, 0, len(prices))
+	for quantity, price := range prices {
+		rows = append(rows, priceRow{Quantity: quantity, Price: price})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, errA := strconv.Atoi(rows[i].Quantity)
+		b, errB := strconv.Atoi(rows[j].Quantity)
+		if errA != nil || errB != nil {
+			return rows[i].Quantity < rows[j].Quantity
+		}
+		return a < b
+	})
+	return rows
+}
+
+// applyPriceOverrides sets the prices bound from the pricing tab on the
+// variants; a nil table makes a variant use the product prices
+func applyPriceOverrides(variants []entity.ProductVariant, overrides map[uint]entity.JSONPrices) {
+	for i := range variants {
+		if prices, ok := overrides[variants[i].ID]; ok {
+			variants[i].Prices = prices
+		}
+	}
+}
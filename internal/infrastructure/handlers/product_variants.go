@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"belcamp/internal/domain/entity"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The variants tab posts one row per variant. Every row posts its key in
+// variant_key, the variant ID or "n..." for new rows, and its inputs as
+// variant.<key>.<field>, e.g. variant.12.sku.
+
+// variantRow is a variant as edited in the variants tab
+type variantRow struct {
+	Key     string
+	Variant entity.ProductVariant
+	Remove  bool
+}
+
+// RegisterVariantRoutes registers the variant rows of the variants tab
+func (h *ProductHandler) RegisterVariantRoutes(r *gin.RouterGroup, path string) {
+	group := r.Group(path + "/:id/variants")
+	group.GET("", h.VariantRows)
+	group.GET("/row", h.VariantRow)
+	group.POST("/matrix", h.VariantMatrix)
+}
+
+// VariantRows renders the saved variants of the product, discarding the
+// unsaved changes of the tab
+func (h *ProductHandler) VariantRows(c *gin.Context) {
+	variants, err := h.variants.List(c.Request.Context(), convertToUint(c.Param("id")))
+	if err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	rows := make([]variantRow, len(variants))
+	for i, v := range variants {
+		rows[i] = variantRow{Key: strconv.FormatUint(uint64(v.ID), 10), Variant: v}
+	}
+	h.renderVariantRows(c, rows)
+}
+
+// VariantRow renders an empty row for a new variant
+func (h *ProductHandler) VariantRow(c *gin.Context) {
+	h.renderVariantRows(c, []variantRow{{Key: newVariantKey(0), Variant: entity.ProductVariant{Status: true}}})
+}
+
+// VariantMatrix renders a new row for every combination of the chosen sizes
+// and colors that the tab does not list yet
+func (h *ProductHandler) VariantMatrix(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	product := &entity.Product{}
+	if id := convertToUint(c.Param("id")); id != 0 {
+		found, err := h.service.Get(ctx, id)
+		if err != nil {
+			h.RenderError(c, http.StatusNotFound, "Product not found")
+			return
+		}
+		product = found
+	}
+	if slug := strings.TrimSpace(c.PostForm("slug")); slug != "" {
+		product.Slug = slug
+	}
+
+	colors, err := h.variants.Colors(ctx)
+	if err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var chosen []entity.Color
+	for _, name := range c.PostFormArray("matrix_colors") {
+		for _, color := range colors {
+			if color.Name == name {
+				chosen = append(chosen, color)
+			}
+		}
+	}
+
+	var sizes []string
+	for _, size := range strings.Split(c.PostForm("matrix_sizes"), ",") {
+		if size = strings.TrimSpace(size); size != "" {
+			sizes = append(sizes, size)
+		}
+	}
+
+	// Combinations already in the tab, saved or not, are skipped
+	var current []entity.ProductVariant
+	for _, key := range c.PostFormArray("variant_key") {
+		if c.PostForm(variantInput(key, "remove")) == "true" {
+			continue
+		}
+		v := entity.ProductVariant{SKU: strings.ToUpper(strings.TrimSpace(c.PostForm(variantInput(key, "sku"))))}
+		if size := strings.TrimSpace(c.PostForm(variantInput(key, "size"))); size != "" {
+			v.Size = &size
+		}
+		v.Colors = &entity.JSONColors{{"name": c.PostForm(variantInput(key, "color"))}}
+		current = append(current, v)
+	}
+
+	variants, err := h.variants.Matrix(ctx, product, sizes, chosen, c.PostForm("matrix_prefix"), current)
+	if err != nil {
+		h.Toast(c, err.Error(), "error")
+		c.Status(http.StatusOK)
+		return
+	}
+	if len(variants) == 0 {
+		h.Toast(c, "Every combination already has a variant", "info")
+		c.Status(http.StatusOK)
+		return
+	}
+
+	rows := make([]variantRow, len(variants))
+	for i, v := range variants {
+		rows[i] = variantRow{Key: newVariantKey(i), Variant: v}
+	}
+	h.Toast(c, fmt.Sprintf("%d variant(s) added, save the product to keep them", len(rows)), "success")
+	h.renderVariantRows(c, rows)
+}
+
+// renderVariantRows renders rows of the variants tab
+func (h *ProductHandler) renderVariantRows(c *gin.Context, rows []variantRow) {
+	colors, err := h.variants.Colors(c.Request.Context())
+	if err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.HTML(http.StatusOK, "products.variant-rows", gin.H{"rows": rows, "colors": colors})
+}
+
+// bindVariants returns the posted variants of the product and the IDs of
+// the variants to delete. Forms without the variants tab keep the saved
+// variants.
+func (h *ProductHandler) bindVariants(c *gin.Context, product *entity.Product) ([]entity.ProductVariant, []uint, error) {
+	ctx := c.Request.Context()
+
+	var saved []entity.ProductVariant
+	if product.ID != 0 {
+		var err error
+		if saved, err = h.variants.List(ctx, product.ID); err != nil {
+			return nil, nil, err
+		}
+	}
+	if c.PostForm("variants") != "true" {
+		return saved, nil, nil
+	}
+
+	byID := make(map[uint]entity.ProductVariant, len(saved))
+	for _, v := range saved {
+		byID[v.ID] = v
+	}
+	colors, err := h.variants.Colors(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rows []variantRow
+	var variants []entity.ProductVariant
+	var removed []uint
+	var problems []string
+	for _, key := range c.PostFormArray("variant_key") {
+		row := variantRow{Key: key, Remove: c.PostForm(variantInput(key, "remove")) == "true"}
+
+		if id := convertToUint(c.PostForm(variantInput(key, "id"))); id != 0 {
+			v, ok := byID[id]
+			if !ok {
+				return nil, nil, fmt.Errorf("variant %d does not belong to this product", id)
+			}
+			row.Variant = v
+		}
+
+		if err := bindVariant(c, key, &row.Variant, colors); err != nil {
+			problems = append(problems, err.Error())
+		}
+		rows = append(rows, row)
+
+		switch {
+		case row.Remove && row.Variant.ID != 0:
+			removed = append(removed, row.Variant.ID)
+		case !row.Remove:
+			variants = append(variants, row.Variant)
+		}
+	}
+
+	// Posted rows are shown as typed when the product cannot be saved
+	c.Set("variantRows", rows)
+
+	if len(problems) > 0 {
+		return nil, nil, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return variants, removed, nil
+}
+
+// bindVariant sets the posted fields of a row on the variant
+func bindVariant(c *gin.Context, key string, v *entity.ProductVariant, colors []entity.Color) error {
+	input := func(field string) string {
+		return strings.TrimSpace(c.PostForm(variantInput(key, field)))
+	}
+
+	v.SKU = strings.ToUpper(input("sku"))
+	v.Status = input("status") == "true"
+
+	v.Size = nil
+	if size := input("size"); size != "" {
+		v.Size = &size
+	}
+
+	if name := input("color"); name != v.ColorName() {
+		var color *entity.Color
+		for i := range colors {
+			if colors[i].Name == name {
+				color = &colors[i]
+			}
+		}
+		if color == nil && name != "" {
+			return fmt.Errorf("%s: unknown color %s", v.SKU, name)
+		}
+		v.SetColor(color)
+	}
+
+	// Availability is not edited here, only adjusted or counted in the stock
+	threshold := input("threshold")
+	if threshold == "" {
+		threshold = "0"
+	}
+	var err error
+	if v.LowStockThreshold, err = strconv.Atoi(threshold); err != nil {
+		return fmt.Errorf("%s: invalid low-stock threshold %q", v.SKU, threshold)
+	}
+
+	return nil
+}
+
+// variantsData adds the variant rows, the colors and the sizes of the
+// product to the form
+func (h *ProductHandler) variantsData(c *gin.Context, product *entity.Product, data gin.H) error {
+	ctx := c.Request.Context()
+
+	var rows []variantRow
+	if posted, ok := c.Get("variantRows"); ok {
+		rows = posted.([]variantRow)
+	} else if product.ID != 0 {
+		variants, err := h.variants.List(ctx, product.ID)
+		if err != nil {
+			return err
+		}
+		for _, v := range variants {
+			rows = append(rows, variantRow{Key: strconv.FormatUint(uint64(v.ID), 10), Variant: v})
+		}
+	}
+
+	colors, err := h.variants.Colors(ctx)
+	if err != nil {
+		return err
+	}
+	sizes, _ := product.GetSizes()
+
+	data["variants"] = gin.H{
+		"rows":   rows,
+		"colors": colors,
+		"sizes":  strings.Join(sizes, ", "),
+		"url":    fmt.Sprintf("%s/%d/variants", data["listUrl"], product.ID),
+	}
+	return nil
+}
+
+// variantInput returns the name of an input of a variant row
+func variantInput(key, field string) string {
+	return "variant." + key + "." + field
+}
+
+// newVariantKey returns a key for an unsaved row, unique within the page
+func newVariantKey(i int) string {
+	return fmt.Sprintf("n%d", time.Now().UnixNano()+int64(i))
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"belcamp/internal/registry"
+	"belcamp/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Results per resource in the header search and on the search page
+const (
+	searchPaletteLimit = 5
+	searchPageLimit    = 20
+)
+
+// SearchHandler serves the global search of the header
+type SearchHandler struct {
+	BaseHandler
+	search service.SearchService
+}
+
+// NewSearchHandler creates a handler searching the resources of the service
+func NewSearchHandler(search service.SearchService) *SearchHandler {
+	return &SearchHandler{search: search}
+}
+
+// searchHit is a result linked to its page
+type searchHit struct {
+	Title    string
+	Subtitle string
+	ID       uint
+	URL      string
+}
+
+// searchGroup holds the hits of one resource
+type searchGroup struct {
+	Resource registry.Resource
+	Hits     []searchHit
+}
+
+// Search searches the resources visible to the user. HTMX requests from the
+// header get the few best results of each resource, other requests the
+// search page.
+func (h *SearchHandler) Search(c *gin.Context) {
+	term := strings.TrimSpace(c.Query("q"))
+	limit := searchPageLimit
+	if c.GetHeader("HX-Request") == "true" {
+		limit = searchPaletteLimit
+	}
+
+	var groups []searchGroup
+	if len([]rune(term)) >= 2 {
+		visible := registry.Visible(c)
+		sources := make([]string, len(visible))
+		for i, r := range visible {
+			sources[i] = r.Name
+		}
+
+		found, err := h.search.Search(c.Request.Context(), term, limit, sources)
+		if err != nil {
+			h.RenderError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		for _, group := range found {
+			resource, _ := registry.Get(group.Source)
+			hits := make([]searchHit, len(group.Results))
+			for i, result := range group.Results {
+				hits[i] = searchHit{
+					Title:    result.Title,
+					Subtitle: result.Subtitle,
+					ID:       result.ID,
+					URL:      fmt.Sprintf("%s/%d", resource.Path, result.ID),
+				}
+			}
+			groups = append(groups, searchGroup{Resource: resource, Hits: hits})
+		}
+	}
+
+	h.Render(c, "search.index", gin.H{
+		"title":  "Search",
+		"q":      term,
+		"groups": groups,
+	}, "search.results")
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"belcamp/internal/convert"
+	"belcamp/internal/domain/entity"
+	"belcamp/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The shipment page posts one row per line. Every row posts its key in
+// line_key, the line ID or "n..." for new rows, and its inputs as
+// line.<key>.<field>, e.g. line.12.sku. Quantities received are posted
+// separately as receive[<line ID>].
+
+// shipmentLineRow is a line as edited in the shipment page
+type shipmentLineRow struct {
+	Key    string
+	Line   entity.ShipmentLine
+	Remove bool
+}
+
+// ShipmentHandler extends the generic CRUD handler with the lines of the
+// shipments and their receipt
+type ShipmentHandler struct {
+	*CRUDHandler[entity.Shipment]
+	shipments service.ShipmentService
+}
+
+// NewShipmentHandler creates a handler saving shipments with their lines
+func NewShipmentHandler(svc *service.CRUDService[entity.Shipment], shipments service.ShipmentService, tmpl string) *ShipmentHandler {
+	h := &ShipmentHandler{
+		CRUDHandler: NewCRUDHandler(svc, tmpl),
+		shipments:   shipments,
+	}
+
+	// Shipments are saved and deleted with their lines, which set the next
+	// arrival of the variants
+	h.Override(ActionCreate, h.Create)
+	h.Override(ActionUpdate, h.Update)
+	h.Override(ActionDelete, h.Delete)
+	h.ExtendForm(h.linesData)
+
+	return h
+}
+
+// RegisterShipmentRoutes registers the line rows and the receipt of the
+// shipments
+func (h *ShipmentHandler) RegisterShipmentRoutes(r *gin.RouterGroup, path string) {
+	group := r.Group(path + "/:id")
+	group.GET("/lines/row", h.LineRow)
+	group.POST("/receive", h.Receive)
+}
+
+// Create creates a shipment with its lines
+func (h *ShipmentHandler) Create(c *gin.Context) {
+	h.save(c, &entity.Shipment{}, true)
+}
+
+// Update updates a shipment and its lines
+func (h *ShipmentHandler) Update(c *gin.Context) {
+	shipment, err := h.service.Get(c.Request.Context(), convertToUint(c.Param("id")))
+	if err != nil {
+		h.RenderError(c, http.StatusNotFound, "Shipment not found")
+		return
+	}
+
+	h.save(c, shipment, false)
+}
+
+// Delete deletes a shipment and removes its pending lines from the next
+// arrivals
+func (h *ShipmentHandler) Delete(c *gin.Context) {
+	if err := h.shipments.Delete(c.Request.Context(), convertToUint(c.Param("id"))); err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// The SmartTable removes the row itself
+	if c.GetHeader("HX-Request") == "true" {
+		h.Toast(c, "Deleted", "success")
+		c.Status(http.StatusOK)
+		return
+	}
+	h.Redirect(c, h.listPath(c))
+}
+
+// LineRow renders an empty row for a new line
+func (h *ShipmentHandler) LineRow(c *gin.Context) {
+	c.HTML(http.StatusOK, "shipments.line-rows", gin.H{
+		"rows": []shipmentLineRow{{Key: newVariantKey(0)}},
+	})
+}
+
+// Receive adds the posted quantities received to the stock and reopens the
+// shipment page
+func (h *ShipmentHandler) Receive(c *gin.Context) {
+	id := convertToUint(c.Param("id"))
+
+	quantities := map[uint]int{}
+	var problems []string
+	for lineID, value := range c.PostFormMap("receive") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("invalid quantity %q", value))
+			continue
+		}
+		quantities[convertToUint(lineID)] = n
+	}
+
+	var err error
+	if len(problems) > 0 {
+		err = fmt.Errorf("%s", strings.Join(problems, "; "))
+	} else {
+		err = h.shipments.Receive(c.Request.Context(), id, quantities)
+	}
+	if err != nil {
+		// HTMX ignores error responses, so report through a toast instead
+		h.Toast(c, err.Error(), "error")
+		c.Status(http.StatusNoContent)
+		return
+	}
+	// The request path is <list>/<id>/receive
+	h.Redirect(c, strings.TrimSuffix(c.Request.URL.Path, "/receive")+"/edit")
+}
+
+// save binds the posted form and lines, then stores the shipment with its
+// lines
+func (h *ShipmentHandler) save(c *gin.Context, shipment *entity.Shipment, isNew bool) {
+	// Lines are bound first so they keep what was typed when the form fails
+	lines, removed, linesErr := h.bindLines(c, shipment)
+	if errs := h.bindForm(c, shipment); len(errs) > 0 {
+		h.renderForm(c, shipment, isNew, errs)
+		return
+	}
+	if linesErr != nil {
+		h.renderForm(c, shipment, isNew, map[string]string{"": linesErr.Error()})
+		return
+	}
+
+	if err := h.shipments.Save(c.Request.Context(), shipment, isNew, lines, removed); err != nil {
+		h.renderForm(c, shipment, isNew, map[string]string{"": err.Error()})
+		return
+	}
+	h.Redirect(c, h.listPath(c))
+}
+
+// bindLines returns the posted lines of the shipment, with their variants
+// found by SKU, and the IDs of the lines to delete
+func (h *ShipmentHandler) bindLines(c *gin.Context, shipment *entity.Shipment) ([]entity.ShipmentLine, []uint, error) {
+	ctx := c.Request.Context()
+
+	byID := map[uint]entity.ShipmentLine{}
+	if shipment.ID != 0 {
+		saved, err := h.shipments.Lines(ctx, shipment.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, line := range saved {
+			byID[line.ID] = line
+		}
+	}
+
+	var rows []shipmentLineRow
+	var lines []entity.ShipmentLine
+	var removed []uint
+	var problems []string
+	for _, key := range c.PostFormArray("line_key") {
+		input := func(field string) string {
+			return strings.TrimSpace(c.PostForm(lineInput(key, field)))
+		}
+		row := shipmentLineRow{Key: key, Remove: input("remove") == "true"}
+
+		if id := convertToUint(input("id")); id != 0 {
+			line, ok := byID[id]
+			if !ok {
+				return nil, nil, fmt.Errorf("line %d does not belong to this shipment", id)
+			}
+			row.Line = line
+		}
+
+		if !row.Remove {
+			if err := h.bindLine(c, key, &row.Line); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+		rows = append(rows, row)
+
+		switch {
+		case row.Remove && row.Line.ID != 0:
+			removed = append(removed, row.Line.ID)
+		case !row.Remove:
+			lines = append(lines, row.Line)
+		}
+	}
+
+	// Posted rows are shown as typed when the shipment cannot be saved
+	c.Set("shipmentLines", rows)
+
+	if len(problems) > 0 {
+		return nil, nil, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return lines, removed, nil
+}
+
+// bindLine sets the posted fields of a row on the line
+func (h *ShipmentHandler) bindLine(c *gin.Context, key string, line *entity.ShipmentLine) error {
+	input := func(field string) string {
+		return strings.TrimSpace(c.PostForm(lineInput(key, field)))
+	}
+
+	sku := strings.ToUpper(input("sku"))
+	if sku != line.ProductVariant.SKU {
+		line.ProductVariantID = 0
+		line.ProductVariant = entity.ProductVariant{SKU: sku}
+		if sku == "" {
+			return fmt.Errorf("a line has no SKU")
+		}
+		variant, err := h.shipments.FindVariant(c.Request.Context(), sku)
+		if err != nil {
+			return err
+		}
+		line.ProductVariantID = variant.ID
+		line.ProductVariant = *variant
+	}
+
+	quantity := input("quantity")
+	n, err := strconv.Atoi(quantity)
+	if err != nil {
+		return fmt.Errorf("%s: invalid quantity %q", sku, quantity)
+	}
+	line.Quantity = n
+
+	line.ExpectedAt = nil
+	if date := input("expected"); date != "" {
+		t, err := convert.ParseTime(date)
+		if err != nil {
+			return fmt.Errorf("%s: invalid expected date %q", sku, date)
+		}
+		line.ExpectedAt = &t
+	}
+	return nil
+}
+
+// linesData adds the line rows of the shipment to the form
+func (h *ShipmentHandler) linesData(c *gin.Context, shipment *entity.Shipment, data gin.H) error {
+	var rows []shipmentLineRow
+	if posted, ok := c.Get("shipmentLines"); ok {
+		rows = posted.([]shipmentLineRow)
+	} else if shipment.ID != 0 {
+		lines, err := h.shipments.Lines(c.Request.Context(), shipment.ID)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			rows = append(rows, shipmentLineRow{Key: strconv.FormatUint(uint64(line.ID), 10), Line: line})
+		}
+	}
+
+	pending := 0
+	for _, row := range rows {
+		if row.Line.ID != 0 {
+			pending += row.Line.Pending()
+		}
+	}
+
+	data["lines"] = gin.H{
+		"rows":    rows,
+		"pending": pending,
+		"url":     fmt.Sprintf("%s/%d", data["listUrl"], shipment.ID),
+	}
+	return nil
+}
+
+// lineInput returns the name of an input of a line row
+func lineInput(key, field string) string {
+	return "line." + key + "." + field
+}
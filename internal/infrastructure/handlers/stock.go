@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"belcamp/internal/domain/entity"
+	"belcamp/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StockHandler serves the low-stock alerts, the stock history of variants
+// and the stock movements of orders
+type StockHandler struct {
+	BaseHandler
+	stock     service.StockService
+	shipments service.ShipmentService
+}
+
+// NewStockHandler creates a handler moving stock through the service, showing
+// the incoming shipments of the variants
+func NewStockHandler(stock service.StockService, shipments service.ShipmentService) *StockHandler {
+	return &StockHandler{stock: stock, shipments: shipments}
+}
+
+// RegisterRoutes registers the stock pages and actions under path
+func (h *StockHandler) RegisterRoutes(r *gin.RouterGroup, path string) {
+	group := r.Group(path)
+	group.GET("", h.Index)
+	group.POST("/reconcile", h.Reconcile)
+
+	variants := group.Group("/variants/:id")
+	variants.GET("", h.History)
+	variants.POST("/adjust", h.Adjust)
+	variants.POST("/threshold", h.Threshold)
+
+	orders := group.Group("/orders/:id")
+	orders.POST("/reserve", h.ReserveOrder)
+	orders.POST("/cancel", h.CancelOrder)
+}
+
+// Index renders the variants low on stock and those whose availability has
+// drifted from the ledger
+func (h *StockHandler) Index(c *gin.Context) {
+	data, err := h.alerts(c)
+	if err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	data["title"] = "Stock"
+	h.Render(c, "stock.index", data, "stock.alerts")
+}
+
+// Reconcile resets drifted variants to the balance of their ledger
+func (h *StockHandler) Reconcile(c *gin.Context) {
+	count, err := h.stock.Reconcile(c.Request.Context())
+	if err != nil {
+		h.Toast(c, err.Error(), "error")
+	} else {
+		h.Toast(c, fmt.Sprintf("%d variant(s) reconciled with the ledger", count), "success")
+	}
+
+	data, err := h.alerts(c)
+	if err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.HTML(http.StatusOK, "stock.alerts", data)
+}
+
+// History renders the stock movements of a variant with the forms moving
+// its stock
+func (h *StockHandler) History(c *gin.Context) {
+	data, ok := h.history(c)
+	if !ok {
+		return
+	}
+	data["title"] = "Stock of " + data["variant"].(*entity.ProductVariant).SKU
+	h.Render(c, "stock.history", data, "stock.variant")
+}
+
+// Adjust adds or removes stock, or sets the counted stock when mode is
+// "count"
+func (h *StockHandler) Adjust(c *gin.Context) {
+	id := convertToUint(c.Param("id"))
+	quantity, err := strconv.Atoi(strings.TrimSpace(c.PostForm("quantity")))
+	if err != nil {
+		h.Toast(c, "Invalid quantity", "error")
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	reason := strings.TrimSpace(c.PostForm("reason"))
+	if c.PostForm("mode") == "count" {
+		err = h.stock.Count(c.Request.Context(), id, quantity, reason)
+	} else {
+		err = h.stock.Adjust(c.Request.Context(), id, quantity, reason)
+	}
+	h.moved(c, err, "Stock updated")
+}
+
+// Threshold sets the low-stock threshold of the variant
+func (h *StockHandler) Threshold(c *gin.Context) {
+	threshold, err := strconv.Atoi(strings.TrimSpace(c.PostForm("threshold")))
+	if err == nil {
+		err = h.stock.SetThreshold(c.Request.Context(), convertToUint(c.Param("id")), threshold)
+	}
+	h.moved(c, err, "Low-stock threshold saved")
+}
+
+// ReserveOrder removes the items of the order from stock
+func (h *StockHandler) ReserveOrder(c *gin.Context) {
+	h.orderMoved(c, h.stock.ReserveOrder(c.Request.Context(), convertToUint(c.Param("id"))), "Stock reserved")
+}
+
+// CancelOrder returns the stock reserved by the order
+func (h *StockHandler) CancelOrder(c *gin.Context) {
+	h.orderMoved(c, h.stock.CancelOrder(c.Request.Context(), convertToUint(c.Param("id"))), "Stock returned")
+}
+
+// moved reports a stock action on a variant and renders its updated history
+func (h *StockHandler) moved(c *gin.Context, err error, message string) {
+	if err != nil {
+		// HTMX ignores error responses, so report through a toast instead
+		h.Toast(c, err.Error(), "error")
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	data, ok := h.history(c)
+	if !ok {
+		return
+	}
+	h.Toast(c, message, "success")
+	c.HTML(http.StatusOK, "stock.variant", data)
+}
+
+// orderMoved reports a stock action on an order as a toast, or as JSON for
+// requests outside the admin pages
+func (h *StockHandler) orderMoved(c *gin.Context, err error, message string) {
+	if c.GetHeader("HX-Request") == "true" {
+		if err != nil {
+			h.Toast(c, err.Error(), "error")
+		} else {
+			h.Toast(c, message, "success", "smartTableRefresh")
+		}
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// history loads the variant of the request with its movements and incoming
+// shipments
+func (h *StockHandler) history(c *gin.Context) (gin.H, bool) {
+	variant, movements, err := h.stock.History(c.Request.Context(), convertToUint(c.Param("id")))
+	if err != nil {
+		h.RenderError(c, http.StatusNotFound, "Variant not found")
+		return nil, false
+	}
+	arrivals, err := h.shipments.Arrivals(c.Request.Context(), variant.ID)
+	if err != nil {
+		h.RenderError(c, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	return gin.H{
+		"variant":   variant,
+		"movements": movements,
+		"arrivals":  arrivals,
+	}, true
+}
+
+// alerts loads the low-stock variants and the drifted ones
+func (h *StockHandler) alerts(c *gin.Context) (gin.H, error) {
+	low, err := h.stock.LowStock(c.Request.Context())
+	if err != nil {
+		return nil, err
+	}
+	drifts, err := h.stock.Drifts(c.Request.Context())
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{"lowStock": low, "drifts": drifts}, nil
+}
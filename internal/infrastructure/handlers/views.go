@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"belcamp/internal/domain/entity"
+	"belcamp/internal/domain/valueobject"
+	"belcamp/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EnableViews lets users save named SmartTable views of the resource. It
+// must be called before the routes are registered.
+func (h *CRUDHandler[T]) EnableViews(views service.ViewService) {
+	h.views = views
+}
+
+// RegisterViewRoutes registers saving and deleting the views of the table
+func (h *CRUDHandler[T]) RegisterViewRoutes(r *gin.RouterGroup, path string) {
+	if h.views == nil {
+		return
+	}
+
+	group := r.Group(path + "/views")
+	group.POST("", h.SaveView)
+	group.DELETE("/:view", h.DeleteView)
+}
+
+// SaveView saves the current query of the table as a named view of the user
+func (h *CRUDHandler[T]) SaveView(c *gin.Context) {
+	view := &entity.SavedView{
+		UserID:    currentUserID(c),
+		Resource:  h.tmpl,
+		Name:      c.PostForm("view_name"),
+		Query:     viewQuery(c.PostForm("view_query")),
+		IsDefault: c.PostForm("view_default") == "true",
+	}
+
+	if err := h.views.Save(c.Request.Context(), view); err != nil {
+		h.Toast(c, err.Error(), "error")
+		c.Status(http.StatusOK)
+		return
+	}
+
+	h.Toast(c, "View "+view.Name+" saved", "success", "smartTableRefresh")
+	c.Status(http.StatusOK)
+}
+
+// DeleteView deletes a view of the user
+func (h *CRUDHandler[T]) DeleteView(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("view"), 10, 32)
+	if err != nil {
+		h.RenderError(c, http.StatusBadRequest, "Invalid view")
+		return
+	}
+
+	if err := h.views.Delete(c.Request.Context(), currentUserID(c), uint(id)); err != nil {
+		h.Toast(c, "The view could not be deleted", "error")
+		c.Status(http.StatusOK)
+		return
+	}
+
+	h.Toast(c, "View deleted", "success", "smartTableRefresh")
+	c.Status(http.StatusOK)
+}
+
+// applyDefaultView opens the default view of the user when the table is
+// requested without a query, and returns the saved views of the user
+func (h *CRUDHandler[T]) applyDefaultView(c *gin.Context) ([]entity.SavedView, error) {
+	if h.views == nil {
+		return nil, nil
+	}
+
+	ctx := c.Request.Context()
+	userID := currentUserID(c)
+	if c.Request.URL.RawQuery == "" {
+		view, err := h.views.Default(ctx, userID, h.tmpl)
+		if err != nil {
+			return nil, err
+		}
+		if view != nil {
+			c.Request.URL.RawQuery = view.Query
+		}
+	}
+
+	return h.views.List(ctx, userID, h.tmpl)
+}
+
+// withColumns shows the columns listed in the "columns" query parameter, in
+// that order, and hides the others. The config is unchanged without one.
+func withColumns(config valueobject.SmartTableConfig, param string) valueobject.SmartTableConfig {
+	if param == "" {
+		return config
+	}
+
+	var shown, hidden []valueobject.SmartTableColumn
+	for _, field := range strings.Split(param, ",") {
+		for _, column := range config.Columns {
+			if column.Field == field {
+				column.Visible = true
+				shown = append(shown, column)
+			}
+		}
+	}
+	for _, column := range config.Columns {
+		if !strings.Contains(","+param+",", ","+column.Field+",") {
+			column.Visible = false
+			hidden = append(hidden, column)
+		}
+	}
+
+	// Hiding every column would leave an empty table
+	if len(shown) == 0 {
+		return config
+	}
+
+	config.Columns = append(shown, hidden...)
+	return config
+}
+
+// columnChoices returns the columns of the table as JSON for the column
+// chooser: field, label and whether the column is shown
+func columnChoices(config valueobject.SmartTableConfig) string {
+	type choice struct {
+		Field string `json:"field"`
+		Label string `json:"label"`
+		On    bool   `json:"on"`
+	}
+
+	choices := make([]choice, len(config.Columns))
+	for i, column := range config.Columns {
+		choices[i] = choice{Field: column.Field, Label: column.Label, On: column.Visible}
+	}
+	data, _ := json.Marshal(choices)
+	return string(data)
+}
+
+// viewQuery keeps the parameters of a view from a table query, leaving out
+// the position in the list
+func viewQuery(rawQuery string) string {
+	query, _ := url.ParseQuery(rawQuery)
+	query.Del("page")
+	query.Del("after")
+	query.Del("before")
+	return query.Encode()
+}
+
+// currentUserID returns the ID of the signed in user
+func currentUserID(c *gin.Context) uint {
+	value, _ := c.Get("userID")
+	switch id := value.(type) {
+	case uint:
+		return id
+	case int:
+		return uint(id)
+	case int64:
+		return uint(id)
+	case uint64:
+		return uint(id)
+	}
+	return 0
+}
@@ -0,0 +1,200 @@
+package persistence
+
+import (
+	"belcamp/internal/convert"
+	"belcamp/internal/domain/interfaces"
+	"belcamp/internal/domain/valueobject"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// filtered restricts a query to the IDs and filters of the list query.
+// Filters on fields that are not columns are ignored. Filters on a tree
+// table, such as the category of the products, also match the descendants
+// of the chosen values.
+func (r *GormRepository[T]) filtered(query valueobject.ListQuery) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		sch, err := r.schema()
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+
+		if query.IDs != nil {
+			pk := sch.PrioritizedPrimaryField
+			db = db.Where(clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: pk.DBName}, Values: toValues(query.IDs)})
+		}
+
+		trees := treeSources[T]()
+		for key, value := range query.Filters {
+			value = strings.TrimSpace(value)
+			name, operator := valueobject.ParseFilterKey(key)
+			field := sch.LookUpField(name)
+			if value == "" || field == nil || field.DBName == "" {
+				continue
+			}
+			if source := trees[name]; source != nil && (operator == "" || operator == valueobject.FilterIn) {
+				condition, err := r.subtreeCondition(field, source, value)
+				if err != nil {
+					db.AddError(err)
+					return db
+				}
+				if condition != nil {
+					db = db.Where(condition)
+				}
+				continue
+			}
+			if condition := filterCondition(field, operator, value); condition != nil {
+				db = db.Where(condition)
+			}
+		}
+
+		return db
+	}
+}
+
+// treeSources returns the option sources of the filters of T on tree
+// tables, by filtered field
+func treeSources[T any]() map[string]*valueobject.OptionSource {
+	var zero T
+	provider, ok := any(zero).(interfaces.SmartTableProvider)
+	if !ok {
+		return nil
+	}
+
+	sources := map[string]*valueobject.OptionSource{}
+	for _, column := range provider.GetSmartTableConfig().Columns {
+		if column.FilterSource != nil && column.FilterSource.Parent != "" {
+			sources[column.FilterKey()] = column.FilterSource
+		}
+	}
+	return sources
+}
+
+// subtreeCondition matches the values of a tree table and all their
+// descendants, e.g. a category and its subcategories. The recursive UNION
+// skips the rows already reached, so a loop of parents ends.
+func (r *GormRepository[T]) subtreeCondition(field *schema.Field, source *valueobject.OptionSource, value string) (clause.Expression, error) {
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(source.Model); err != nil {
+		return nil, err
+	}
+	valueColumn := source.Value
+	if valueColumn == "" {
+		valueColumn = "id"
+	}
+
+	var values []any
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	return clause.Expr{
+		SQL: "? IN (WITH RECURSIVE subtree AS (SELECT ? FROM ? WHERE ? IN ? " +
+			"UNION SELECT ? FROM ? JOIN subtree ON ? = ?) SELECT ? FROM subtree)",
+		Vars: []any{
+			clause.Column{Table: clause.CurrentTable, Name: field.DBName},
+			clause.Column{Name: valueColumn}, clause.Table{Name: stmt.Schema.Table}, clause.Column{Name: valueColumn}, values,
+			clause.Column{Table: "child", Name: valueColumn}, clause.Table{Name: stmt.Schema.Table, Alias: "child"},
+			clause.Column{Table: "child", Name: source.Parent}, clause.Column{Table: "subtree", Name: valueColumn},
+			clause.Column{Name: valueColumn},
+		},
+	}, nil
+}
+
+// filterCondition translates a filter into SQL. Without an operator text
+// columns match partially and everything else exactly. Values that cannot
+// be read for the column, such as "abc" in a date range, are ignored.
+func filterCondition(field *schema.Field, operator, value string) clause.Expression {
+	col := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+
+	switch operator {
+	case valueobject.FilterFrom, valueobject.FilterTo:
+		bound, ok := rangeBound(field, value)
+		if !ok {
+			return nil
+		}
+		if operator == valueobject.FilterFrom {
+			return clause.Gte{Column: col, Value: bound}
+		}
+		if t, isTime := bound.(time.Time); isTime && len(value) == len("2006-01-02") {
+			// A date includes the whole day
+			return clause.Lt{Column: col, Value: t.AddDate(0, 0, 1)}
+		}
+		return clause.Lte{Column: col, Value: bound}
+	case valueobject.FilterIn:
+		var values []any
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, exactValue(field, item))
+			}
+		}
+		if len(values) == 0 {
+			return nil
+		}
+		return clause.IN{Column: col, Values: values}
+	case valueobject.FilterNull:
+		empty := clause.Expr{SQL: "? IS NULL", Vars: []any{col}}
+		if field.DataType == schema.String {
+			empty = clause.Expr{SQL: "(? IS NULL OR ? = '')", Vars: []any{col, col}}
+		}
+		switch value {
+		case "unset":
+			return empty
+		case "set":
+			return clause.Not(empty)
+		}
+		return nil
+	case "":
+		if field.DataType == schema.String {
+			return clause.Like{Column: col, Value: "%" + escapeLike(value) + "%"}
+		}
+		return clause.Eq{Column: col, Value: exactValue(field, value)}
+	}
+	return nil
+}
+
+// exactValue converts a filter value for comparison with the column
+func exactValue(field *schema.Field, value string) any {
+	if field.DataType == schema.Bool {
+		b, _ := strconv.ParseBool(value)
+		return b
+	}
+	return value
+}
+
+// rangeBound reads the bound of a date or numeric range
+func rangeBound(field *schema.Field, value string) (any, bool) {
+	switch field.DataType {
+	case schema.Time:
+		t, err := convert.ParseTime(value)
+		return t, err == nil
+	case schema.Int, schema.Uint, schema.Float:
+		f, err := strconv.ParseFloat(value, 64)
+		return f, err == nil
+	}
+	return value, true
+}
+
+// escapeLike escapes the LIKE wildcards in a user supplied value
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+func toValues(ids []uint) []any {
+	values := make([]any, len(ids))
+	for i, id := range ids {
+		values[i] = id
+	}
+	return values
+}
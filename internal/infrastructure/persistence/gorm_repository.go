@@ -1,11 +1,21 @@
 package persistence
 
 import (
+	"belcamp/internal/database"
+	"belcamp/internal/domain/interfaces"
 	"belcamp/internal/domain/repository"
+	"belcamp/internal/domain/valueobject"
 	"belcamp/internal/infrastructure/errors"
+	"belcamp/internal/slug"
 	"context"
+	"fmt"
+	"reflect"
+	"slices"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"gorm.io/plugin/dbresolver"
 )
 
 type GormRepository[T any] struct {
@@ -20,6 +30,15 @@ func (r *GormRepository[T]) Create(ctx context.Context, entity *T) error {
 	return r.db.WithContext(ctx).Create(entity).Error
 }
 
+// reader returns a session for the reads of the lists, which may lag behind
+// a little. Outside a transaction they go to a read replica when replicas
+// are configured; every other query uses the primary.
+func (r *GormRepository[T]) reader(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Clauses(dbresolver.Use(database.ReplicaResolver), dbresolver.Read)
+}
+
+// FindByID returns the entity from the primary, as it is often saved back
+// or shown right after a change
 func (r *GormRepository[T]) FindByID(ctx context.Context, id uint) (*T, error) {
 	var entity T
 	if err := r.db.WithContext(ctx).First(&entity, id).Error; err != nil {
@@ -35,30 +54,255 @@ func (r *GormRepository[T]) Update(ctx context.Context, entity *T) error {
 	return r.db.WithContext(ctx).Save(entity).Error
 }
 
+// UpdateFields saves only the given fields of the entity, zero values included
+func (r *GormRepository[T]) UpdateFields(ctx context.Context, entity *T, fields []string) error {
+	return r.db.WithContext(ctx).Model(entity).Select(fields).Omit(clause.Associations).Updates(entity).Error
+}
+
 func (r *GormRepository[T]) Delete(ctx context.Context, id uint) error {
 	return r.db.WithContext(ctx).Delete(new(T), id).Error
 }
 
-func (r *GormRepository[T]) List(ctx context.Context, page, pageSize int, preloadFields ...string) ([]T, int64, error) {
+func (r *GormRepository[T]) List(ctx context.Context, query valueobject.ListQuery, page, pageSize int) ([]T, int64, error) {
 	var entities []T
 	var total int64
 
-	if err := r.db.WithContext(ctx).Model(new(T)).Count(&total).Error; err != nil {
+	if err := r.reader(ctx).Model(new(T)).Scopes(r.filtered(query)).Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
-	query := r.db.WithContext(ctx).
+	db := r.reader(ctx).
+		Scopes(r.filtered(query)).
 		Offset((page - 1) * pageSize).
 		Limit(pageSize)
 
+	// Sort in the database when the sort field is a column
+	if sortField, pk, err := r.sortFields(query.Sort); err == nil && sortField != nil {
+		db = db.Order(orderBy(sortField, pk, query.Descending()))
+	}
+
 	// Apply preloading to each specified field
-	for _, field := range preloadFields {
-		query = query.Preload(field)
+	for _, field := range query.Preload {
+		db = db.Preload(field)
 	}
 
-	if err := query.Find(&entities).Error; err != nil {
+	if err := db.Find(&entities).Error; err != nil {
 		return nil, 0, err
 	}
 
 	return entities, total, nil
 }
+
+// FindAll returns every entity matching the query
+func (r *GormRepository[T]) FindAll(ctx context.Context, query valueobject.ListQuery) ([]T, error) {
+	var entities []T
+
+	db := r.reader(ctx).Scopes(r.filtered(query))
+	if sortField, pk, err := r.sortFields(query.Sort); err == nil && sortField != nil {
+		db = db.Order(orderBy(sortField, pk, query.Descending()))
+	}
+	for _, field := range query.Preload {
+		db = db.Preload(field)
+	}
+
+	if err := db.Find(&entities).Error; err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
+
+// Count returns the number of entities matching the query
+func (r *GormRepository[T]) Count(ctx context.Context, query valueobject.ListQuery) (int64, error) {
+	var total int64
+	err := r.reader(ctx).Model(new(T)).Scopes(r.filtered(query)).Count(&total).Error
+	return total, err
+}
+
+// UpdateWhere sets the given columns on every entity matching the query
+func (r *GormRepository[T]) UpdateWhere(ctx context.Context, query valueobject.ListQuery, updates map[string]any) (int64, error) {
+	result := r.db.WithContext(ctx).Model(new(T)).Scopes(r.filtered(query)).Updates(updates)
+	return result.RowsAffected, result.Error
+}
+
+// DeleteWhere deletes every entity matching the query
+func (r *GormRepository[T]) DeleteWhere(ctx context.Context, query valueobject.ListQuery) (int64, error) {
+	result := r.db.WithContext(ctx).Scopes(r.filtered(query)).Delete(new(T))
+	return result.RowsAffected, result.Error
+}
+
+// Options loads select options from a related table. Options of tree tables
+// are listed depth first and labelled with their path, e.g. "Bags › Backpacks".
+func (r *GormRepository[T]) Options(ctx context.Context, source valueobject.OptionSource) ([]valueobject.FilterOption, error) {
+	valueColumn := source.Value
+	if valueColumn == "" {
+		valueColumn = "id"
+	}
+
+	var rows []optionRow
+	db := r.reader(ctx).Model(source.Model)
+	if source.Parent != "" {
+		db = db.Select("? AS value, ? AS label, ? AS parent",
+			clause.Column{Name: valueColumn}, clause.Column{Name: source.Label}, clause.Column{Name: source.Parent})
+	} else {
+		db = db.Select("? AS value, ? AS label", clause.Column{Name: valueColumn}, clause.Column{Name: source.Label})
+	}
+	err := db.Order(clause.OrderByColumn{Column: clause.Column{Name: source.Label}}).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if source.Parent != "" {
+		return treeOptions(rows), nil
+	}
+
+	options := make([]valueobject.FilterOption, len(rows))
+	for i, row := range rows {
+		options[i] = valueobject.FilterOption{Value: row.Value, Label: row.Label}
+	}
+	return options, nil
+}
+
+type optionRow struct {
+	Value  string
+	Label  string
+	Parent *string
+}
+
+// treeOptions orders the rows of a tree depth first, siblings by label, and
+// labels each option with the labels of its ancestors. Rows whose parent is
+// missing are treated as roots, and so is the first row of a loop of
+// parents, as in the category tree.
+func treeOptions(rows []optionRow) []valueobject.FilterOption {
+	known := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		known[row.Value] = true
+	}
+
+	children := make(map[string][]optionRow)
+	for _, row := range rows {
+		parent := ""
+		if row.Parent != nil && known[*row.Parent] && *row.Parent != row.Value {
+			parent = *row.Parent
+		}
+		children[parent] = append(children[parent], row)
+	}
+
+	options := make([]valueobject.FilterOption, 0, len(rows))
+	visited := make(map[string]bool, len(rows))
+	var walk func(parent, path string)
+	walk = func(parent, path string) {
+		for _, row := range children[parent] {
+			if visited[row.Value] {
+				continue
+			}
+			visited[row.Value] = true
+
+			label := row.Label
+			if path != "" {
+				label = path + " › " + row.Label
+			}
+			options = append(options, valueobject.FilterOption{Value: row.Value, Label: label})
+			walk(row.Value, label)
+		}
+	}
+	walk("", "")
+
+	// Rows never reached from a root are in a loop of parents
+	for _, row := range rows {
+		if !visited[row.Value] {
+			visited[row.Value] = true
+			options = append(options, valueobject.FilterOption{Value: row.Value, Label: row.Label})
+			walk(row.Value, row.Label)
+		}
+	}
+	return options
+}
+
+// Transaction runs fn with a repository bound to a transaction. Transactions
+// always run on the primary, replicas are never used inside them.
+func (r *GormRepository[T]) Transaction(ctx context.Context, fn func(repo repository.Repository[T]) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&GormRepository[T]{db: tx})
+	})
+}
+
+// HasColumn reports whether the struct field is backed by a column, so it
+// can be sorted on in the database
+func (r *GormRepository[T]) HasColumn(field string) bool {
+	sch, err := r.schema()
+	if err != nil {
+		return false
+	}
+	f := sch.LookUpField(field)
+	return f != nil && f.DBName != ""
+}
+
+// Upsert creates the entity, or updates the given fields of the entity with
+// the same key field value. It reports whether the entity was created.
+func (r *GormRepository[T]) Upsert(ctx context.Context, entity *T, key string, fields []string) (bool, error) {
+	sch, err := r.schema()
+	if err != nil {
+		return false, err
+	}
+
+	keyField := sch.LookUpField(key)
+	pk := sch.PrioritizedPrimaryField
+	if keyField == nil || keyField.DBName == "" || pk == nil {
+		return false, fmt.Errorf("%s cannot be matched by %s", sch.Name, key)
+	}
+
+	// Imported slugs are kept as typed, once made URL-safe, like the slugs
+	// typed in the form
+	if record, ok := any(entity).(interfaces.Sluggable); ok && slices.Contains(fields, "Slug") {
+		locked := slug.Make(record.GetSlug())
+		if locked == "" {
+			return false, &errors.DomainError{Code: errors.ErrValidation.Code, Message: "The slug cannot be empty"}
+		}
+		record.SetSlug(locked)
+		record.LockSlug()
+		fields = append(slices.Clip(fields), "SlugLocked")
+	}
+
+	rv := reflect.ValueOf(entity).Elem()
+	value, _ := keyField.ValueOf(ctx, rv)
+
+	var existing T
+	err = r.db.WithContext(ctx).Where(clause.Eq{Column: clause.Column{Name: keyField.DBName}, Value: value}).Take(&existing).Error
+	if err == gorm.ErrRecordNotFound {
+		return true, r.db.WithContext(ctx).Omit(clause.Associations).Create(entity).Error
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Update the existing row in place, leaving unmapped columns untouched
+	id, _ := pk.ValueOf(ctx, reflect.ValueOf(&existing).Elem())
+	if err := pk.Set(ctx, rv, id); err != nil {
+		return false, err
+	}
+	return false, r.db.WithContext(ctx).Model(entity).Select(fields).Omit(clause.Associations).Updates(entity).Error
+}
+
+// EstimateCount returns the row count estimate kept in the table statistics,
+// which is much cheaper than COUNT(*) on large tables
+func (r *GormRepository[T]) EstimateCount(ctx context.Context) (int64, error) {
+	sch, err := r.schema()
+	if err != nil {
+		return 0, err
+	}
+
+	var rows int64
+	err = r.reader(ctx).
+		Raw("SELECT COALESCE(TABLE_ROWS, 0) FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?", sch.Table).
+		Scan(&rows).Error
+	return rows, err
+}
+
+// schema returns the parsed GORM schema of T
+func (r *GormRepository[T]) schema() (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
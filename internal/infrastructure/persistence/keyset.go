@@ -0,0 +1,194 @@
+package persistence
+
+import (
+	"belcamp/internal/domain/valueobject"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ListCursor returns one page of entities using keyset pagination on the sort
+// column with the primary key as tie-breaker. It never counts the table; set
+// page.Approximate to get an estimate from the table statistics instead.
+// Invalid or stale cursors restart from the first page.
+func (r *GormRepository[T]) ListCursor(ctx context.Context, query valueobject.ListQuery, page *valueobject.CursorPagination) ([]T, error) {
+	sortField, pk, err := r.sortFields(query.Sort)
+	if err != nil {
+		return nil, err
+	}
+	if sortField == nil {
+		sortField = pk
+	}
+
+	token, backward := page.After, false
+	if token == "" && page.Before != "" {
+		token, backward = page.Before, true
+	}
+
+	var cursor *valueobject.Cursor
+	if token != "" {
+		if c, err := valueobject.DecodeCursor(token); err == nil && c.Sort == sortField.Name {
+			cursor = &c
+		}
+	}
+
+	// Walking backwards scans in the reverse order and flips the rows afterwards
+	desc := query.Descending() != backward
+
+	db := r.reader(ctx).Model(new(T)).Scopes(r.filtered(query))
+	for _, field := range query.Preload {
+		db = db.Preload(field)
+	}
+
+	if cursor != nil {
+		value, err := cursorValue(sortField, cursor.Value)
+		if err != nil {
+			cursor = nil
+		} else {
+			db = db.Where(keysetCondition(sortField, pk, value, cursor.ID, desc))
+		}
+	}
+
+	var entities []T
+	if err := db.Order(orderBy(sortField, pk, desc)).Limit(page.PageSize + 1).Find(&entities).Error; err != nil {
+		return nil, err
+	}
+
+	hasMore := len(entities) > page.PageSize
+	if hasMore {
+		entities = entities[:page.PageSize]
+	}
+	if backward {
+		slices.Reverse(entities)
+	}
+
+	page.Next, page.Prev = "", ""
+	if len(entities) > 0 {
+		first, last := &entities[0], &entities[len(entities)-1]
+
+		// Going forward there is a previous page whenever we started from a
+		// cursor; going backward there is always a next one
+		if backward || hasMore {
+			if page.Next, err = encodeCursor(ctx, sortField, pk, last); err != nil {
+				return nil, err
+			}
+		}
+		if (backward && hasMore) || (!backward && cursor != nil) {
+			if page.Prev, err = encodeCursor(ctx, sortField, pk, first); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if page.Approximate {
+		if page.ApproxTotal, err = r.EstimateCount(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return entities, nil
+}
+
+// sortFields resolves the sort field and the primary key of T. The sort
+// field is nil when it is not backed by a column, e.g. a computed value.
+func (r *GormRepository[T]) sortFields(sort string) (*schema.Field, *schema.Field, error) {
+	sch, err := r.schema()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pk := sch.PrioritizedPrimaryField
+	if pk == nil {
+		return nil, nil, fmt.Errorf("%s has no primary key", sch.Name)
+	}
+
+	sortField := sch.LookUpField(sort)
+	if sortField == nil || sortField.DBName == "" {
+		return nil, pk, nil
+	}
+	return sortField, pk, nil
+}
+
+// orderBy orders by the sort column and then by the primary key
+func orderBy(sortField, pk *schema.Field, desc bool) clause.OrderBy {
+	columns := []clause.OrderByColumn{{Column: clause.Column{Name: sortField.DBName}, Desc: desc}}
+	if sortField != pk {
+		columns = append(columns, clause.OrderByColumn{Column: clause.Column{Name: pk.DBName}, Desc: desc})
+	}
+	return clause.OrderBy{Columns: columns}
+}
+
+// keysetCondition selects the rows that come after the cursor in the scan
+// order. MySQL sorts NULLs first, so they precede every value when ascending
+// and follow every value when descending.
+func keysetCondition(sortField, pk *schema.Field, value any, id uint, desc bool) clause.Expression {
+	col := clause.Column{Name: sortField.DBName}
+	pkCol := clause.Column{Name: pk.DBName}
+
+	cmp := ">"
+	if desc {
+		cmp = "<"
+	}
+
+	if sortField == pk {
+		return clause.Expr{SQL: "? " + cmp + " ?", Vars: []any{pkCol, id}}
+	}
+
+	switch {
+	case value == nil && !desc:
+		return clause.Expr{SQL: "((? IS NULL AND ? > ?) OR ? IS NOT NULL)", Vars: []any{col, pkCol, id, col}}
+	case value == nil && desc:
+		return clause.Expr{SQL: "(? IS NULL AND ? < ?)", Vars: []any{col, pkCol, id}}
+	case desc:
+		return clause.Expr{SQL: "(? < ? OR (? = ? AND ? < ?) OR ? IS NULL)", Vars: []any{col, value, col, value, pkCol, id, col}}
+	default:
+		return clause.Expr{SQL: "(? > ? OR (? = ? AND ? > ?))", Vars: []any{col, value, col, value, pkCol, id}}
+	}
+}
+
+// cursorValue decodes a cursor value into the Go type of the sort field so it
+// is compared with the right SQL type. Nil pointers decode to nil.
+func cursorValue(field *schema.Field, raw json.RawMessage) (any, error) {
+	ptr := reflect.New(field.FieldType)
+	if err := json.Unmarshal(raw, ptr.Interface()); err != nil {
+		return nil, err
+	}
+
+	value := ptr.Elem()
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil, nil
+		}
+		value = value.Elem()
+	}
+	return value.Interface(), nil
+}
+
+// encodeCursor creates the cursor token pointing at the given entity
+func encodeCursor(ctx context.Context, sortField, pk *schema.Field, entity any) (string, error) {
+	rv := reflect.ValueOf(entity).Elem()
+
+	value, _ := sortField.ValueOf(ctx, rv)
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	idValue, _ := pk.ValueOf(ctx, rv)
+	id := reflect.ValueOf(idValue)
+
+	c := valueobject.Cursor{Sort: sortField.Name, Value: raw}
+	switch id.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		c.ID = uint(id.Uint())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		c.ID = uint(id.Int())
+	}
+
+	return valueobject.EncodeCursor(c)
+}
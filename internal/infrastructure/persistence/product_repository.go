@@ -2,20 +2,34 @@ package persistence
 
 import (
 	"belcamp/internal/domain/entity"
+	"belcamp/internal/service"
 	"context"
+	"slices"
 )
 
 type ProductRepository struct {
 	*GormRepository[entity.Product]
+	Slugs service.SlugService
 }
 
-// Custom repository methods
+// FindBySlug returns the product with the slug. Products whose slug changed
+// are also found by their previous slugs; their Slug then differs from the
+// one asked for, so callers can redirect to the current address.
 func (r *ProductRepository) FindBySlug(ctx context.Context, slug string) (*entity.Product, error) {
-	var product entity.Product
-	if err := r.db.WithContext(ctx).Where("slug = ?", slug).First(&product).Error; err != nil {
+	id, err := r.Slugs.Resolve(ctx, &entity.Product{}, slug)
+	if err != nil {
 		return nil, err
 	}
-	return &product, nil
+	return r.FindByID(ctx, id)
+}
+
+// UpdateFields saves only the given fields of the product. A new name, e.g.
+// edited in the list, gives the product a new slug as the form does.
+func (r *ProductRepository) UpdateFields(ctx context.Context, product *entity.Product, fields []string) error {
+	if slices.Contains(fields, "Name") {
+		return r.Slugs.SaveFields(ctx, product, fields)
+	}
+	return r.GormRepository.UpdateFields(ctx, product, fields)
 }
 
 func (r *ProductRepository) FindByCategory(ctx context.Context, categoryID uint) ([]entity.Product, error) {
@@ -0,0 +1,155 @@
+package persistence
+
+import (
+	"belcamp/internal/domain/valueobject"
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm/clause"
+)
+
+// minFullTextWord is the default innodb_ft_min_token_size; shorter words
+// are never indexed, so terms containing them are matched with LIKE
+const minFullTextWord = 3
+
+// fullTextIndexes caches, per table, the column lists of its FULLTEXT indexes
+var fullTextIndexes sync.Map
+
+// Search returns up to limit entities matching the term. The columns are
+// matched with MATCH ... AGAINST when a FULLTEXT index covers exactly those
+// columns, and with LIKE otherwise.
+func (r *GormRepository[T]) Search(ctx context.Context, config valueobject.SearchConfig, term string, limit int) ([]T, error) {
+	term = strings.TrimSpace(term)
+	if term == "" {
+		return nil, nil
+	}
+
+	sch, err := r.schema()
+	if err != nil {
+		return nil, err
+	}
+
+	var conditions []clause.Expression
+	if len(config.Columns) > 0 {
+		if query, ok := fullTextQuery(term); ok && r.hasFullText(ctx, sch.Table, config.Columns) {
+			vars := make([]any, 0, len(config.Columns)+1)
+			for _, column := range config.Columns {
+				vars = append(vars, clause.Column{Table: clause.CurrentTable, Name: column})
+			}
+			placeholders := strings.TrimSuffix(strings.Repeat("?,", len(config.Columns)), ",")
+			conditions = append(conditions, clause.Expr{
+				SQL:  "MATCH(" + placeholders + ") AGAINST (? IN BOOLEAN MODE)",
+				Vars: append(vars, query),
+			})
+		} else {
+			for _, column := range config.Columns {
+				conditions = append(conditions, clause.Like{
+					Column: clause.Column{Table: clause.CurrentTable, Name: column},
+					Value:  "%" + escapeLike(term) + "%",
+				})
+			}
+		}
+	}
+
+	if id, err := strconv.ParseUint(term, 10, 64); err == nil && config.MatchID && sch.PrioritizedPrimaryField != nil {
+		conditions = append(conditions, clause.Eq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: sch.PrioritizedPrimaryField.DBName},
+			Value:  id,
+		})
+	}
+
+	for _, related := range config.Related {
+		local := related.Local
+		if local == "" {
+			local = "id"
+		}
+		conditions = append(conditions, clause.Expr{
+			SQL: "? IN (SELECT ? FROM ? WHERE ? LIKE ?)",
+			Vars: []any{
+				clause.Column{Table: clause.CurrentTable, Name: local},
+				clause.Column{Name: related.Key},
+				clause.Table{Name: related.Table},
+				clause.Column{Name: related.Column},
+				"%" + escapeLike(term) + "%",
+			},
+		})
+	}
+
+	if len(conditions) == 0 {
+		return nil, nil
+	}
+
+	db := r.reader(ctx).Model(new(T)).Where(clause.Or(conditions...)).Limit(limit)
+	if len(config.Columns) > 0 {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: config.Columns[0]}})
+	} else if sch.PrioritizedPrimaryField != nil {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: sch.PrioritizedPrimaryField.DBName}, Desc: true})
+	}
+	for _, field := range config.Preload {
+		db = db.Preload(field)
+	}
+
+	var entities []T
+	if err := db.Find(&entities).Error; err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
+
+// hasFullText reports whether a FULLTEXT index of the table covers exactly
+// the columns, as MATCH requires. Only MySQL is checked.
+func (r *GormRepository[T]) hasFullText(ctx context.Context, table string, columns []string) bool {
+	if r.db.Dialector.Name() != "mysql" {
+		return false
+	}
+
+	indexes, ok := fullTextIndexes.Load(table)
+	if !ok {
+		var rows []struct {
+			ColumnList string
+		}
+		err := r.reader(ctx).
+			Raw("SELECT GROUP_CONCAT(COLUMN_NAME ORDER BY COLUMN_NAME) AS column_list FROM information_schema.STATISTICS "+
+				"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_TYPE = 'FULLTEXT' GROUP BY INDEX_NAME", table).
+			Scan(&rows).Error
+		if err != nil {
+			// Try again on the next search rather than caching the failure
+			return false
+		}
+
+		list := make([]string, len(rows))
+		for i, row := range rows {
+			list[i] = row.ColumnList
+		}
+		indexes, _ = fullTextIndexes.LoadOrStore(table, list)
+	}
+
+	sorted := append([]string(nil), columns...)
+	sort.Strings(sorted)
+	wanted := strings.Join(sorted, ",")
+	for _, index := range indexes.([]string) {
+		if index == wanted {
+			return true
+		}
+	}
+	return false
+}
+
+// fullTextQuery turns the term into a boolean mode query requiring every
+// word as a prefix, e.g. "red cap" becomes "+red* +cap*". It fails when a
+// word is too short to be indexed.
+func fullTextQuery(term string) (string, bool) {
+	strip := strings.NewReplacer("+", "", "-", "", "<", "", ">", "", "(", "", ")", "", "~", "", "*", "", `"`, "", "@", "")
+
+	var words []string
+	for _, word := range strings.Fields(strip.Replace(term)) {
+		if len([]rune(word)) < minFullTextWord {
+			return "", false
+		}
+		words = append(words, "+"+word+"*")
+	}
+	return strings.Join(words, " "), len(words) > 0
+}
@@ -0,0 +1,26 @@
+package setup
+
+import (
+	"belcamp/internal/domain/entity"
+	"belcamp/internal/infrastructure/handlers"
+	"belcamp/internal/infrastructure/persistence"
+	"belcamp/internal/registry"
+	"belcamp/internal/service"
+	"belcamp/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// catalogRoutes wires the catalogs, whose PDF is uploaded or generated from
+// their categories and products
+func catalogRoutes(db *gorm.DB, group *gin.RouterGroup, r registry.Resource) {
+	svc := service.NewCRUDService(persistence.NewGormRepository[entity.Catalog](db))
+	searches.Add(r.Name, svc)
+
+	catalogs := service.NewCatalogService(db, storage.Default(), service.NewPricingService(db))
+	handler := handlers.NewCatalogHandler(svc, catalogs, r.Template)
+	handler.EnableViews(service.NewViewService(db))
+	handler.RegisterDefaultRoutes(group, r.Path)
+	handler.RegisterCatalogRoutes(group, r.Path)
+}
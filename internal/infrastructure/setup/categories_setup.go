@@ -4,16 +4,21 @@ import (
 	"belcamp/internal/domain/entity"
 	"belcamp/internal/infrastructure/handlers"
 	"belcamp/internal/infrastructure/persistence"
+	"belcamp/internal/registry"
 	"belcamp/internal/service"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func SetupCategories(db *gorm.DB, group *gin.RouterGroup) {
-	handlers.NewCRUDHandler(
-		service.NewCRUDService(
-			persistence.NewGormRepository[entity.Category](db),
-		), "categories",
-	).RegisterDefaultRoutes(group, "/categories")
+// categoryRoutes wires the categories, listed as a tree where they are
+// reordered and moved by drag and drop
+func categoryRoutes(db *gorm.DB, group *gin.RouterGroup, r registry.Resource) {
+	svc := service.NewCRUDService(persistence.NewGormRepository[entity.Category](db))
+	searches.Add(r.Name, svc)
+
+	handler := handlers.NewCategoryHandler(svc, service.NewCategoryService(db), r.Template)
+	handler.EnableViews(service.NewViewService(db))
+	handler.RegisterDefaultRoutes(group, r.Path)
+	handler.RegisterCategoryRoutes(group, r.Path)
 }
@@ -2,6 +2,8 @@ package setup
 
 import (
 	"belcamp/internal/infrastructure/handlers"
+	"belcamp/internal/registry"
+	"belcamp/internal/service"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -9,19 +11,28 @@ import (
 
 func SetupDashboard(db *gorm.DB, protected *gin.RouterGroup) {
 	h := &handlers.BaseHandler{}
+	stock := service.NewStockService(db)
 
 	protected.GET("/", func(c *gin.Context) {
-		dashboard(h, c)
+		dashboard(h, stock, c)
 	})
 }
 
-func dashboard(h *handlers.BaseHandler, c *gin.Context) {
+func dashboard(h *handlers.BaseHandler, stock service.StockService, c *gin.Context) {
+	// The dashboard still renders when the alerts cannot be loaded
+	lowStock, err := stock.LowStock(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+	}
+
 	data := gin.H{
 		"title":          "Dashboard",
 		"totalOrders":    150,
 		"totalProducts":  1250,
 		"totalUsers":     250,
 		"recentActivity": []string{},
+		"resources":      registry.Visible(c),
+		"lowStock":       lowStock,
 	}
 
 	h.Render(c, "dashboard.index", data, "")
@@ -0,0 +1,19 @@
+package setup
+
+import (
+	"net/http"
+
+	"belcamp/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupFiles serves the files of the local storage. Their URLs are signed, so
+// they are served without a session; S3 URLs point to the bucket instead.
+func SetupFiles(r *gin.Engine) {
+	handler, ok := storage.Default().(http.Handler)
+	if !ok {
+		return
+	}
+	r.GET(storage.LocalPath+"/*key", gin.WrapH(http.StripPrefix(storage.LocalPath, handler)))
+}
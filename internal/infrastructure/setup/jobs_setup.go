@@ -0,0 +1,15 @@
+package setup
+
+import (
+	"belcamp/internal/infrastructure/handlers"
+	"belcamp/internal/jobs"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupJobs(protected *gin.RouterGroup) {
+	handler := handlers.NewJobHandler(jobs.Default)
+
+	protected.GET("/jobs/:id", handler.Show)
+	protected.GET("/jobs/:id/download", handler.Download)
+}
@@ -4,16 +4,19 @@ import (
 	"belcamp/internal/domain/entity"
 	"belcamp/internal/infrastructure/handlers"
 	"belcamp/internal/infrastructure/persistence"
+	"belcamp/internal/registry"
 	"belcamp/internal/service"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func SetupOrders(db *gorm.DB, group *gin.RouterGroup) {
-	handlers.NewCRUDHandler(
-		service.NewCRUDService(
-			persistence.NewGormRepository[entity.Order](db),
-		), "orders",
-	).RegisterDefaultRoutes(group, "/orders")
+// orderRoutes wires orders, priced from their cart when saved
+func orderRoutes(db *gorm.DB, group *gin.RouterGroup, r registry.Resource) {
+	svc := service.NewCRUDService(persistence.NewGormRepository[entity.Order](db))
+	searches.Add(r.Name, svc)
+
+	handler := handlers.NewOrderHandler(svc, service.NewPricingService(db), r.Template)
+	handler.EnableViews(service.NewViewService(db))
+	handler.RegisterDefaultRoutes(group, r.Path)
 }
@@ -4,27 +4,47 @@ import (
 	"belcamp/internal/domain/entity"
 	"belcamp/internal/infrastructure/handlers"
 	"belcamp/internal/infrastructure/persistence"
+	"belcamp/internal/registry"
 	"belcamp/internal/service"
+	"belcamp/internal/storage"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func SetupProducts(db *gorm.DB, group *gin.RouterGroup) {
+// productRoutes wires products with their custom repository and the
+// variants import
+func productRoutes(db *gorm.DB, group *gin.RouterGroup, r registry.Resource) {
 	// Create repository
 	repo := persistence.NewGormRepository[entity.Product](db)
 
 	// Add custom repository methods if needed
+	slugs := service.NewSlugService(db)
 	productRepo := &persistence.ProductRepository{
 		GormRepository: repo.(*persistence.GormRepository[entity.Product]),
+		Slugs:          slugs,
 	}
 
 	// Create service
 	svc := service.NewCRUDService(productRepo)
+	searches.Add(r.Name, svc)
 
-	// Create handlers
-	handler := handlers.NewCRUDHandler(svc, "products")
+	// Create handlers; products override create and update to store datasheets
+	// and photos
+	pricing := service.NewPricingService(db)
+	handler := handlers.NewProductHandler(svc, pricing, service.NewVariantService(db), service.NewMediaService(db, storage.Default()), slugs, r.Template, storage.Default())
+	handler.EnableViews(service.NewViewService(db))
 
 	// Register routes
-	handler.RegisterDefaultRoutes(group, "/products")
+	handler.RegisterDefaultRoutes(group, r.Path)
+	handler.RegisterPricingRoutes(group, r.Path)
+	handler.RegisterVariantRoutes(group, r.Path)
+	handler.RegisterMediaRoutes(group, r.Path)
+	handler.RegisterSlugRoutes(group, r.Path)
+
+	// Variants are imported from their own spreadsheet, matched by SKU
+	variantSvc := service.NewCRUDService(persistence.NewGormRepository[entity.ProductVariant](db))
+	variants := handlers.NewCRUDHandler(variantSvc, "variants")
+	variants.CheckImport(handlers.VariantPriceCheck(pricing))
+	variants.RegisterImportRoutes(group, r.Path+"/variants")
 }
@@ -0,0 +1,101 @@
+package setup
+
+import (
+	"belcamp/internal/domain/entity"
+	"belcamp/internal/infrastructure/handlers"
+	"belcamp/internal/infrastructure/persistence"
+	"belcamp/internal/registry"
+	"belcamp/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RegisterResources registers the resources of the admin panel. Routes,
+// navigation and dashboard links are all generated from the registry.
+func RegisterResources() {
+	// Catalog
+	registry.Register(registry.Resource{
+		Name:      "products",
+		Icon:      "fas fa-box",
+		MenuGroup: "Catalog",
+		Order:     1,
+		Routes:    productRoutes,
+	})
+	registry.Register(registry.Resource{
+		Name:      "categories",
+		Icon:      "fas fa-tags",
+		MenuGroup: "Catalog",
+		Order:     2,
+		Routes:    categoryRoutes,
+	})
+	registry.Register(registry.Resource{
+		Name:      "stock",
+		Icon:      "fas fa-warehouse",
+		MenuGroup: "Catalog",
+		Order:     3,
+		Routes:    stockRoutes,
+	})
+	registry.Register(registry.Resource{
+		Name:      "shipments",
+		Icon:      "fas fa-truck",
+		MenuGroup: "Catalog",
+		Order:     4,
+		Routes:    shipmentRoutes,
+	})
+	registry.Register(registry.Resource{
+		Name:      "catalogs",
+		Icon:      "fas fa-book-open",
+		MenuGroup: "Catalog",
+		Order:     5,
+		Routes:    catalogRoutes,
+	})
+
+	// Sales
+	registry.Register(registry.Resource{
+		Name:      "orders",
+		Icon:      "fas fa-receipt",
+		MenuGroup: "Sales",
+		Order:     1,
+		Routes:    orderRoutes,
+	})
+	registry.Register(registry.Resource{
+		Name:      "companies",
+		Icon:      "fas fa-building",
+		MenuGroup: "Sales",
+		Order:     2,
+		Routes:    CRUD[entity.Company](),
+	})
+
+	// Access
+	registry.Register(registry.Resource{
+		Name:       "users",
+		Icon:       "fas fa-users",
+		MenuGroup:  "Access",
+		Order:      1,
+		Permission: "users.manage",
+		Routes:     CRUD[entity.User](),
+	})
+}
+
+// SetupResources registers the routes of every resource, behind its
+// permission check
+func SetupResources(db *gorm.DB, protected *gin.RouterGroup) {
+	registry.Authorize = handlers.Authorizer(service.NewPermissionService(db))
+	for _, r := range registry.All() {
+		group := protected.Group("", registry.RequirePermission(r))
+		r.Routes(db, group, r)
+	}
+}
+
+// CRUD wires the generic repository, service and handler of T
+func CRUD[T any]() registry.RoutesFunc {
+	return func(db *gorm.DB, group *gin.RouterGroup, r registry.Resource) {
+		svc := service.NewCRUDService(persistence.NewGormRepository[T](db))
+		searches.Add(r.Name, svc)
+
+		handler := handlers.NewCRUDHandler(svc, r.Template)
+		handler.EnableViews(service.NewViewService(db))
+		handler.RegisterDefaultRoutes(group, r.Path)
+	}
+}
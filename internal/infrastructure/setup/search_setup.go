@@ -0,0 +1,18 @@
+package setup
+
+import (
+	"belcamp/internal/infrastructure/handlers"
+	"belcamp/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// searches collects the resources found by the global search; resource
+// routes add their service to it
+var searches = service.NewSearchService()
+
+func SetupSearch(protected *gin.RouterGroup) {
+	handler := handlers.NewSearchHandler(searches)
+
+	protected.GET("/search", handler.Search)
+}
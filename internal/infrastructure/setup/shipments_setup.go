@@ -0,0 +1,24 @@
+package setup
+
+import (
+	"belcamp/internal/domain/entity"
+	"belcamp/internal/infrastructure/handlers"
+	"belcamp/internal/infrastructure/persistence"
+	"belcamp/internal/registry"
+	"belcamp/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// shipmentRoutes wires incoming shipments, saved with their lines and
+// received into the stock ledger
+func shipmentRoutes(db *gorm.DB, group *gin.RouterGroup, r registry.Resource) {
+	svc := service.NewCRUDService(persistence.NewGormRepository[entity.Shipment](db))
+	searches.Add(r.Name, svc)
+
+	handler := handlers.NewShipmentHandler(svc, service.NewShipmentService(db), r.Template)
+	handler.EnableViews(service.NewViewService(db))
+	handler.RegisterDefaultRoutes(group, r.Path)
+	handler.RegisterShipmentRoutes(group, r.Path)
+}
@@ -0,0 +1,16 @@
+package setup
+
+import (
+	"belcamp/internal/infrastructure/handlers"
+	"belcamp/internal/registry"
+	"belcamp/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// stockRoutes wires the stock ledger: low-stock alerts, the stock history of
+// variants and the stock of orders
+func stockRoutes(db *gorm.DB, group *gin.RouterGroup, r registry.Resource) {
+	handlers.NewStockHandler(service.NewStockService(db), service.NewShipmentService(db)).RegisterRoutes(group, r.Path)
+}
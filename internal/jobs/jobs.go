@@ -0,0 +1,206 @@
+// Package jobs runs long tasks, such as large exports, in the background.
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"belcamp/internal/logging"
+
+	"github.com/google/uuid"
+)
+
+// Status is the state of a job
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+)
+
+// Info describes the state of a job
+type Info struct {
+	ID        string
+	Kind      string
+	Status    Status
+	Progress  int // Items processed so far
+	Error     string
+	File      string // Path of the produced file
+	Filename  string // Name offered when downloading the file
+	URL       string // Page showing what the job made, for jobs without a file
+	OwnerID   any    // User that started the job
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Job is a background task that may produce a file to download. ID, Kind,
+// OwnerID and CreatedAt never change; read the rest through Snapshot.
+type Job struct {
+	Info
+
+	mu sync.RWMutex
+}
+
+// Snapshot returns a copy of the job state that is safe to read while it runs
+func (j *Job) Snapshot() Info {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.Info
+}
+
+// SetProgress records the number of processed items
+func (j *Job) SetProgress(n int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Progress = n
+	j.UpdatedAt = time.Now()
+}
+
+// SetFile records the file produced by the job
+func (j *Job) SetFile(path, filename string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.File = path
+	j.Filename = filename
+}
+
+// SetURL records the page showing what the job made
+func (j *Job) SetURL(url string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.URL = url
+}
+
+func (j *Job) setStatus(status Status, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Status = status
+	j.UpdatedAt = time.Now()
+	if err != nil {
+		j.Error = err.Error()
+	}
+}
+
+// Manager keeps track of the jobs of this process
+type Manager struct {
+	dir    string
+	ttl    time.Duration
+	mu     sync.RWMutex
+	jobs   map[string]*Job
+	logger *slog.Logger
+}
+
+// Default is the manager used by the handlers. Its files are written to
+// JOBS_DIR, "tmp/jobs" by default.
+var Default = NewManager(jobsDir(), 24*time.Hour)
+
+// NewManager creates a manager writing files to dir. Finished jobs and their
+// files are removed after ttl.
+func NewManager(dir string, ttl time.Duration) *Manager {
+	return &Manager{
+		dir:    dir,
+		ttl:    ttl,
+		jobs:   map[string]*Job{},
+		logger: logging.For("jobs"),
+	}
+}
+
+// Dir returns the directory where jobs write their files
+func (m *Manager) Dir() string {
+	return m.dir
+}
+
+// Start runs fn in the background and returns the job tracking it. The
+// request ID of ctx is kept so the job logs can be correlated.
+func (m *Manager) Start(ctx context.Context, kind string, fn func(ctx context.Context, job *Job) error) *Job {
+	now := time.Now()
+	job := &Job{Info: Info{
+		ID:        uuid.New().String(),
+		Kind:      kind,
+		Status:    StatusPending,
+		OwnerID:   logging.UserID(ctx),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}}
+
+	m.mu.Lock()
+	m.jobs[job.ID] = job
+	m.mu.Unlock()
+
+	jobCtx := logging.WithRequestID(context.Background(), logging.RequestID(ctx))
+	if job.OwnerID != nil {
+		jobCtx = logging.WithUserID(jobCtx, job.OwnerID)
+	}
+
+	go m.run(jobCtx, job, fn)
+	m.cleanup()
+
+	return job
+}
+
+// Get returns the job with the given ID
+func (m *Manager) Get(id string) (*Job, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	job, ok := m.jobs[id]
+	return job, ok
+}
+
+func (m *Manager) run(ctx context.Context, job *Job, fn func(ctx context.Context, job *Job) error) {
+	defer func() {
+		if r := recover(); r != nil {
+			m.logger.ErrorContext(ctx, "job panicked", slog.String("job", job.ID), slog.Any("panic", r))
+			job.setStatus(StatusFailed, nil)
+		}
+	}()
+
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		job.setStatus(StatusFailed, err)
+		return
+	}
+
+	job.setStatus(StatusRunning, nil)
+	m.logger.InfoContext(ctx, "job started", slog.String("job", job.ID), slog.String("kind", job.Kind))
+
+	if err := fn(ctx, job); err != nil {
+		m.logger.ErrorContext(ctx, "job failed", slog.String("job", job.ID), slog.String("error", err.Error()))
+		job.setStatus(StatusFailed, err)
+		return
+	}
+
+	job.setStatus(StatusDone, nil)
+	m.logger.InfoContext(ctx, "job finished", slog.String("job", job.ID), slog.Int("progress", job.Snapshot().Progress))
+}
+
+// cleanup forgets expired jobs and removes their files
+func (m *Manager) cleanup() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, job := range m.jobs {
+		snapshot := job.Snapshot()
+		if snapshot.Status != StatusDone && snapshot.Status != StatusFailed {
+			continue
+		}
+		if time.Since(snapshot.UpdatedAt) < m.ttl {
+			continue
+		}
+		if snapshot.File != "" {
+			os.Remove(snapshot.File)
+		}
+		delete(m.jobs, id)
+	}
+}
+
+func jobsDir() string {
+	if dir := os.Getenv("JOBS_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join("tmp", "jobs")
+}
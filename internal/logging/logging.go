@@ -0,0 +1,140 @@
+// Package logging provides structured logging for the application.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	userIDKey
+)
+
+var (
+	mu      sync.RWMutex
+	output  io.Writer = os.Stdout
+	loggers           = map[string]*slog.Logger{}
+)
+
+// Setup configures the default logger from the environment.
+//
+// LOG_FORMAT selects "json" (default) or "text" output and LOG_LEVEL sets the
+// base level. Each subsystem can override it with LOG_LEVEL_<SUBSYSTEM>,
+// e.g. LOG_LEVEL_DB=warn or LOG_LEVEL_HTTP=debug.
+func Setup() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	loggers = map[string]*slog.Logger{}
+	slog.SetDefault(newLogger(""))
+}
+
+// For returns the logger for the given subsystem
+func For(subsystem string) *slog.Logger {
+	mu.RLock()
+	logger, ok := loggers[subsystem]
+	mu.RUnlock()
+	if ok {
+		return logger
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if logger, ok := loggers[subsystem]; ok {
+		return logger
+	}
+	logger = newLogger(subsystem)
+	loggers[subsystem] = logger
+	return logger
+}
+
+// WithRequestID stores the request ID in the context
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request ID stored in the context, if any
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithUserID stores the authenticated user ID in the context
+func WithUserID(ctx context.Context, id any) context.Context {
+	return context.WithValue(ctx, userIDKey, id)
+}
+
+// UserID returns the authenticated user ID stored in the context, if any
+func UserID(ctx context.Context) any {
+	return ctx.Value(userIDKey)
+}
+
+func newLogger(subsystem string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: levelFor(subsystem)}
+
+	var handler slog.Handler
+	if strings.ToLower(os.Getenv("LOG_FORMAT")) == "text" {
+		handler = slog.NewTextHandler(output, opts)
+	} else {
+		handler = slog.NewJSONHandler(output, opts)
+	}
+
+	logger := slog.New(&contextHandler{Handler: handler})
+	if subsystem != "" {
+		logger = logger.With("subsystem", subsystem)
+	}
+	return logger
+}
+
+// levelFor resolves the level for a subsystem, falling back to LOG_LEVEL
+func levelFor(subsystem string) slog.Level {
+	if subsystem != "" {
+		if value, ok := os.LookupEnv("LOG_LEVEL_" + strings.ToUpper(subsystem)); ok {
+			return parseLevel(value)
+		}
+	}
+	return parseLevel(os.Getenv("LOG_LEVEL"))
+}
+
+func parseLevel(value string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// contextHandler adds request-scoped fields from the context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if userID := UserID(ctx); userID != nil {
+		r.AddAttrs(slog.Any("user_id", userID))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
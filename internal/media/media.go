@@ -0,0 +1,206 @@
+// Package media processes uploaded product photos: it checks their content,
+// resizes them into the configured sizes and converts them to WebP.
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"  // Registers the GIF decoder
+	_ "image/jpeg" // Registers the JPEG decoder
+	_ "image/png"  // Registers the PNG decoder
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"belcamp/internal/storage"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/google/uuid"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Registers the WebP decoder
+)
+
+// MaxUploadSize is the largest photo accepted, in bytes
+const MaxUploadSize = 10 << 20
+
+// maxPixels guards against images that are small files but huge once decoded
+const maxPixels = 50_000_000
+
+// accepted are the content types of the photos that can be uploaded
+var accepted = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Size is a thumbnail size, by the width of the resized photo
+type Size struct {
+	Name  string
+	Width int
+}
+
+// File is a processed photo or one of its thumbnails, ready to be stored
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Sizes returns the thumbnail sizes set in IMAGE_SIZES as "name:width" pairs,
+// "thumb:200,medium:800" by default
+func Sizes() []Size {
+	spec := os.Getenv("IMAGE_SIZES")
+	if spec == "" {
+		spec = "thumb:200,medium:800"
+	}
+
+	var sizes []Size
+	for _, pair := range strings.Split(spec, ",") {
+		name, width, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		n, err := strconv.Atoi(width)
+		if !ok || err != nil || n <= 0 || name == "" {
+			continue
+		}
+		sizes = append(sizes, Size{Name: name, Width: n})
+	}
+	return sizes
+}
+
+// maxWidth returns the width photos are reduced to, IMAGE_MAX_WIDTH or 2000
+// by default
+func maxWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("IMAGE_MAX_WIDTH")); err == nil && n > 0 {
+		return n
+	}
+	return 2000
+}
+
+// Sniff returns the content type of the photo from its content, rejecting
+// anything that is not a supported image whatever its extension
+func Sniff(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if !accepted[contentType] {
+		return "", fmt.Errorf("unsupported file type %s, only JPEG, PNG, GIF and WebP images are allowed", contentType)
+	}
+	return contentType, nil
+}
+
+// Process converts an uploaded photo to WebP, reduced to the maximum width,
+// and makes a thumbnail for every size. The photo is the first file
+// returned; its name is the one stored in the product.
+func Process(data []byte) ([]File, error) {
+	if len(data) > MaxUploadSize {
+		return nil, fmt.Errorf("file size exceeds %dMB limit", MaxUploadSize>>20)
+	}
+	if _, err := Sniff(data); err != nil {
+		return nil, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unreadable image: %w", err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("image of %dx%d pixels is too large", config.Width, config.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unreadable image: %w", err)
+	}
+
+	name := uuid.New().String() + ".webp"
+	photo, err := encode(resize(img, maxWidth()))
+	if err != nil {
+		return nil, err
+	}
+	files := []File{{Name: name, Data: photo}}
+
+	for _, size := range Sizes() {
+		thumb, err := encode(resize(img, size.Width))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Name: Thumb(name, size.Name), Data: thumb})
+	}
+	return files, nil
+}
+
+// Thumb returns the file name of a size of the photo. Photos uploaded before
+// the thumbnails existed are returned as they are.
+func Thumb(name, size string) string {
+	stem, ok := strings.CutSuffix(name, ".webp")
+	if !ok || !isUUID(stem) {
+		return name
+	}
+	for _, s := range Sizes() {
+		if s.Name == size {
+			return stem + "-" + size + ".webp"
+		}
+	}
+	return name
+}
+
+// URL returns the address of a size of the photo in the storage
+func URL(name, size string) string {
+	return storage.URL(Thumb(name, size))
+}
+
+// Files returns the names of the files of a photo: the photo and its
+// thumbnails
+func Files(name string) []string {
+	files := []string{name}
+	for _, size := range Sizes() {
+		if thumb := Thumb(name, size.Name); thumb != name {
+			files = append(files, thumb)
+		}
+	}
+	return files
+}
+
+// Photo returns the name of the photo a stored file belongs to, and whether
+// the file was made by Process at all
+func Photo(file string) (string, bool) {
+	stem, ok := strings.CutSuffix(file, ".webp")
+	if !ok {
+		return "", false
+	}
+	if len(stem) > 36 && stem[36] == '-' {
+		stem = stem[:36]
+	}
+	if !isUUID(stem) {
+		return "", false
+	}
+	return stem + ".webp", true
+}
+
+// isUUID reports whether s is a UUID in its canonical form, as generated for
+// the names of the photos
+func isUUID(s string) bool {
+	return len(s) == 36 && uuid.Validate(s) == nil
+}
+
+// resize scales the image down to the width, keeping its proportions.
+// Narrower images are kept as they are.
+func resize(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() <= width {
+		return img
+	}
+
+	height := max(bounds.Dy()*width/bounds.Dx(), 1)
+	resized := image.NewNRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(resized, resized.Bounds(), img, bounds, draw.Over, nil)
+	return resized
+}
+
+// encode writes the image as WebP
+func encode(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := nativewebp.Encode(&buf, img, nil); err != nil {
+		return nil, fmt.Errorf("converting to WebP: %w", err)
+	}
+	return buf.Bytes(), nil
+}
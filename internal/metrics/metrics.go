@@ -0,0 +1,173 @@
+// Package metrics provides the Prometheus metrics for the application.
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"belcamp/internal/database"
+	"belcamp/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+const namespace = "belcamp"
+
+// Registry holds every collector exposed on /metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "GORM query duration by table and operation.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"table", "operation"})
+
+	loginAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_attempts_total",
+		Help:      "Login attempts by result.",
+	}, []string{"result"})
+
+	uploads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "uploads_total",
+		Help:      "Uploaded files by kind.",
+	}, []string{"kind"})
+
+	uploadBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upload_bytes_total",
+		Help:      "Uploaded bytes by kind.",
+	}, []string{"kind"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		dbQueryDuration,
+		loginAttempts,
+		uploads,
+		uploadBytes,
+	)
+}
+
+// Register adds the database collectors: connection pool stats, per-query
+// durations and the business gauges computed from the database
+func Register(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	if err := Registry.Register(collectors.NewDBStatsCollector(sqlDB, "primary")); err != nil {
+		return err
+	}
+	for host, replica := range database.Replicas() {
+		if err := Registry.Register(collectors.NewDBStatsCollector(replica, "replica-"+host)); err != nil {
+			return err
+		}
+	}
+
+	if err := db.Use(&gormPlugin{}); err != nil {
+		return err
+	}
+
+	Registry.MustRegister(
+		businessGauge(db, "pending_user_approvals", "Users waiting for approval.",
+			"SELECT COUNT(*) FROM users WHERE status = 'new' AND deleted_at IS NULL"),
+		businessGauge(db, "open_carts", "Carts that have not been turned into an order.",
+			"SELECT COUNT(*) FROM carts WHERE deleted_at IS NULL AND NOT EXISTS "+
+				"(SELECT 1 FROM orders WHERE orders.cart_id = carts.id AND orders.deleted_at IS NULL)"),
+		businessGauge(db, "low_stock_variants", "Active variants at or below their low-stock threshold.",
+			"SELECT COUNT(*) FROM product_variants WHERE status = 1 AND availability <= low_stock_threshold AND deleted_at IS NULL"),
+	)
+
+	return nil
+}
+
+// ObserveRequest records a handled HTTP request
+func ObserveRequest(method, route string, status int, elapsed time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(elapsed.Seconds())
+}
+
+// RecordLogin records a login attempt
+func RecordLogin(success bool) {
+	result := "failure"
+	if success {
+		result = "success"
+	}
+	loginAttempts.WithLabelValues(result).Inc()
+}
+
+// RecordUpload records an uploaded file of the given kind
+func RecordUpload(kind string, size int64) {
+	uploads.WithLabelValues(kind).Inc()
+	uploadBytes.WithLabelValues(kind).Add(float64(size))
+}
+
+// Handler serves the metrics. When token is set, requests must present it as
+// a bearer token.
+func Handler(token string) gin.HandlerFunc {
+	h := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+
+	return func(c *gin.Context) {
+		if token != "" {
+			given := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				c.AbortWithStatus(http.StatusUnauthorized)
+				return
+			}
+		}
+
+		h.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// businessGauge creates a gauge evaluated with a COUNT query on every scrape
+func businessGauge(db *gorm.DB, name, help, query string) prometheus.GaugeFunc {
+	logger := logging.For("metrics")
+
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		var count int64
+		if err := db.WithContext(ctx).Raw(query).Scan(&count).Error; err != nil {
+			logger.Warn("failed to compute gauge", slog.String("gauge", name), slog.String("error", err.Error()))
+			return 0
+		}
+		return float64(count)
+	})
+}
@@ -4,6 +4,8 @@ package middleware
 import (
 	"net/http"
 
+	"belcamp/internal/logging"
+
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 )
@@ -30,6 +32,7 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		// Set user info in context
 		c.Set("userID", userID)
+		c.Request = c.Request.WithContext(logging.WithUserID(c.Request.Context(), userID))
 		c.Next()
 	}
 }
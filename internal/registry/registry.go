@@ -0,0 +1,154 @@
+// Package registry keeps the admin resources, each registered once with
+// the path, templates, menu entry and permission it is served with.
+package registry
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RoutesFunc registers the routes of a resource on the protected group
+type RoutesFunc func(db *gorm.DB, group *gin.RouterGroup, resource Resource)
+
+// Resource is an entity managed in the admin panel
+type Resource struct {
+	Name       string // Unique key, e.g. "products"
+	Label      string // Shown in the menu and on the dashboard
+	Path       string // Defaults to "/" + Name
+	Template   string // Template prefix, e.g. "products" for "products.index"; defaults to Name
+	Icon       string // Font Awesome classes, e.g. "fas fa-box"
+	MenuGroup  string // Menu section; resources without one are listed first
+	Order      int    // Position in the menu group
+	Permission string // Required to see and use the resource; empty for everyone
+	Hidden     bool   // Routed but left out of the menu and dashboard
+
+	// Routes replaces the default wiring, e.g. for specialized handlers
+	Routes RoutesFunc
+}
+
+// MenuItem is a link of the navigation
+type MenuItem struct {
+	Name string
+	URL  string
+	Icon string
+}
+
+// MenuGroup is a section of the navigation
+type MenuGroup struct {
+	Label string
+	Items []MenuItem
+}
+
+// Authorize reports whether the request may use resources requiring the
+// permission. It is set to check the permissions granted to the signed in
+// user; until then resources requiring a permission are refused.
+var Authorize = func(c *gin.Context, permission string) bool {
+	return false
+}
+
+var (
+	mu        sync.RWMutex
+	resources []Resource
+)
+
+// Register adds a resource, filling in the defaults of Path, Template and
+// Label. Registering the same name twice panics.
+func Register(r Resource) {
+	if r.Path == "" {
+		r.Path = "/" + r.Name
+	}
+	if r.Template == "" {
+		r.Template = r.Name
+	}
+	if r.Label == "" {
+		r.Label = strings.ToUpper(r.Name[:1]) + r.Name[1:]
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, existing := range resources {
+		if existing.Name == r.Name {
+			panic("registry: resource " + r.Name + " registered twice")
+		}
+	}
+	resources = append(resources, r)
+}
+
+// All returns the registered resources in menu order: menu groups in the
+// order they were first registered, then by Order within each group
+func All() []Resource {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	groups := map[string]int{}
+	for _, r := range resources {
+		if _, ok := groups[r.MenuGroup]; !ok && r.MenuGroup != "" {
+			groups[r.MenuGroup] = len(groups) + 1
+		}
+	}
+
+	all := make([]Resource, len(resources))
+	copy(all, resources)
+	sort.SliceStable(all, func(i, j int) bool {
+		if gi, gj := groups[all[i].MenuGroup], groups[all[j].MenuGroup]; gi != gj {
+			return gi < gj
+		}
+		return all[i].Order < all[j].Order
+	})
+	return all
+}
+
+// Get returns the resource registered with the name
+func Get(name string) (Resource, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, r := range resources {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Resource{}, false
+}
+
+// Visible returns the resources shown to the request, in menu order
+func Visible(c *gin.Context) []Resource {
+	var visible []Resource
+	for _, r := range All() {
+		if !r.Hidden && (r.Permission == "" || Authorize(c, r.Permission)) {
+			visible = append(visible, r)
+		}
+	}
+	return visible
+}
+
+// Menu returns the navigation of the request, starting with the dashboard
+func Menu(c *gin.Context) []MenuGroup {
+	menu := []MenuGroup{{Items: []MenuItem{{Name: "Dashboard", URL: "/", Icon: "fas fa-gauge"}}}}
+	for _, r := range Visible(c) {
+		last := &menu[len(menu)-1]
+		if r.MenuGroup != last.Label {
+			menu = append(menu, MenuGroup{Label: r.MenuGroup})
+			last = &menu[len(menu)-1]
+		}
+		last.Items = append(last.Items, MenuItem{Name: r.Label, URL: r.Path, Icon: r.Icon})
+	}
+	return menu
+}
+
+// RequirePermission aborts requests that may not use the resource
+func RequirePermission(r Resource) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if r.Permission != "" && !Authorize(c, r.Permission) {
+			c.HTML(http.StatusForbidden, "error", gin.H{"error": "You are not allowed to access " + r.Label})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
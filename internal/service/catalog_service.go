@@ -0,0 +1,340 @@
+package service
+
+import (
+	"belcamp/internal/catalogpdf"
+	"belcamp/internal/domain/entity"
+	"belcamp/internal/infrastructure/errors"
+	"belcamp/internal/logging"
+	"belcamp/internal/media"
+	"belcamp/internal/storage"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// catalogPhotoSize is the thumbnail of the photos printed in the catalogs
+const catalogPhotoSize = "medium"
+
+// CatalogService keeps the PDF of the catalogs in the storage and generates
+// it from their selected categories and products
+type CatalogService interface {
+	Selectable(ctx context.Context) ([]entity.Category, []entity.Product, error)
+	Save(ctx context.Context, catalog *entity.Catalog, isNew, pdfChanged bool) (string, error)
+	Upload(ctx context.Context, slug string, r io.Reader, size int64) (string, error)
+	Generate(ctx context.Context, catalogID uint, progress func(int)) error
+	RemoveFile(ctx context.Context, key string) error
+	Delete(ctx context.Context, catalogID uint) error
+}
+
+// catalogService implements CatalogService
+type catalogService struct {
+	db      *gorm.DB
+	store   storage.Storage
+	pricing PricingService
+}
+
+// NewCatalogService creates a new CatalogService keeping PDFs in the storage
+func NewCatalogService(db *gorm.DB, store storage.Storage, pricing PricingService) CatalogService {
+	return &catalogService{db: db, store: store, pricing: pricing}
+}
+
+// Selectable returns the categories and the active products a catalog can
+// include, by name
+func (s *catalogService) Selectable(ctx context.Context) ([]entity.Category, []entity.Product, error) {
+	var categories []entity.Category
+	if err := s.db.WithContext(ctx).Order("name").Find(&categories).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var products []entity.Product
+	err := s.db.WithContext(ctx).
+		Select("id", "name", "category_id").
+		Where("status = ?", true).
+		Order("name").
+		Find(&products).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	return categories, products, nil
+}
+
+// Save creates or updates the catalog with its slug and returns the stored PDF
+// the catalog no longer uses. The PDF is only written when pdfChanged, after
+// an upload or a removal; otherwise the stored PDF is kept, as a generation
+// may have replaced it since the catalog was loaded.
+func (s *catalogService) Save(ctx context.Context, catalog *entity.Catalog, isNew, pdfChanged bool) (string, error) {
+	var replaced string
+	err := saveWithSlug(s.db.WithContext(ctx), catalog, func(tx *gorm.DB) error {
+		replaced = ""
+		if isNew {
+			catalog.ID = 0
+			return tx.Create(catalog).Error
+		}
+
+		// The lock holds back generations until the catalog is saved
+		var stored entity.Catalog
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "pdf_path", "generated_at").
+			First(&stored, catalog.ID).Error
+		if err != nil {
+			return err
+		}
+		if !pdfChanged {
+			catalog.PDFPath, catalog.GeneratedAt = stored.PDFPath, stored.GeneratedAt
+		} else if stored.PDFPath != catalog.PDFPath {
+			replaced = stored.PDFPath
+		}
+		return tx.Save(catalog).Error
+	})
+	return replaced, err
+}
+
+// Upload stores an uploaded PDF for the catalog and returns its key
+func (s *catalogService) Upload(ctx context.Context, slug string, r io.Reader, size int64) (string, error) {
+	key := catalogKey(slug)
+	if err := s.store.Put(ctx, key, r, size, "application/pdf"); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// Generate lays out the products of the catalog as a PDF, stores it and
+// replaces the previous PDF of the catalog. progress receives the number of
+// products laid out.
+func (s *catalogService) Generate(ctx context.Context, catalogID uint, progress func(int)) error {
+	var catalog entity.Catalog
+	if err := s.db.WithContext(ctx).First(&catalog, catalogID).Error; err != nil {
+		return err
+	}
+
+	products, err := s.products(ctx, catalog)
+	if err != nil {
+		return err
+	}
+	if len(products) == 0 {
+		return &errors.DomainError{Code: errors.ErrValidation.Code, Message: "The catalog has no active products, select categories or products first"}
+	}
+
+	doc := catalogpdf.Document{Title: catalog.Name, Date: time.Now()}
+	if catalog.Description != nil {
+		doc.Description = *catalog.Description
+	}
+
+	// Products come ordered by category, each category is a section
+	for i := range products {
+		product := &products[i]
+		title := "Outros produtos"
+		if product.Category != nil {
+			title = product.Category.Name
+		}
+		if n := len(doc.Sections); n == 0 || doc.Sections[n-1].Title != title {
+			doc.Sections = append(doc.Sections, catalogpdf.Section{Title: title})
+		}
+
+		section := &doc.Sections[len(doc.Sections)-1]
+		section.Products = append(section.Products, s.catalogProduct(ctx, product))
+		if progress != nil {
+			progress(i + 1)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := catalogpdf.Write(&buf, doc); err != nil {
+		return fmt.Errorf("laying out the catalog: %w", err)
+	}
+	key := catalogKey(catalog.Slug)
+	if err := s.store.Put(ctx, key, &buf, int64(buf.Len()), "application/pdf"); err != nil {
+		return err
+	}
+
+	// The PDF to replace is read under a lock, as the catalog may have been
+	// saved with another PDF during the layout
+	var previous string
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stored entity.Catalog
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "pdf_path").
+			First(&stored, catalog.ID).Error
+		if err != nil {
+			return err
+		}
+		previous = stored.PDFPath
+
+		now := time.Now()
+		return tx.Model(&stored).
+			Select("PDFPath", "GeneratedAt").
+			Updates(&entity.Catalog{PDFPath: key, GeneratedAt: &now}).Error
+	})
+	if err != nil {
+		s.store.Delete(ctx, key)
+		return err
+	}
+	if previous != "" {
+		return s.RemoveFile(ctx, previous)
+	}
+	return nil
+}
+
+// RemoveFile removes a PDF no catalog uses anymore
+func (s *catalogService) RemoveFile(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, key)
+}
+
+// Delete deletes the catalog and its PDF
+func (s *catalogService) Delete(ctx context.Context, catalogID uint) error {
+	var catalog entity.Catalog
+	if err := s.db.WithContext(ctx).First(&catalog, catalogID).Error; err != nil {
+		return err
+	}
+	if err := s.db.WithContext(ctx).Delete(&catalog).Error; err != nil {
+		return err
+	}
+	if catalog.PDFPath != "" {
+		return s.RemoveFile(ctx, catalog.PDFPath)
+	}
+	return nil
+}
+
+// products returns the active products of the catalog: those of its
+// categories and their subcategories, and those selected on their own.
+// They are ordered by category, then by name.
+func (s *catalogService) products(ctx context.Context, catalog entity.Catalog) ([]entity.Product, error) {
+	categoryIDs, err := s.withSubcategories(ctx, catalog.CategoryIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(categoryIDs) == 0 && len(catalog.ProductIDs) == 0 {
+		return nil, nil
+	}
+
+	query := s.db.WithContext(ctx).
+		Preload("Category").
+		Preload("ProductVariants", "status = ?", true).
+		Joins("LEFT JOIN categories ON categories.id = products.category_id").
+		Where("products.status = ?", true).
+		Order("categories.name, products.name")
+	switch {
+	case len(categoryIDs) == 0:
+		query = query.Where("products.id IN ?", []uint(catalog.ProductIDs))
+	case len(catalog.ProductIDs) == 0:
+		query = query.Where("products.category_id IN ?", categoryIDs)
+	default:
+		query = query.Where("products.category_id IN ? OR products.id IN ?", categoryIDs, []uint(catalog.ProductIDs))
+	}
+
+	var products []entity.Product
+	if err := query.Find(&products).Error; err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// withSubcategories returns the categories with all their descendants
+func (s *catalogService) withSubcategories(ctx context.Context, roots []uint) ([]uint, error) {
+	if len(roots) == 0 {
+		return nil, nil
+	}
+	var categories []entity.Category
+	if err := s.db.WithContext(ctx).Select("id", "parent_id").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	children := map[uint][]uint{}
+	for _, category := range categories {
+		if category.ParentID != nil {
+			children[*category.ParentID] = append(children[*category.ParentID], category.ID)
+		}
+	}
+
+	// The seen set also guards against parents that loop
+	seen := map[uint]bool{}
+	var ids []uint
+	queue := append([]uint(nil), roots...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+		queue = append(queue, children[id]...)
+	}
+	return ids, nil
+}
+
+// catalogProduct returns the product as printed in the catalog. Missing
+// photos and prices are left out.
+func (s *catalogService) catalogProduct(ctx context.Context, product *entity.Product) catalogpdf.Product {
+	item := catalogpdf.Product{}
+	if product.Name != nil {
+		item.Name = *product.Name
+	}
+	switch {
+	case product.ShortDescription != nil && *product.ShortDescription != "":
+		item.Description = *product.ShortDescription
+	case product.Description != nil:
+		item.Description = *product.Description
+	}
+
+	if photos, err := product.GetPhotos(); err == nil && len(photos) > 0 {
+		item.Photo = s.photo(ctx, photos[0])
+	}
+
+	if sizes, err := product.GetSizes(); err == nil && len(sizes) > 0 {
+		item.Sizes = sizes
+	} else {
+		seen := map[string]bool{}
+		for _, variant := range product.ProductVariants {
+			if variant.Size != nil && *variant.Size != "" && !seen[*variant.Size] {
+				seen[*variant.Size] = true
+				item.Sizes = append(item.Sizes, *variant.Size)
+			}
+		}
+	}
+
+	if tiers, _, err := s.pricing.Tiers(product, nil); err == nil {
+		item.Prices = tiers
+	}
+	return item
+}
+
+// photo reads a photo from the storage, in the size printed in the catalogs.
+// The photo itself is printed when that thumbnail is missing.
+func (s *catalogService) photo(ctx context.Context, name string) []byte {
+	thumb := media.Thumb(name, catalogPhotoSize)
+	r, err := s.store.Get(ctx, thumb)
+	if err != nil && thumb != name {
+		r, err = s.store.Get(ctx, name)
+	}
+	if err != nil {
+		logging.For("catalogs").WarnContext(ctx, "catalog photo missing", "photo", name, "error", err)
+		return nil
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(io.LimitReader(r, media.MaxUploadSize))
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// catalogKey returns a new storage key for a PDF of the catalog, named after
+// its slug. Keys are unique so browsers never keep an outdated catalog.
+func catalogKey(slug string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(slug))
+	return fmt.Sprintf("catalogs/%s-%s.pdf", name, uuid.New().String()[:8])
+}
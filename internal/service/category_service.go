@@ -0,0 +1,339 @@
+package service
+
+import (
+	"belcamp/internal/domain/entity"
+	"belcamp/internal/infrastructure/errors"
+	"context"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CategoryNode is a category in the tree, with the number of its products
+type CategoryNode struct {
+	Category entity.Category
+	Parent   *CategoryNode
+	Children []*CategoryNode
+	Depth    int // 0 for the root categories
+	Products int // Products of the category itself
+	Total    int // Products of the category and all its descendants
+}
+
+// Path returns the names of the category after those of its parents, e.g.
+// "Clothing › T-shirts"
+func (n *CategoryNode) Path() string {
+	names := []string{}
+	for _, category := range n.Breadcrumb() {
+		names = append(names, category.Name)
+	}
+	return strings.Join(names, " › ")
+}
+
+// Breadcrumb returns the categories from the root down to this one
+func (n *CategoryNode) Breadcrumb() []entity.Category {
+	var crumbs []entity.Category
+	for node := n; node != nil; node = node.Parent {
+		crumbs = append([]entity.Category{node.Category}, crumbs...)
+	}
+	return crumbs
+}
+
+// Contains reports whether the category is this one or one of its
+// descendants
+func (n *CategoryNode) Contains(id uint) bool {
+	if n.Category.ID == id {
+		return true
+	}
+	for _, child := range n.Children {
+		if child.Contains(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// CategoryTree is the categories arranged under their parents, siblings by
+// order then name
+type CategoryTree struct {
+	Roots []*CategoryNode
+	nodes map[uint]*CategoryNode
+}
+
+// NewCategoryTree arranges the categories under their parents. counts holds
+// the number of products by category, if known. Categories whose parent is
+// missing, or whose parents loop, are shown at the root.
+func NewCategoryTree(categories []entity.Category, counts map[uint]int) *CategoryTree {
+	tree := &CategoryTree{nodes: make(map[uint]*CategoryNode, len(categories))}
+	for _, category := range categories {
+		tree.nodes[category.ID] = &CategoryNode{Category: category, Products: counts[category.ID]}
+	}
+
+	sorted := append([]entity.Category(nil), categories...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if a, b := categoryOrder(sorted[i]), categoryOrder(sorted[j]); a != b {
+			return a < b
+		}
+		return strings.ToLower(sorted[i].Name) < strings.ToLower(sorted[j].Name)
+	})
+
+	children := map[uint][]*CategoryNode{}
+	var roots []*CategoryNode
+	for _, category := range sorted {
+		node := tree.nodes[category.ID]
+		if category.ParentID != nil && tree.nodes[*category.ParentID] != nil {
+			children[*category.ParentID] = append(children[*category.ParentID], node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	// Categories never reached from a root are in a loop of parents, the
+	// first of each loop is shown at the root
+	placed := map[uint]bool{}
+	var place func(node *CategoryNode, parent *CategoryNode)
+	place = func(node *CategoryNode, parent *CategoryNode) {
+		placed[node.Category.ID] = true
+		node.Parent = parent
+		if parent != nil {
+			node.Depth = parent.Depth + 1
+		}
+		node.Total = node.Products
+		for _, child := range children[node.Category.ID] {
+			if placed[child.Category.ID] {
+				continue
+			}
+			place(child, node)
+			node.Children = append(node.Children, child)
+			node.Total += child.Total
+		}
+	}
+	for _, root := range roots {
+		place(root, nil)
+		tree.Roots = append(tree.Roots, root)
+	}
+	for _, category := range sorted {
+		if node := tree.nodes[category.ID]; !placed[category.ID] {
+			place(node, nil)
+			tree.Roots = append(tree.Roots, node)
+		}
+	}
+	return tree
+}
+
+// Node returns the node of the category, nil when it is not in the tree
+func (t *CategoryTree) Node(id uint) *CategoryNode {
+	return t.nodes[id]
+}
+
+// Path returns the path of the category, e.g. "Clothing › T-shirts", or ""
+// when it is not in the tree
+func (t *CategoryTree) Path(id uint) string {
+	if node := t.nodes[id]; node != nil {
+		return node.Path()
+	}
+	return ""
+}
+
+// Nodes returns every node, depth first, in the order of the tree
+func (t *CategoryTree) Nodes() []*CategoryNode {
+	nodes := make([]*CategoryNode, 0, len(t.nodes))
+	var walk func([]*CategoryNode)
+	walk = func(level []*CategoryNode) {
+		for _, node := range level {
+			nodes = append(nodes, node)
+			walk(node.Children)
+		}
+	}
+	walk(t.Roots)
+	return nodes
+}
+
+// categoryOrder returns the position of the category among its siblings
+func categoryOrder(category entity.Category) int16 {
+	if category.Order == nil {
+		return 0
+	}
+	return *category.Order
+}
+
+// CategoryService arranges the categories as a tree. Categories are moved
+// without creating loops of parents, and deleted without losing their
+// products or subcategories.
+type CategoryService interface {
+	Tree(ctx context.Context) (*CategoryTree, error)
+	Save(ctx context.Context, category *entity.Category, isNew bool) error
+	Move(ctx context.Context, id uint, parentID *uint, position int) error
+	Delete(ctx context.Context, id uint, moveTo *uint) error
+}
+
+// categoryService implements CategoryService
+type categoryService struct {
+	db *gorm.DB
+}
+
+// NewCategoryService creates a new CategoryService instance
+func NewCategoryService(db *gorm.DB) CategoryService {
+	return &categoryService{db: db}
+}
+
+// Tree returns the categories as a tree, with their number of products
+func (s *categoryService) Tree(ctx context.Context) (*CategoryTree, error) {
+	return s.tree(s.db.WithContext(ctx), true)
+}
+
+// Save creates or updates the category with its slug. The parent is checked
+// against the categories locked until the category is saved, so a parent
+// moved meanwhile cannot create a loop.
+func (s *categoryService) Save(ctx context.Context, category *entity.Category, isNew bool) error {
+	return saveWithSlug(s.db.WithContext(ctx), category, func(tx *gorm.DB) error {
+		tree, err := s.tree(lockCategories(tx), false)
+		if err != nil {
+			return err
+		}
+		if err := checkParent(tree, category.ID, category.ParentID); err != nil {
+			return err
+		}
+
+		if isNew {
+			category.ID = 0
+			return tx.Create(category).Error
+		}
+		return tx.Save(category).Error
+	})
+}
+
+// Move places the category under the parent, at the root when parentID is
+// nil, at the position among its new siblings. The siblings are renumbered.
+func (s *categoryService) Move(ctx context.Context, id uint, parentID *uint, position int) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tree, err := s.tree(lockCategories(tx), false)
+		if err != nil {
+			return err
+		}
+		node := tree.Node(id)
+		if node == nil {
+			return errors.ErrNotFound
+		}
+		if err := checkParent(tree, id, parentID); err != nil {
+			return err
+		}
+
+		siblings := tree.Roots
+		if parentID != nil {
+			siblings = tree.Node(*parentID).Children
+		}
+		ordered := make([]*CategoryNode, 0, len(siblings)+1)
+		for _, sibling := range siblings {
+			if sibling.Category.ID != id {
+				ordered = append(ordered, sibling)
+			}
+		}
+		position = min(max(position, 0), len(ordered))
+		ordered = append(ordered[:position], append([]*CategoryNode{node}, ordered[position:]...)...)
+
+		for i, sibling := range ordered {
+			updates := map[string]any{"order": i}
+			if sibling.Category.ID == id {
+				updates["parent_id"] = parentID
+			}
+			err := tx.Model(&entity.Category{}).Where("id = ?", sibling.Category.ID).Updates(updates).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete deletes the category. Its products are moved to the moveTo
+// category, or left without one when moveTo is nil, and its subcategories
+// are moved up to its parent.
+func (s *categoryService) Delete(ctx context.Context, id uint, moveTo *uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var category entity.Category
+		if err := tx.First(&category, id).Error; err != nil {
+			return errors.ErrNotFound
+		}
+
+		if moveTo != nil {
+			if *moveTo == id {
+				return &errors.DomainError{Code: errors.ErrValidation.Code, Message: "Products cannot be moved to the deleted category"}
+			}
+			var count int64
+			if err := tx.Model(&entity.Category{}).Where("id = ?", *moveTo).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return &errors.DomainError{Code: errors.ErrValidation.Code, Message: "The category receiving the products does not exist"}
+			}
+		}
+
+		err := tx.Model(&entity.Product{}).Where("category_id = ?", id).Update("category_id", moveTo).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&entity.Category{}).Where("parent_id = ?", id).Update("parent_id", category.ParentID).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&category).Error
+	})
+}
+
+// tree loads the categories as a tree, with their number of products when
+// counted
+func (s *categoryService) tree(db *gorm.DB, counted bool) (*CategoryTree, error) {
+	var categories []entity.Category
+	if err := db.Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	if !counted {
+		return NewCategoryTree(categories, nil), nil
+	}
+
+	var rows []struct {
+		CategoryID uint
+		Count      int
+	}
+	err := db.Model(&entity.Product{}).
+		Select("category_id, COUNT(*) AS count").
+		Where("category_id IS NOT NULL").
+		Group("category_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		counts[row.CategoryID] = row.Count
+	}
+	return NewCategoryTree(categories, counts), nil
+}
+
+// lockCategories reads the categories with SELECT ... FOR UPDATE, so the
+// tree they form cannot change until the transaction ends
+func lockCategories(tx *gorm.DB) *gorm.DB {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"})
+}
+
+// InvalidParent is the code of the errors of a category placed under a
+// parent it cannot have
+const InvalidParent = "INVALID_PARENT"
+
+// checkParent checks that the category can be placed under the parent in
+// the tree
+func checkParent(tree *CategoryTree, id uint, parentID *uint) error {
+	if parentID == nil {
+		return nil
+	}
+	parent := tree.Node(*parentID)
+	if parent == nil {
+		return &errors.DomainError{Code: InvalidParent, Message: "The parent category does not exist"}
+	}
+	if node := tree.Node(id); node != nil && node.Contains(*parentID) {
+		return &errors.DomainError{Code: InvalidParent, Message: "A category cannot be placed inside itself or one of its subcategories"}
+	}
+	return nil
+}
@@ -1,9 +1,15 @@
 package service
 
 import (
+	"belcamp/internal/convert"
+	"belcamp/internal/domain/interfaces"
 	"belcamp/internal/domain/repository"
 	"belcamp/internal/domain/valueobject"
+	"belcamp/internal/infrastructure/errors"
 	"context"
+	"fmt"
+	"reflect"
+	"strings"
 )
 
 type CRUDService[T any] struct {
@@ -26,15 +32,216 @@ func (s *CRUDService[T]) Update(ctx context.Context, entity *T) error {
 	return s.repo.Update(ctx, entity)
 }
 
+// UpdateField parses the text value into a single field of the entity and
+// saves that column only. An empty value clears the field.
+func (s *CRUDService[T]) UpdateField(ctx context.Context, id uint, field, value string) (*T, error) {
+	entity, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	target := reflect.ValueOf(entity).Elem().FieldByName(field)
+	if !target.IsValid() || !target.CanSet() || !s.repo.HasColumn(field) {
+		return nil, &errors.DomainError{Code: errors.ErrValidation.Code, Message: field + " cannot be edited"}
+	}
+
+	if value == "" {
+		target.Set(reflect.Zero(target.Type()))
+	} else if err := convert.Set(target, value); err != nil {
+		return nil, &errors.DomainError{Code: errors.ErrValidation.Code, Message: err.Error()}
+	}
+
+	if err := s.repo.UpdateFields(ctx, entity, []string{field}); err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
 func (s *CRUDService[T]) Delete(ctx context.Context, id uint) error {
 	return s.repo.Delete(ctx, id)
 }
 
-func (s *CRUDService[T]) List(ctx context.Context, pagination *valueobject.Pagination) ([]T, *valueobject.Pagination, error) {
-	entities, total, err := s.repo.List(ctx, pagination.Page, pagination.PageSize)
+func (s *CRUDService[T]) List(ctx context.Context, pagination *valueobject.Pagination, query valueobject.ListQuery) ([]T, *valueobject.Pagination, error) {
+	entities, total, err := s.repo.List(ctx, query, pagination.Page, pagination.PageSize)
 	if err != nil {
 		return nil, nil, err
 	}
 	pagination.Total = total
 	return entities, pagination, nil
 }
+
+// ListCursor lists one keyset page; the cursors for the neighbouring pages
+// are set on the given pagination
+func (s *CRUDService[T]) ListCursor(ctx context.Context, pagination *valueobject.CursorPagination, query valueobject.ListQuery) ([]T, error) {
+	return s.repo.ListCursor(ctx, query, pagination)
+}
+
+// SortsInDatabase reports whether the list can be sorted by field in the
+// database rather than in memory
+func (s *CRUDService[T]) SortsInDatabase(field string) bool {
+	return s.repo.HasColumn(field)
+}
+
+// FindAll returns every entity matching the query
+func (s *CRUDService[T]) FindAll(ctx context.Context, query valueobject.ListQuery) ([]T, error) {
+	return s.repo.FindAll(ctx, query)
+}
+
+// Count returns the number of entities matching the query
+func (s *CRUDService[T]) Count(ctx context.Context, query valueobject.ListQuery) (int64, error) {
+	return s.repo.Count(ctx, query)
+}
+
+// EstimateCount returns the approximate number of rows of the table
+func (s *CRUDService[T]) EstimateCount(ctx context.Context) (int64, error) {
+	return s.repo.EstimateCount(ctx)
+}
+
+// Each walks every entity matching the query in keyset batches of batchSize
+// so large tables are never loaded into memory at once
+func (s *CRUDService[T]) Each(ctx context.Context, query valueobject.ListQuery, batchSize int, fn func(batch []T) error) error {
+	page := valueobject.NewCursorPagination(batchSize, "", "")
+	for {
+		entities, err := s.repo.ListCursor(ctx, query, page)
+		if err != nil {
+			return err
+		}
+		if len(entities) > 0 {
+			if err := fn(entities); err != nil {
+				return err
+			}
+		}
+		if !page.HasNext() {
+			return nil
+		}
+		page = valueobject.NewCursorPagination(batchSize, page.Next, "")
+	}
+}
+
+// Options loads select options from a related table
+func (s *CRUDService[T]) Options(ctx context.Context, source valueobject.OptionSource) ([]valueobject.FilterOption, error) {
+	return s.repo.Options(ctx, source)
+}
+
+// Search returns up to limit entities matching the term, as search results.
+// Entities that are not a SearchProvider are never found.
+func (s *CRUDService[T]) Search(ctx context.Context, term string, limit int) ([]valueobject.SearchResult, error) {
+	var zero T
+	provider, ok := any(zero).(interfaces.SearchProvider)
+	if !ok {
+		return nil, nil
+	}
+	config := provider.GetSearchConfig()
+
+	entities, err := s.repo.Search(ctx, config, term, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]valueobject.SearchResult, len(entities))
+	for i := range entities {
+		v := reflect.ValueOf(entities[i])
+		result := valueobject.SearchResult{
+			Title:    fieldText(v, config.Title),
+			Subtitle: fieldText(v, config.Subtitle),
+		}
+		if id := v.FieldByName("ID"); id.IsValid() && id.CanUint() {
+			result.ID = uint(id.Uint())
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// fieldText returns the text of a dotted field path of the struct, e.g.
+// "Company.Name", or "" when the path cannot be followed
+func fieldText(v reflect.Value, path string) string {
+	if path == "" {
+		return ""
+	}
+	for _, name := range strings.Split(path, ".") {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return ""
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return ""
+		}
+		if v = v.FieldByName(name); !v.IsValid() {
+			return ""
+		}
+	}
+	return convert.Text(v, "2006-01-02")
+}
+
+// Bulk applies a bulk action to every entity matching the selection in a
+// single transaction and returns the number of affected rows. param is the
+// value chosen by the user for actions that declare a Param column.
+func (s *CRUDService[T]) Bulk(ctx context.Context, action valueobject.SmartTableBulkAction, selection valueobject.ListQuery, param string) (int64, error) {
+	if selection.IDs != nil && len(selection.IDs) == 0 {
+		return 0, &errors.DomainError{Code: errors.ErrValidation.Code, Message: "No rows selected"}
+	}
+
+	updates := make(map[string]any, len(action.Updates)+1)
+	for column, value := range action.Updates {
+		updates[column] = value
+	}
+	if action.Param != "" {
+		if param == "" {
+			return 0, &errors.DomainError{Code: errors.ErrValidation.Code, Message: "Please choose a value for " + action.ParamLabel}
+		}
+		updates[action.Param] = param
+	}
+
+	if !action.Delete && len(updates) == 0 {
+		return 0, &errors.DomainError{Code: errors.ErrValidation.Code, Message: "Unsupported bulk action " + action.Name}
+	}
+
+	var affected int64
+	err := s.repo.Transaction(ctx, func(repo repository.Repository[T]) error {
+		var err error
+		if action.Delete {
+			affected, err = repo.DeleteWhere(ctx, selection)
+		} else {
+			affected, err = repo.UpdateWhere(ctx, selection, updates)
+		}
+		return err
+	})
+	return affected, err
+}
+
+// errDryRun rolls back the transaction of a dry-run import
+var errDryRun = &errors.DomainError{Code: "DRY_RUN", Message: "Dry run"}
+
+// Import creates or updates the entities, matched by the key field, in a
+// single transaction. Existing entities only get the given fields written.
+// Nothing is saved when a row fails or when dryRun is set; the outcomes still
+// report what each row did.
+func (s *CRUDService[T]) Import(ctx context.Context, entities []*T, key string, fields []string, dryRun bool) ([]valueobject.ImportOutcome, error) {
+	outcomes := make([]valueobject.ImportOutcome, len(entities))
+
+	err := s.repo.Transaction(ctx, func(repo repository.Repository[T]) error {
+		failed := 0
+		for i, entity := range entities {
+			created, err := repo.Upsert(ctx, entity, key, fields)
+			outcomes[i] = valueobject.ImportOutcome{Created: created, Err: err}
+			if err != nil {
+				failed++
+			}
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		if failed > 0 {
+			return &errors.DomainError{Code: errors.ErrValidation.Code, Message: fmt.Sprintf("%d row(s) failed, nothing was imported", failed)}
+		}
+		return nil
+	})
+	if err == errDryRun {
+		err = nil
+	}
+	return outcomes, err
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"belcamp/internal/domain/entity"
+	"belcamp/internal/logging"
+	"belcamp/internal/media"
+	"belcamp/internal/metrics"
+	"belcamp/internal/storage"
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// orphanAge is how long an uploaded photo may stay unused before it is
+// removed, leaving time to save the product it was uploaded for
+const orphanAge = 24 * time.Hour
+
+// ColorGallery is the photos of a product shown for one of its colors
+type ColorGallery struct {
+	Color  entity.Color
+	Photos []string
+}
+
+// MediaService stores the photos of the products with their thumbnails and
+// removes the files that no product uses anymore
+type MediaService interface {
+	Upload(ctx context.Context, data []byte) (string, error)
+	Galleries(ctx context.Context, productID uint) ([]ColorGallery, error)
+	DeleteUnused(ctx context.Context, photos []string) error
+	CleanOrphans(ctx context.Context, olderThan time.Duration) (int, error)
+}
+
+// mediaService implements MediaService, keeping files in the storage
+type mediaService struct {
+	db    *gorm.DB
+	store storage.Storage
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewMediaService creates a new MediaService keeping photos in the storage
+func NewMediaService(db *gorm.DB, store storage.Storage) MediaService {
+	return &mediaService{db: db, store: store}
+}
+
+// Upload converts the photo, stores it with its thumbnails and returns its
+// name. Photos left unused by abandoned forms are cleaned up from time to
+// time.
+func (s *mediaService) Upload(ctx context.Context, data []byte) (string, error) {
+	files, err := media.Process(data)
+	if err != nil {
+		return "", err
+	}
+
+	for i, file := range files {
+		err := s.store.Put(ctx, file.Name, bytes.NewReader(file.Data), int64(len(file.Data)), "image/webp")
+		if err != nil {
+			for _, written := range files[:i] {
+				s.store.Delete(ctx, written.Name)
+			}
+			return "", err
+		}
+	}
+	metrics.RecordUpload("photo", int64(len(data)))
+
+	s.sweep(ctx)
+	return files[0].Name, nil
+}
+
+// Galleries returns the photos of the product by color
+func (s *mediaService) Galleries(ctx context.Context, productID uint) ([]ColorGallery, error) {
+	var rows []entity.ProductColorPhoto
+	err := s.db.WithContext(ctx).
+		Preload("Color").
+		Joins("JOIN colors ON colors.id = product_color_photos.color_id").
+		Where("product_color_photos.product_id = ?", productID).
+		Order("colors.name").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	galleries := make([]ColorGallery, len(rows))
+	for i, row := range rows {
+		galleries[i] = ColorGallery{Color: row.Color, Photos: row.Photos}
+	}
+	return galleries, nil
+}
+
+// DeleteUnused removes the files of the photos that no product uses, e.g.
+// after they were removed from a gallery
+func (s *mediaService) DeleteUnused(ctx context.Context, photos []string) error {
+	if len(photos) == 0 {
+		return nil
+	}
+	used, err := s.used(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, photo := range photos {
+		if used[photo] {
+			continue
+		}
+		if _, ours := media.Photo(photo); !ours {
+			continue
+		}
+		for _, file := range media.Files(photo) {
+			if err := s.store.Delete(ctx, file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CleanOrphans removes the photos older than olderThan that no product uses,
+// with their thumbnails. Only files made by the upload are considered.
+func (s *mediaService) CleanOrphans(ctx context.Context, olderThan time.Duration) (int, error) {
+	objects, err := s.store.List(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+	used, err := s.used(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	removed := 0
+	for _, object := range objects {
+		photo, ours := media.Photo(object.Key)
+		if !ours || used[photo] || object.ModTime.After(cutoff) {
+			continue
+		}
+		if err := s.store.Delete(ctx, object.Key); err == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// sweep cleans up orphaned photos in the background, at most once an hour
+func (s *mediaService) sweep(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.lastSweep) < time.Hour {
+		return
+	}
+	s.lastSweep = time.Now()
+
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		logger := logging.For("media")
+		removed, err := s.CleanOrphans(ctx, orphanAge)
+		if err != nil {
+			logger.ErrorContext(ctx, "cleaning orphaned photos failed", "error", err)
+			return
+		}
+		if removed > 0 {
+			logger.InfoContext(ctx, "orphaned photos removed", "files", removed)
+		}
+	}()
+}
+
+// used returns the photos referenced by any product, deleted or not, or by
+// their color galleries
+func (s *mediaService) used(ctx context.Context) (map[string]bool, error) {
+	used := map[string]bool{}
+
+	var products []entity.Product
+	err := s.db.WithContext(ctx).Unscoped().
+		Select("id", "photos", "color_photos").
+		Find(&products).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, product := range products {
+		collectPhotos(product.Photos, used)
+		collectPhotos(product.ColorPhotos, used)
+	}
+
+	var galleries []entity.ProductColorPhoto
+	if err := s.db.WithContext(ctx).Find(&galleries).Error; err != nil {
+		return nil, err
+	}
+	for _, gallery := range galleries {
+		for _, photo := range gallery.Photos {
+			used[photo] = true
+		}
+	}
+	return used, nil
+}
+
+// collectPhotos adds every string of a JSON column to the set. The color
+// photos of the products are not a fixed shape, so any nesting is walked.
+func collectPhotos(data []byte, into map[string]bool) {
+	if len(data) == 0 {
+		return
+	}
+	var value any
+	if json.Unmarshal(data, &value) != nil {
+		return
+	}
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case string:
+			into[v] = true
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		case map[string]any:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(value)
+}
+
+// saveColorPhotos replaces the color galleries of the product with the ones
+// set on it. Products loaded without their galleries keep them.
+func saveColorPhotos(tx *gorm.DB, product *entity.Product) error {
+	if product.ProductColorPhotos == nil {
+		return nil
+	}
+	if err := tx.Where("product_id = ?", product.ID).Delete(&entity.ProductColorPhoto{}).Error; err != nil {
+		return err
+	}
+
+	for _, gallery := range product.ProductColorPhotos {
+		if len(gallery.Photos) == 0 {
+			continue
+		}
+		row := entity.ProductColorPhoto{ProductID: product.ID, ColorID: gallery.ColorID, Photos: gallery.Photos}
+		if err := tx.Omit("Product", "Color").Create(&row).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"belcamp/internal/domain/entity"
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AllPermissions is the permission granting every other one
+const AllPermissions = "*"
+
+// PermissionService keeps the permissions of the admin panel granted to the
+// users
+type PermissionService interface {
+	Granted(ctx context.Context, userID uint) (map[string]bool, error)
+	Grant(ctx context.Context, userID uint, permission string) error
+	Revoke(ctx context.Context, userID uint, permission string) error
+}
+
+// permissionService implements PermissionService
+type permissionService struct {
+	db *gorm.DB
+}
+
+// NewPermissionService creates a new PermissionService instance
+func NewPermissionService(db *gorm.DB) PermissionService {
+	return &permissionService{db: db}
+}
+
+// Granted returns the permissions of the user
+func (s *permissionService) Granted(ctx context.Context, userID uint) (map[string]bool, error) {
+	var permissions []string
+	err := s.db.WithContext(ctx).Model(&entity.UserPermission{}).Where("user_id = ?", userID).Pluck("permission", &permissions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	granted := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		granted[permission] = true
+	}
+	return granted, nil
+}
+
+// Grant gives the permission to the user, if not given yet
+func (s *permissionService) Grant(ctx context.Context, userID uint, permission string) error {
+	return s.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&entity.UserPermission{UserID: userID, Permission: permission}).Error
+}
+
+// Revoke takes the permission back from the user
+func (s *permissionService) Revoke(ctx context.Context, userID uint, permission string) error {
+	return s.db.WithContext(ctx).Where("user_id = ? AND permission = ?", userID, permission).Delete(&entity.UserPermission{}).Error
+}
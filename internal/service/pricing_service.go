@@ -0,0 +1,190 @@
+package service
+
+import (
+	"belcamp/internal/domain/entity"
+	"belcamp/internal/domain/valueobject"
+	"belcamp/internal/infrastructure/errors"
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PricingService resolves unit prices from the quantity breaks of products
+// and variants. A variant without prices of its own is sold at the prices of
+// its product.
+type PricingService interface {
+	Validate(prices entity.JSONPrices) (valueobject.PriceTable, error)
+	Tiers(product *entity.Product, variant *entity.ProductVariant) (valueobject.PriceTable, bool, error)
+	UnitPrice(product *entity.Product, variant *entity.ProductVariant, quantity int) (valueobject.Money, error)
+	Variants(ctx context.Context, product *entity.Product) ([]VariantPricing, error)
+	SaveOrder(ctx context.Context, order *entity.Order, isNew bool) error
+}
+
+// VariantPricing is the price table a variant is sold at
+type VariantPricing struct {
+	Variant   entity.ProductVariant
+	Tiers     valueobject.PriceTable
+	Inherited bool // The variant uses the prices of its product
+}
+
+// pricingService implements PricingService
+type pricingService struct {
+	db *gorm.DB
+}
+
+// NewPricingService creates a new PricingService instance
+func NewPricingService(db *gorm.DB) PricingService {
+	return &pricingService{db: db}
+}
+
+// Validate parses and validates a table of quantity breaks
+func (s *pricingService) Validate(prices entity.JSONPrices) (valueobject.PriceTable, error) {
+	table, err := prices.Table()
+	if err == nil {
+		err = table.Validate()
+	}
+	if err != nil {
+		return nil, &errors.DomainError{Code: errors.ErrValidation.Code, Message: "Invalid prices: " + err.Error()}
+	}
+	return table, nil
+}
+
+// Tiers returns the price table the variant is sold at, and whether it is
+// inherited from the product. Without a variant the product table is
+// returned.
+func (s *pricingService) Tiers(product *entity.Product, variant *entity.ProductVariant) (valueobject.PriceTable, bool, error) {
+	if variant != nil && len(variant.Prices) > 0 {
+		table, err := s.Validate(variant.Prices)
+		return table, false, err
+	}
+	if product == nil {
+		return nil, true, &errors.DomainError{Code: errors.ErrValidation.Code, Message: "The variant has no prices and no product"}
+	}
+
+	prices, err := product.GetPrices()
+	if err != nil {
+		return nil, true, err
+	}
+	table, err := s.Validate(prices)
+	return table, true, err
+}
+
+// UnitPrice returns the unit price of the variant, or of the product when
+// variant is nil, for the quantity
+func (s *pricingService) UnitPrice(product *entity.Product, variant *entity.ProductVariant, quantity int) (valueobject.Money, error) {
+	if quantity < 1 {
+		return 0, &errors.DomainError{Code: errors.ErrValidation.Code, Message: "The quantity must be at least 1"}
+	}
+
+	table, _, err := s.Tiers(product, variant)
+	if err != nil {
+		return 0, err
+	}
+	price, ok := table.PriceFor(quantity)
+	if !ok {
+		return 0, &errors.DomainError{Code: errors.ErrValidation.Code, Message: fmt.Sprintf("No price for a quantity of %d", quantity)}
+	}
+	return price, nil
+}
+
+// Variants returns the price table of each variant of the product, by SKU
+func (s *pricingService) Variants(ctx context.Context, product *entity.Product) ([]VariantPricing, error) {
+	var variants []entity.ProductVariant
+	if err := s.db.WithContext(ctx).Where("product_id = ?", product.ID).Order("sku").Find(&variants).Error; err != nil {
+		return nil, err
+	}
+
+	pricing := make([]VariantPricing, len(variants))
+	for i := range variants {
+		// Invalid tables are listed empty so the product can still be edited
+		tiers, inherited, _ := s.Tiers(product, &variants[i])
+		pricing[i] = VariantPricing{Variant: variants[i], Tiers: tiers, Inherited: inherited}
+	}
+	return pricing, nil
+}
+
+// SaveOrder saves the order with its Total set to the items plus shipping, in
+// a single transaction. The cart is priced when the order is created, and
+// again when the cart or the shipping of an order that is not placed yet
+// changes. A placed order keeps the prices it was placed at, so it can be
+// saved after its variants change price or are deleted.
+func (s *pricingService) SaveOrder(ctx context.Context, order *entity.Order, isNew bool) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		save := tx.Omit(clause.Associations)
+		if isNew {
+			if err := s.priceOrder(tx, order); err != nil {
+				return err
+			}
+			return save.Create(order).Error
+		}
+
+		var stored entity.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&stored, order.ID).Error; err != nil {
+			return err
+		}
+
+		cartChanged := order.CartID != stored.CartID
+		shipping := shippingCost(order)
+		switch {
+		case stored.Status == entity.OrderNew && (cartChanged || shipping != shippingCost(&stored)):
+			if err := s.priceOrder(tx, order); err != nil {
+				return err
+			}
+		case cartChanged:
+			return &errors.DomainError{Code: errors.ErrValidation.Code, Message: "The cart of a placed order cannot be changed"}
+		default:
+			// Keep the items at their stored prices and only follow the shipping
+			order.Total = (valueobject.MoneyFromFloat(stored.Total) - shippingCost(&stored) + shipping).Float64()
+		}
+		return save.Save(order).Error
+	})
+}
+
+// priceOrder prices the cart of the order and sets its Total
+func (s *pricingService) priceOrder(tx *gorm.DB, order *entity.Order) error {
+	subtotal, err := s.priceCart(tx, order.CartID)
+	if err != nil {
+		return err
+	}
+	order.Total = (subtotal + shippingCost(order)).Float64()
+	return nil
+}
+
+// shippingCost returns the shipping cost of the order, zero when unset
+func shippingCost(order *entity.Order) valueobject.Money {
+	if order.ShippingCost == nil {
+		return 0
+	}
+	return valueobject.MoneyFromFloat(*order.ShippingCost)
+}
+
+// priceCart sets the unit price of every item of the cart from the current
+// prices and returns the cart subtotal
+func (s *pricingService) priceCart(tx *gorm.DB, cartID uint) (valueobject.Money, error) {
+	var items []entity.CartItem
+	if err := tx.Preload("ProductVariant.Product").Where("cart_id = ?", cartID).Find(&items).Error; err != nil {
+		return 0, err
+	}
+	if len(items) == 0 {
+		return 0, &errors.DomainError{Code: errors.ErrValidation.Code, Message: fmt.Sprintf("Cart %d has no items", cartID)}
+	}
+
+	var subtotal valueobject.Money
+	for i := range items {
+		item := &items[i]
+		price, err := s.UnitPrice(&item.ProductVariant.Product, &item.ProductVariant, item.Quantity)
+		if err != nil {
+			return 0, &errors.DomainError{
+				Code:    errors.ErrValidation.Code,
+				Message: fmt.Sprintf("%s: %s", item.ProductVariant.SKU, err.Error()),
+			}
+		}
+		if err := tx.Model(item).Update("unit_price", price.Float64()).Error; err != nil {
+			return 0, err
+		}
+		subtotal += price.Mul(item.Quantity)
+	}
+	return subtotal, nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"belcamp/internal/domain/valueobject"
+	"context"
+	"sync"
+)
+
+// Searcher finds the entities of one resource, e.g. a CRUDService
+type Searcher interface {
+	Search(ctx context.Context, term string, limit int) ([]valueobject.SearchResult, error)
+}
+
+// SearchService searches every resource added to it at once
+type SearchService interface {
+	Add(source string, searcher Searcher)
+	Search(ctx context.Context, term string, limit int, sources []string) ([]valueobject.SearchGroup, error)
+}
+
+// searchService implements SearchService
+type searchService struct {
+	mu        sync.RWMutex
+	searchers map[string]Searcher
+}
+
+// NewSearchService creates a new SearchService instance
+func NewSearchService() SearchService {
+	return &searchService{searchers: make(map[string]Searcher)}
+}
+
+// Add makes the resource named source searchable
+func (s *searchService) Add(source string, searcher Searcher) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.searchers[source] = searcher
+}
+
+// Search queries the given sources concurrently and returns up to limit
+// results of each, in the order of sources. Sources without results, or
+// never added, are left out.
+func (s *searchService) Search(ctx context.Context, term string, limit int, sources []string) ([]valueobject.SearchGroup, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	groups := make([]valueobject.SearchGroup, len(sources))
+	errs := make([]error, len(sources))
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		searcher, ok := s.searchers[source]
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, source string, searcher Searcher) {
+			defer wg.Done()
+			results, err := searcher.Search(ctx, term, limit)
+			groups[i] = valueobject.SearchGroup{Source: source, Results: results}
+			errs[i] = err
+		}(i, source, searcher)
+	}
+	wg.Wait()
+
+	found := make([]valueobject.SearchGroup, 0, len(groups))
+	for i, group := range groups {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if len(group.Results) > 0 {
+			found = append(found, group)
+		}
+	}
+	return found, nil
+}
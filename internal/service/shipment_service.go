@@ -0,0 +1,346 @@
+package service
+
+import (
+	"belcamp/internal/domain/entity"
+	"belcamp/internal/infrastructure/errors"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ShipmentService manages incoming shipments. The pending lines of the
+// shipments set the next arrival of their variants, and received units enter
+// the stock through the ledger.
+type ShipmentService interface {
+	Lines(ctx context.Context, shipmentID uint) ([]entity.ShipmentLine, error)
+	Arrivals(ctx context.Context, variantID uint) ([]entity.ShipmentLine, error)
+	FindVariant(ctx context.Context, sku string) (*entity.ProductVariant, error)
+	Save(ctx context.Context, shipment *entity.Shipment, isNew bool, lines []entity.ShipmentLine, removed []uint) error
+	Delete(ctx context.Context, id uint) error
+	Receive(ctx context.Context, shipmentID uint, quantities map[uint]int) error
+}
+
+// shipmentService implements ShipmentService
+type shipmentService struct {
+	db *gorm.DB
+}
+
+// NewShipmentService creates a new ShipmentService instance
+func NewShipmentService(db *gorm.DB) ShipmentService {
+	return &shipmentService{db: db}
+}
+
+// Lines returns the lines of the shipment with their variants
+func (s *shipmentService) Lines(ctx context.Context, shipmentID uint) ([]entity.ShipmentLine, error) {
+	var lines []entity.ShipmentLine
+	err := s.db.WithContext(ctx).
+		Preload("ProductVariant.Product").
+		Where("shipment_id = ?", shipmentID).
+		Order("id").
+		Find(&lines).Error
+	return lines, err
+}
+
+// Arrivals returns the pending lines of the variant with their shipments,
+// earliest first
+func (s *shipmentService) Arrivals(ctx context.Context, variantID uint) ([]entity.ShipmentLine, error) {
+	var lines []entity.ShipmentLine
+	err := s.db.WithContext(ctx).
+		Preload("Shipment").
+		Joins("JOIN shipments ON shipments.id = shipment_lines.shipment_id AND shipments.deleted_at IS NULL").
+		Where("shipment_lines.product_variant_id = ? AND shipment_lines.quantity > shipment_lines.received", variantID).
+		Order("COALESCE(shipment_lines.expected_at, shipments.expected_at), shipment_lines.id").
+		Find(&lines).Error
+	return lines, err
+}
+
+// FindVariant returns the variant with the SKU
+func (s *shipmentService) FindVariant(ctx context.Context, sku string) (*entity.ProductVariant, error) {
+	var variant entity.ProductVariant
+	if err := s.db.WithContext(ctx).Where("sku = ?", sku).First(&variant).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, &errors.DomainError{Code: errors.ErrValidation.Code, Message: fmt.Sprintf("There is no variant with SKU %s", sku)}
+		}
+		return nil, err
+	}
+	return &variant, nil
+}
+
+// Save saves the shipment and its lines in a single transaction, deleting the
+// removed lines, and updates the next arrival of the variants involved.
+// Received units cannot be removed from a line.
+func (s *shipmentService) Save(ctx context.Context, shipment *entity.Shipment, isNew bool, lines []entity.ShipmentLine, removed []uint) error {
+	if shipment.Reference == "" {
+		return &errors.DomainError{Code: errors.ErrValidation.Code, Message: "The shipment needs a reference"}
+	}
+	if len(lines) == 0 {
+		return &errors.DomainError{Code: errors.ErrValidation.Code, Message: "Add at least one line to the shipment"}
+	}
+	variants := map[uint]bool{}
+	for _, line := range lines {
+		if variants[line.ProductVariantID] {
+			return &errors.DomainError{Code: errors.ErrValidation.Code, Message: fmt.Sprintf("%s appears in two lines", line.ProductVariant.SKU)}
+		}
+		variants[line.ProductVariantID] = true
+		if line.Quantity <= 0 {
+			return &errors.DomainError{Code: errors.ErrValidation.Code, Message: fmt.Sprintf("The quantity of %s must be positive", line.ProductVariant.SKU)}
+		}
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The saved lines are locked first, in the order Receive locks them,
+		// so units received meanwhile are not overwritten
+		var existing []entity.ShipmentLine
+		if !isNew {
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("shipment_id = ?", shipment.ID).
+				Order("id").
+				Find(&existing).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		save := tx.Omit(clause.Associations)
+		if isNew {
+			shipment.Status = entity.ShipmentOpen
+			if err := save.Create(shipment).Error; err != nil {
+				return err
+			}
+		} else if err := save.Save(shipment).Error; err != nil {
+			return err
+		}
+
+		saved := make(map[uint]entity.ShipmentLine, len(existing))
+		for _, line := range existing {
+			saved[line.ID] = line
+			variants[line.ProductVariantID] = true
+		}
+
+		for _, id := range removed {
+			line, ok := saved[id]
+			if !ok {
+				continue
+			}
+			if line.Received > 0 {
+				return &errors.DomainError{Code: errors.ErrValidation.Code, Message: "Lines with received units cannot be removed"}
+			}
+			if err := tx.Delete(&line).Error; err != nil {
+				return err
+			}
+		}
+
+		for i := range lines {
+			line := &lines[i]
+			line.ShipmentID = shipment.ID
+			if line.ID == 0 {
+				line.Received = 0
+				if err := tx.Omit(clause.Associations).Create(line).Error; err != nil {
+					return err
+				}
+				continue
+			}
+
+			current, ok := saved[line.ID]
+			if !ok {
+				return &errors.DomainError{Code: errors.ErrValidation.Code, Message: "The line does not belong to this shipment"}
+			}
+			if current.Received > 0 && current.ProductVariantID != line.ProductVariantID {
+				return &errors.DomainError{Code: errors.ErrValidation.Code, Message: "The variant of a line with received units cannot be changed"}
+			}
+			if line.Quantity < current.Received {
+				return &errors.DomainError{
+					Code:    errors.ErrValidation.Code,
+					Message: fmt.Sprintf("%d units of %s were already received", current.Received, line.ProductVariant.SKU),
+				}
+			}
+			line.Received = current.Received
+			if err := tx.Omit(clause.Associations, "Received").Save(line).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := updateShipmentStatus(tx, shipment); err != nil {
+			return err
+		}
+		return refreshArrivals(tx, variants)
+	})
+}
+
+// Delete deletes the shipment and takes its pending lines out of the next
+// arrivals. Units already received stay in stock.
+func (s *shipmentService) Delete(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var lines []entity.ShipmentLine
+		if err := tx.Where("shipment_id = ?", id).Find(&lines).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&entity.Shipment{}, id).Error; err != nil {
+			return err
+		}
+
+		variants := map[uint]bool{}
+		for _, line := range lines {
+			variants[line.ProductVariantID] = true
+		}
+		return refreshArrivals(tx, variants)
+	})
+}
+
+// Receive adds the received quantities, by line ID, to the stock. Lines may
+// be received in several parts, but never beyond their pending units.
+func (s *shipmentService) Receive(ctx context.Context, shipmentID uint, quantities map[uint]int) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var shipment entity.Shipment
+		if err := tx.First(&shipment, shipmentID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errors.ErrNotFound
+			}
+			return err
+		}
+
+		var lines []entity.ShipmentLine
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("ProductVariant").
+			Where("shipment_id = ?", shipmentID).
+			Order("id").
+			Find(&lines).Error
+		if err != nil {
+			return err
+		}
+
+		reason := "Shipment " + shipment.Reference
+		variants := map[uint]bool{}
+		for i := range lines {
+			line := &lines[i]
+			quantity := quantities[line.ID]
+			switch {
+			case quantity == 0:
+				continue
+			case quantity < 0:
+				return &errors.DomainError{Code: errors.ErrValidation.Code, Message: fmt.Sprintf("The quantity received of %s cannot be negative", line.ProductVariant.SKU)}
+			case quantity > line.Pending():
+				return &errors.DomainError{
+					Code:    errors.ErrValidation.Code,
+					Message: fmt.Sprintf("Only %d units of %s are pending", line.Pending(), line.ProductVariant.SKU),
+				}
+			}
+
+			line.Received += quantity
+			if err := tx.Model(line).Update("received", line.Received).Error; err != nil {
+				return err
+			}
+			movement := entity.StockMovement{Kind: entity.StockIncoming, ShipmentID: &shipment.ID, Reason: &reason}
+			if err := moveStock(tx, line.ProductVariantID, quantity, movement); err != nil {
+				return err
+			}
+			variants[line.ProductVariantID] = true
+		}
+		if len(variants) == 0 {
+			return &errors.DomainError{Code: errors.ErrValidation.Code, Message: "Enter the quantities received"}
+		}
+
+		if err := updateShipmentStatus(tx, &shipment); err != nil {
+			return err
+		}
+		return refreshArrivals(tx, variants)
+	})
+}
+
+// updateShipmentStatus sets the status of the shipment from its lines
+func updateShipmentStatus(tx *gorm.DB, shipment *entity.Shipment) error {
+	var totals struct {
+		Quantity int
+		Received int
+	}
+	err := tx.Model(&entity.ShipmentLine{}).
+		Select("COALESCE(SUM(quantity), 0) AS quantity, COALESCE(SUM(received), 0) AS received").
+		Where("shipment_id = ?", shipment.ID).
+		Scan(&totals).Error
+	if err != nil {
+		return err
+	}
+
+	status := entity.ShipmentOpen
+	switch {
+	case totals.Received > 0 && totals.Received >= totals.Quantity:
+		status = entity.ShipmentReceived
+	case totals.Received > 0:
+		status = entity.ShipmentPartial
+	}
+	if status == shipment.Status {
+		return nil
+	}
+	shipment.Status = status
+	return tx.Model(shipment).Update("status", status).Error
+}
+
+// arrival is a pending line of a shipment with its expected date
+type arrival struct {
+	Pending  int
+	Expected *time.Time
+}
+
+// refreshArrivals sets the next arrival of the variants from the pending
+// lines of the shipments: the units expected on the earliest date, or every
+// pending unit when none has a date
+func refreshArrivals(tx *gorm.DB, variants map[uint]bool) error {
+	ids := make([]uint, 0, len(variants))
+	for id := range variants {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		var arrivals []arrival
+		err := tx.Raw(`
+			SELECT l.quantity - l.received AS pending, COALESCE(l.expected_at, s.expected_at) AS expected
+			FROM shipment_lines l
+			JOIN shipments s ON s.id = l.shipment_id
+			WHERE l.product_variant_id = ? AND s.deleted_at IS NULL AND l.quantity > l.received`, id).Scan(&arrivals).Error
+		if err != nil {
+			return err
+		}
+
+		quantity, date := nextArrival(arrivals)
+		err = tx.Model(&entity.ProductVariant{}).
+			Where("id = ?", id).
+			Updates(map[string]any{"next_arrival_qty": quantity, "next_arrival_date": date}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextArrival returns the units expected on the earliest day and that day
+func nextArrival(arrivals []arrival) (*int, *time.Time) {
+	var date *time.Time
+	for _, a := range arrivals {
+		if a.Expected != nil && (date == nil || a.Expected.Before(*date)) {
+			date = a.Expected
+		}
+	}
+
+	quantity := 0
+	for _, a := range arrivals {
+		switch {
+		case date == nil:
+			quantity += a.Pending
+		case a.Expected != nil && sameDay(*a.Expected, *date):
+			quantity += a.Pending
+		}
+	}
+	if quantity == 0 {
+		return nil, nil
+	}
+	return &quantity, date
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Format("2006-01-02") == b.Format("2006-01-02")
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"belcamp/internal/domain/entity"
+	"belcamp/internal/domain/interfaces"
+	"belcamp/internal/infrastructure/errors"
+	"belcamp/internal/slug"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// slugAttempts is how many times a record is saved when a generated slug is
+// taken meanwhile by another save
+const slugAttempts = 3
+
+// SlugService finds the entities by their slug, now or before it changed.
+// Slugs are generated from the names, unique in their table, when the
+// entities are saved, see saveWithSlug.
+type SlugService interface {
+	Resolve(ctx context.Context, model any, slug string) (uint, error)
+	SaveFields(ctx context.Context, record interfaces.Sluggable, fields []string) error
+}
+
+// slugService implements SlugService
+type slugService struct {
+	db *gorm.DB
+}
+
+// NewSlugService creates a new SlugService instance
+func NewSlugService(db *gorm.DB) SlugService {
+	return &slugService{db: db}
+}
+
+// saveWithSlug sets the slug of the record, saves it and keeps its previous
+// slug in a single transaction. The unique index on the slugs rejects a slug
+// taken by another save meanwhile; a generated slug is then assigned again,
+// a typed one is reported as used.
+func saveWithSlug(db *gorm.DB, record interfaces.Sluggable, save func(tx *gorm.DB) error) error {
+	posted := record.GetSlug()
+	for attempt := 1; ; attempt++ {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			previous, err := assignSlug(tx, record)
+			if err != nil {
+				return err
+			}
+			if err := save(tx); err != nil {
+				return err
+			}
+			return rememberSlug(tx, record, previous)
+		})
+		if err == nil || !slugConflict(err) || attempt == slugAttempts {
+			return err
+		}
+		record.SetSlug(posted)
+	}
+}
+
+// slugConflict reports whether the save failed on a duplicate key, or on a
+// deadlock between two inserts of the same slug
+func slugConflict(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return stderrors.As(err, &mysqlErr) && (mysqlErr.Number == 1062 || mysqlErr.Number == 1213)
+}
+
+// assignSlug sets the slug of the record before it is saved and returns the
+// slug it is saved with in the database, if any. Slugs are generated from
+// the name and numbered when taken, e.g. "bota-s3-2". A slug typed by hand
+// locks it; locked slugs are kept as typed, once made URL-safe, and must be
+// free.
+func assignSlug(tx *gorm.DB, record interfaces.Sluggable) (string, error) {
+	table, id, err := identify(tx, record)
+	if err != nil {
+		return "", err
+	}
+
+	previous := ""
+	if id != 0 {
+		// Categories without a slug have none in the database
+		var stored []string
+		if err := tx.Table(table).Where("id = ?", id).Pluck("COALESCE(slug, '')", &stored).Error; err != nil {
+			return "", err
+		}
+		if len(stored) > 0 {
+			previous = stored[0]
+		}
+	}
+
+	posted := strings.TrimSpace(record.GetSlug())
+	if !record.IsSlugLocked() && posted != "" && posted != previous {
+		record.LockSlug()
+	}
+
+	if record.IsSlugLocked() {
+		locked := slug.Make(posted)
+		if locked == "" {
+			return "", &errors.DomainError{Code: errors.ErrValidation.Code, Message: "A locked slug cannot be empty"}
+		}
+		taken, err := takenSlugs(tx, table, id, locked)
+		if err != nil {
+			return "", err
+		}
+		if taken[locked] {
+			return "", &errors.DomainError{Code: errors.ErrValidation.Code, Message: fmt.Sprintf("The slug %q is already used", locked)}
+		}
+		record.SetSlug(locked)
+		return previous, nil
+	}
+
+	base := slug.Make(record.SlugSource())
+	if base == "" {
+		return "", &errors.DomainError{Code: errors.ErrValidation.Code, Message: "The slug is generated from the name, which has no letters or digits"}
+	}
+	taken, err := takenSlugs(tx, table, id, base)
+	if err != nil {
+		return "", err
+	}
+
+	// A numbered slug is kept while it matches the name, rather than changing
+	// whenever a lower number is freed
+	if previous != "" && slug.Numbered(previous, base) && !taken[previous] {
+		record.SetSlug(previous)
+		return previous, nil
+	}
+	for n := 1; ; n++ {
+		if candidate := slug.WithSuffix(base, n); !taken[candidate] {
+			record.SetSlug(candidate)
+			return previous, nil
+		}
+	}
+}
+
+// rememberSlug keeps the previous slug of the record once it is saved with
+// a new one. The new slug is dropped from the history, as it now leads to
+// the record itself.
+func rememberSlug(tx *gorm.DB, record interfaces.Sluggable, previous string) error {
+	table, id, err := identify(tx, record)
+	if err != nil {
+		return err
+	}
+	current := record.GetSlug()
+	if id == 0 || previous == current {
+		return nil
+	}
+
+	err = tx.Where("record_type = ? AND slug = ?", table, current).Delete(&entity.SlugHistory{}).Error
+	if err != nil || previous == "" {
+		return err
+	}
+	return tx.Create(&entity.SlugHistory{RecordType: table, Slug: previous, RecordID: id}).Error
+}
+
+// SaveFields saves only the given fields of the record, e.g. its name edited
+// in the list, with its slug assigned again as when the whole record is saved
+func (s *slugService) SaveFields(ctx context.Context, record interfaces.Sluggable, fields []string) error {
+	return saveWithSlug(s.db.WithContext(ctx), record, func(tx *gorm.DB) error {
+		return tx.Model(record).Select(append(slices.Clip(fields), "Slug")).Omit(clause.Associations).Updates(record).Error
+	})
+}
+
+// Resolve returns the ID of the entity of the model with the slug, now or
+// before its slug changed
+func (s *slugService) Resolve(ctx context.Context, model any, slug string) (uint, error) {
+	table, _, err := identify(s.db.WithContext(ctx), model)
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []uint
+	if err := s.db.WithContext(ctx).Model(model).Where("slug = ?", slug).Limit(1).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) > 0 {
+		return ids[0], nil
+	}
+
+	var history entity.SlugHistory
+	err = s.db.WithContext(ctx).
+		Where("record_type = ? AND slug = ?", table, slug).
+		Order("id DESC").
+		First(&history).Error
+	if err == gorm.ErrRecordNotFound {
+		return 0, errors.ErrNotFound
+	}
+	return history.RecordID, err
+}
+
+// identify returns the table and the ID of the record
+func identify(db *gorm.DB, record any) (string, uint, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(record); err != nil {
+		return "", 0, err
+	}
+
+	var id uint
+	if field := stmt.Schema.PrioritizedPrimaryField; field != nil {
+		value := reflect.Indirect(reflect.ValueOf(record))
+		if value.Kind() == reflect.Struct {
+			raw, _ := field.ValueOf(db.Statement.Context, value)
+			id, _ = raw.(uint)
+		}
+	}
+	return stmt.Schema.Table, id, nil
+}
+
+// takenSlugs returns the slugs that base, or a numbered slug of it, may clash
+// with among the other records of the table, deleted ones included as they
+// still hold the unique index. Numbered slugs of long bases are shortened,
+// so the slugs are matched on the stem of the base.
+func takenSlugs(tx *gorm.DB, table string, id uint, base string) (map[string]bool, error) {
+	var slugs []string
+	err := tx.
+		Table(table).
+		Where("id <> ? AND slug LIKE ?", id, slug.Stem(base)+"%").
+		Pluck("slug", &slugs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	taken := make(map[string]bool, len(slugs))
+	for _, used := range slugs {
+		taken[used] = true
+	}
+	return taken, nil
+}
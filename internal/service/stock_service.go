@@ -0,0 +1,368 @@
+package service
+
+import (
+	"belcamp/internal/domain/entity"
+	"belcamp/internal/infrastructure/errors"
+	"belcamp/internal/logging"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// historyLimit is the number of movements shown in the stock history
+const historyLimit = 200
+
+// StockService keeps the stock ledger. Every change of the availability of a
+// variant is recorded as a movement, and the availability is derived from the
+// balance of the latest movement.
+type StockService interface {
+	Adjust(ctx context.Context, variantID uint, quantity int, reason string) error
+	Count(ctx context.Context, variantID uint, counted int, reason string) error
+	ReserveOrder(ctx context.Context, orderID uint) error
+	CancelOrder(ctx context.Context, orderID uint) error
+	SetThreshold(ctx context.Context, variantID uint, threshold int) error
+	History(ctx context.Context, variantID uint) (*entity.ProductVariant, []entity.StockMovement, error)
+	LowStock(ctx context.Context) ([]entity.ProductVariant, error)
+	Drifts(ctx context.Context) ([]StockDrift, error)
+	Reconcile(ctx context.Context) (int, error)
+}
+
+// StockDrift is a variant whose availability no longer matches its ledger,
+// e.g. after it was changed outside the admin panel
+type StockDrift struct {
+	ID           uint
+	SKU          string
+	Availability int
+	Ledger       int
+}
+
+// stockService implements StockService
+type stockService struct {
+	db *gorm.DB
+}
+
+// NewStockService creates a new StockService instance
+func NewStockService(db *gorm.DB) StockService {
+	return &stockService{db: db}
+}
+
+// Adjust adds quantity, negative to remove stock, to the availability of the
+// variant
+func (s *stockService) Adjust(ctx context.Context, variantID uint, quantity int, reason string) error {
+	if quantity == 0 {
+		return &errors.DomainError{Code: errors.ErrValidation.Code, Message: "The adjustment cannot be zero"}
+	}
+	if err := requireReason(reason); err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return moveStock(tx, variantID, quantity, entity.StockMovement{Kind: entity.StockAdjustment, Reason: &reason})
+	})
+}
+
+// Count sets the availability of the variant to the counted stock, recording
+// the difference as an adjustment
+func (s *stockService) Count(ctx context.Context, variantID uint, counted int, reason string) error {
+	if err := requireReason(reason); err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return setStock(tx, variantID, counted, reason)
+	})
+}
+
+// ReserveOrder removes the items of the order from stock. An order is only
+// reserved once, and not at all when a variant lacks stock.
+func (s *stockService) ReserveOrder(ctx context.Context, orderID uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The order lock keeps concurrent reservations from both finding
+		// no movements
+		order, err := lockOrder(tx, orderID)
+		if err != nil {
+			return err
+		}
+
+		reserved, err := orderMovements(tx, orderID, entity.StockReservation)
+		if err != nil {
+			return err
+		}
+		if len(reserved) > 0 {
+			return &errors.DomainError{Code: errors.ErrValidation.Code, Message: fmt.Sprintf("Order %d already has stock reserved", orderID)}
+		}
+
+		var items []entity.CartItem
+		if err := tx.Where("cart_id = ?", order.CartID).Order("id").Find(&items).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return &errors.DomainError{Code: errors.ErrValidation.Code, Message: fmt.Sprintf("Order %d has no items", orderID)}
+		}
+
+		for _, item := range items {
+			movement := entity.StockMovement{Kind: entity.StockReservation, OrderID: &order.ID}
+			if err := moveStock(tx, item.ProductVariantID, -item.Quantity, movement); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CancelOrder returns the stock reserved by the order
+func (s *stockService) CancelOrder(ctx context.Context, orderID uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := lockOrder(tx, orderID); err != nil {
+			return err
+		}
+
+		cancelled, err := orderMovements(tx, orderID, entity.StockCancellation)
+		if err != nil {
+			return err
+		}
+		if len(cancelled) > 0 {
+			return &errors.DomainError{Code: errors.ErrValidation.Code, Message: fmt.Sprintf("The stock of order %d was already returned", orderID)}
+		}
+
+		reserved, err := orderMovements(tx, orderID, entity.StockReservation)
+		if err != nil {
+			return err
+		}
+		if len(reserved) == 0 {
+			return &errors.DomainError{Code: errors.ErrValidation.Code, Message: fmt.Sprintf("Order %d has no stock reserved", orderID)}
+		}
+
+		for _, reservation := range reserved {
+			movement := entity.StockMovement{Kind: entity.StockCancellation, OrderID: reservation.OrderID}
+			if err := moveStock(tx, reservation.ProductVariantID, -reservation.Quantity, movement); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SetThreshold sets the availability at or below which the variant is low on
+// stock
+func (s *stockService) SetThreshold(ctx context.Context, variantID uint, threshold int) error {
+	if threshold < 0 {
+		return &errors.DomainError{Code: errors.ErrValidation.Code, Message: "The low-stock threshold cannot be negative"}
+	}
+	return s.db.WithContext(ctx).
+		Model(&entity.ProductVariant{}).
+		Where("id = ?", variantID).
+		Update("low_stock_threshold", threshold).Error
+}
+
+// History returns the variant with its product and its latest movements,
+// newest first
+func (s *stockService) History(ctx context.Context, variantID uint) (*entity.ProductVariant, []entity.StockMovement, error) {
+	var variant entity.ProductVariant
+	if err := s.db.WithContext(ctx).Preload("Product").First(&variant, variantID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, errors.ErrNotFound
+		}
+		return nil, nil, err
+	}
+
+	var movements []entity.StockMovement
+	err := s.db.WithContext(ctx).
+		Where("product_variant_id = ?", variantID).
+		Order("id DESC").
+		Limit(historyLimit).
+		Find(&movements).Error
+	return &variant, movements, err
+}
+
+// LowStock returns the active variants at or below their low-stock
+// threshold, emptiest first
+func (s *stockService) LowStock(ctx context.Context) ([]entity.ProductVariant, error) {
+	var variants []entity.ProductVariant
+	err := s.db.WithContext(ctx).
+		Preload("Product").
+		Where("status = ? AND availability <= low_stock_threshold", true).
+		Order("availability, sku").
+		Find(&variants).Error
+	return variants, err
+}
+
+// Drifts returns the variants whose availability differs from the balance
+// of their ledger
+func (s *stockService) Drifts(ctx context.Context) ([]StockDrift, error) {
+	return drifts(s.db.WithContext(ctx))
+}
+
+// Reconcile resets the availability of drifted variants to the balance of
+// their ledger and returns how many were reset
+func (s *stockService) Reconcile(ctx context.Context) (int, error) {
+	var count int
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		found, err := drifts(tx)
+		if err != nil {
+			return err
+		}
+		for _, drift := range found {
+			err := tx.Model(&entity.ProductVariant{}).Where("id = ?", drift.ID).Update("availability", drift.Ledger).Error
+			if err != nil {
+				return err
+			}
+			logging.For("stock").WarnContext(tx.Statement.Context, "availability reconciled with the ledger",
+				"sku", drift.SKU, "availability", drift.Availability, "ledger", drift.Ledger)
+		}
+		count = len(found)
+		return nil
+	})
+	return count, err
+}
+
+// drifts compares the availability of every variant with its latest movement
+func drifts(db *gorm.DB) ([]StockDrift, error) {
+	var found []StockDrift
+	err := db.Raw(`
+		SELECT v.id, v.sku, v.availability, m.balance AS ledger
+		FROM product_variants v
+		JOIN stock_movements m ON m.id = (
+			SELECT MAX(id) FROM stock_movements WHERE product_variant_id = v.id
+		)
+		WHERE v.deleted_at IS NULL AND v.availability <> m.balance
+		ORDER BY v.sku`).Scan(&found).Error
+	return found, err
+}
+
+// lockOrder loads the order, locking its row until the transaction ends
+func lockOrder(tx *gorm.DB, orderID uint) (*entity.Order, error) {
+	var order entity.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.ErrNotFound
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// lockVariant loads the variant, locking its row until the transaction ends
+func lockVariant(tx *gorm.DB, variantID uint) (*entity.ProductVariant, error) {
+	var variant entity.ProductVariant
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&variant, variantID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.ErrNotFound
+		}
+		return nil, err
+	}
+	return &variant, nil
+}
+
+// ledgerBalance returns the availability the ledger accounts for. Variants
+// without movements enter the ledger with an opening balance of their
+// current availability.
+func ledgerBalance(tx *gorm.DB, variant *entity.ProductVariant) (int, error) {
+	var latest entity.StockMovement
+	err := tx.Where("product_variant_id = ?", variant.ID).Order("id DESC").Limit(1).Find(&latest).Error
+	if err != nil {
+		return 0, err
+	}
+	if latest.ID != 0 {
+		return latest.Balance, nil
+	}
+	if variant.Availability == 0 {
+		return 0, nil
+	}
+
+	opening := entity.StockMovement{
+		ProductVariantID: variant.ID,
+		Kind:             entity.StockOpening,
+		Quantity:         variant.Availability,
+		Balance:          variant.Availability,
+	}
+	return opening.Balance, tx.Create(&opening).Error
+}
+
+// moveStock records a movement of quantity units of the variant and sets its
+// availability to the new balance. The stock of a variant cannot go below
+// zero.
+func moveStock(tx *gorm.DB, variantID uint, quantity int, movement entity.StockMovement) error {
+	variant, err := lockVariant(tx, variantID)
+	if err != nil {
+		return err
+	}
+	balance, err := ledgerBalance(tx, variant)
+	if err != nil {
+		return err
+	}
+	if balance+quantity < 0 {
+		return &errors.DomainError{
+			Code:    errors.ErrValidation.Code,
+			Message: fmt.Sprintf("Not enough stock of %s: %d available, %d requested", variant.SKU, balance, -quantity),
+		}
+	}
+
+	movement.ProductVariantID = variant.ID
+	movement.Quantity = quantity
+	movement.Balance = balance + quantity
+	movement.UserID = actor(tx.Statement.Context)
+	if err := tx.Create(&movement).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(variant).Update("availability", movement.Balance).Error; err != nil {
+		return err
+	}
+
+	// Alert when the movement takes the variant down to its threshold
+	if balance > variant.LowStockThreshold && movement.Balance <= variant.LowStockThreshold {
+		logging.For("stock").WarnContext(tx.Statement.Context, "variant is low on stock",
+			"sku", variant.SKU, "availability", movement.Balance, "threshold", variant.LowStockThreshold)
+	}
+	return nil
+}
+
+// setStock records the adjustment taking the variant to the target
+// availability, if it differs
+func setStock(tx *gorm.DB, variantID uint, target int, reason string) error {
+	if target < 0 {
+		return &errors.DomainError{Code: errors.ErrValidation.Code, Message: "The stock cannot be negative"}
+	}
+
+	variant, err := lockVariant(tx, variantID)
+	if err != nil {
+		return err
+	}
+	balance, err := ledgerBalance(tx, variant)
+	if err != nil {
+		return err
+	}
+	if target == balance {
+		return nil
+	}
+	return moveStock(tx, variantID, target-balance, entity.StockMovement{Kind: entity.StockAdjustment, Reason: &reason})
+}
+
+// orderMovements returns the movements of a kind recorded for the order
+func orderMovements(tx *gorm.DB, orderID uint, kind string) ([]entity.StockMovement, error) {
+	var movements []entity.StockMovement
+	err := tx.Where("order_id = ? AND kind = ?", orderID, kind).Order("id").Find(&movements).Error
+	return movements, err
+}
+
+// requireReason checks that a manual movement explains itself
+func requireReason(reason string) error {
+	if strings.TrimSpace(reason) == "" {
+		return &errors.DomainError{Code: errors.ErrValidation.Code, Message: "Give a reason for the stock change"}
+	}
+	return nil
+}
+
+// actor returns the ID of the signed in user of the context, if any
+func actor(ctx context.Context) *uint {
+	id, err := strconv.ParseUint(fmt.Sprint(logging.UserID(ctx)), 10, 64)
+	if err != nil || id == 0 {
+		return nil
+	}
+	user := uint(id)
+	return &user
+}
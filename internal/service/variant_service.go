@@ -0,0 +1,301 @@
+package service
+
+import (
+	"belcamp/internal/domain/entity"
+	"belcamp/internal/infrastructure/errors"
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// skuLength is the size of the sku column of product_variants
+const skuLength = 20
+
+// VariantService manages the variants of a product, which are saved together
+// with the product
+type VariantService interface {
+	List(ctx context.Context, productID uint) ([]entity.ProductVariant, error)
+	Colors(ctx context.Context) ([]entity.Color, error)
+	Matrix(ctx context.Context, product *entity.Product, sizes []string, colors []entity.Color, prefix string, current []entity.ProductVariant) ([]entity.ProductVariant, error)
+	SaveWithProduct(ctx context.Context, product *entity.Product, isNew bool, variants []entity.ProductVariant, removed []uint) error
+}
+
+// variantService implements VariantService
+type variantService struct {
+	db *gorm.DB
+}
+
+// NewVariantService creates a new VariantService instance
+func NewVariantService(db *gorm.DB) VariantService {
+	return &variantService{db: db}
+}
+
+// List returns the variants of the product by SKU
+func (s *variantService) List(ctx context.Context, productID uint) ([]entity.ProductVariant, error) {
+	var variants []entity.ProductVariant
+	err := s.db.WithContext(ctx).Where("product_id = ?", productID).Order("sku").Find(&variants).Error
+	return variants, err
+}
+
+// Colors returns the colors variants can be made in, by name
+func (s *variantService) Colors(ctx context.Context) ([]entity.Color, error) {
+	var colors []entity.Color
+	err := s.db.WithContext(ctx).Order("name").Find(&colors).Error
+	return colors, err
+}
+
+// Matrix returns a new variant for every size and color combination missing
+// from the current variants, with a unique SKU. Without sizes or colors the
+// matrix only varies on the other.
+func (s *variantService) Matrix(ctx context.Context, product *entity.Product, sizes []string, colors []entity.Color, prefix string, current []entity.ProductVariant) ([]entity.ProductVariant, error) {
+	if len(sizes) == 0 && len(colors) == 0 {
+		return nil, &errors.DomainError{Code: errors.ErrValidation.Code, Message: "Choose sizes or colors to generate variants"}
+	}
+	if len(sizes) == 0 {
+		sizes = []string{""}
+	}
+
+	combos := make([]*entity.Color, 0, len(colors))
+	for i := range colors {
+		combos = append(combos, &colors[i])
+	}
+	if len(combos) == 0 {
+		combos = append(combos, nil)
+	}
+
+	exists := map[string]bool{}
+	taken := map[string]bool{}
+	for _, v := range current {
+		exists[variantKey(derefString(v.Size), v.ColorName())] = true
+		taken[strings.ToUpper(v.SKU)] = true
+	}
+
+	if prefix = skuPart(prefix, 10); prefix == "" {
+		prefix = skuPart(product.Slug, 10)
+	}
+	if prefix == "" {
+		prefix = fmt.Sprintf("P%d", product.ID)
+	}
+
+	var variants []entity.ProductVariant
+	for _, color := range combos {
+		for _, size := range sizes {
+			size = strings.TrimSpace(size)
+			name := ""
+			if color != nil {
+				name = color.Name
+			}
+			if exists[variantKey(size, name)] {
+				continue
+			}
+			exists[variantKey(size, name)] = true
+
+			parts := []string{prefix}
+			if color != nil {
+				parts = append(parts, skuPart(color.Name, 3))
+			}
+			if size != "" {
+				parts = append(parts, skuPart(size, 4))
+			}
+			sku, err := s.uniqueSKU(ctx, strings.Join(parts, "-"), taken)
+			if err != nil {
+				return nil, err
+			}
+			taken[sku] = true
+
+			variant := entity.ProductVariant{ProductID: product.ID, SKU: sku, Status: true}
+			if size != "" {
+				variant.Size = &size
+			}
+			variant.SetColor(color)
+			variants = append(variants, variant)
+		}
+	}
+	return variants, nil
+}
+
+// uniqueSKU returns base, or base with a numeric suffix, unused by any
+// variant and not in taken
+func (s *variantService) uniqueSKU(ctx context.Context, base string, taken map[string]bool) (string, error) {
+	base = strings.Trim(truncate(base, skuLength), "-")
+	for n := 1; ; n++ {
+		sku := base
+		if n > 1 {
+			suffix := fmt.Sprintf("-%d", n)
+			sku = strings.TrimRight(truncate(base, skuLength-len(suffix)), "-") + suffix
+		}
+		if taken[sku] {
+			continue
+		}
+
+		var count int64
+		err := s.db.WithContext(ctx).Unscoped().Model(&entity.ProductVariant{}).Where("sku = ?", sku).Count(&count).Error
+		if err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return sku, nil
+		}
+	}
+}
+
+// SaveWithProduct saves the product with its slug, its color galleries and
+// its variants in a single transaction, deleting the removed variants.
+// Variants must have unique SKUs and size and color combinations. Their
+// availability is left as it is: new variants start without stock, which is
+// then adjusted or counted through the stock ledger.
+func (s *variantService) SaveWithProduct(ctx context.Context, product *entity.Product, isNew bool, variants []entity.ProductVariant, removed []uint) error {
+	if err := validateVariants(variants); err != nil {
+		return err
+	}
+
+	created := make([]bool, len(variants))
+	for i := range variants {
+		created[i] = variants[i].ID == 0
+	}
+
+	return saveWithSlug(s.db.WithContext(ctx), product, func(tx *gorm.DB) error {
+		// A save retried for its slug creates the new records again
+		if isNew {
+			product.ID = 0
+		}
+		for i := range variants {
+			if created[i] {
+				variants[i].ID = 0
+			}
+		}
+
+		save := tx.Omit(clause.Associations)
+		if isNew {
+			if err := save.Create(product).Error; err != nil {
+				return err
+			}
+		} else if err := save.Save(product).Error; err != nil {
+			return err
+		}
+		if err := saveColorPhotos(tx, product); err != nil {
+			return err
+		}
+
+		if len(removed) > 0 {
+			err := tx.Where("product_id = ? AND id IN ?", product.ID, removed).Delete(&entity.ProductVariant{}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		for i := range variants {
+			variant := &variants[i]
+			if variant.ID != 0 {
+				// Only variants of this product may be changed
+				var count int64
+				if err := tx.Model(&entity.ProductVariant{}).Where("id = ? AND product_id = ?", variant.ID, product.ID).Count(&count).Error; err != nil {
+					return err
+				}
+				if count == 0 {
+					return &errors.DomainError{Code: errors.ErrValidation.Code, Message: fmt.Sprintf("Variant %s does not belong to this product", variant.SKU)}
+				}
+			}
+
+			var count int64
+			err := tx.Model(&entity.ProductVariant{}).Where("sku = ? AND id <> ?", variant.SKU, variant.ID).Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return &errors.DomainError{Code: errors.ErrValidation.Code, Message: fmt.Sprintf("SKU %s is already used by another variant", variant.SKU)}
+			}
+
+			// Availability only changes through the stock ledger
+			variant.ProductID = product.ID
+			if variant.ID == 0 {
+				variant.Availability = 0
+				err = tx.Omit(clause.Associations).Create(variant).Error
+			} else {
+				err = tx.Omit(clause.Associations, "Availability", "NextArrivalQty", "NextArrivalDate").Save(variant).Error
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// validateVariants checks the variants before anything is saved
+func validateVariants(variants []entity.ProductVariant) error {
+	skus := map[string]bool{}
+	combos := map[string]string{}
+	for _, v := range variants {
+		sku := strings.ToUpper(v.SKU)
+		key := variantKey(derefString(v.Size), v.ColorName())
+
+		var problem string
+		switch {
+		case v.SKU == "":
+			problem = "Every variant needs a SKU"
+		case len(v.SKU) > skuLength:
+			problem = fmt.Sprintf("SKU %s is longer than %d characters", v.SKU, skuLength)
+		case skus[sku]:
+			problem = fmt.Sprintf("SKU %s is used twice", v.SKU)
+		case combos[key] != "" && key != "|":
+			problem = fmt.Sprintf("%s and %s have the same size and color", combos[key], v.SKU)
+		case v.LowStockThreshold < 0:
+			problem = fmt.Sprintf("The low-stock threshold of %s cannot be negative", v.SKU)
+		}
+		if problem != "" {
+			return &errors.DomainError{Code: errors.ErrValidation.Code, Message: problem}
+		}
+
+		if len(v.Prices) > 0 {
+			table, err := v.Prices.Table()
+			if err == nil {
+				err = table.Validate()
+			}
+			if err != nil {
+				return &errors.DomainError{Code: errors.ErrValidation.Code, Message: fmt.Sprintf("Invalid prices of %s: %s", v.SKU, err.Error())}
+			}
+		}
+
+		skus[sku] = true
+		combos[key] = v.SKU
+	}
+	return nil
+}
+
+// variantKey identifies the size and color combination of a variant
+func variantKey(size, color string) string {
+	return strings.ToLower(strings.TrimSpace(size)) + "|" + strings.ToLower(strings.TrimSpace(color))
+}
+
+// skuPart keeps the first n letters and digits of s, in upper case
+func skuPart(s string, n int) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(s) {
+		if b.Len() >= n {
+			break
+		}
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"belcamp/internal/domain/entity"
+	"belcamp/internal/infrastructure/errors"
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ViewService stores the SmartTable views saved by each user
+type ViewService interface {
+	List(ctx context.Context, userID uint, resource string) ([]entity.SavedView, error)
+	Default(ctx context.Context, userID uint, resource string) (*entity.SavedView, error)
+	Save(ctx context.Context, view *entity.SavedView) error
+	Delete(ctx context.Context, userID uint, id uint) error
+}
+
+// viewService implements ViewService
+type viewService struct {
+	db *gorm.DB
+}
+
+// NewViewService creates a new ViewService instance
+func NewViewService(db *gorm.DB) ViewService {
+	return &viewService{db: db}
+}
+
+// List returns the views of the user for the resource, by name
+func (s *viewService) List(ctx context.Context, userID uint, resource string) ([]entity.SavedView, error) {
+	var views []entity.SavedView
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND resource = ?", userID, resource).
+		Order("name").
+		Find(&views).Error
+	return views, err
+}
+
+// Default returns the default view of the user for the resource, or nil
+// when there is none
+func (s *viewService) Default(ctx context.Context, userID uint, resource string) (*entity.SavedView, error) {
+	var views []entity.SavedView
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND resource = ? AND is_default = ?", userID, resource, true).
+		Limit(1).
+		Find(&views).Error
+	if err != nil || len(views) == 0 {
+		return nil, err
+	}
+	return &views[0], nil
+}
+
+// Save stores the view, replacing the view of the user with the same name.
+// A default view stops being the default of the other views.
+func (s *viewService) Save(ctx context.Context, view *entity.SavedView) error {
+	view.Name = strings.TrimSpace(view.Name)
+	if view.Name == "" {
+		return &errors.DomainError{Code: errors.ErrValidation.Code, Message: "Please name the view"}
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		owner := tx.Model(&entity.SavedView{}).Where("user_id = ? AND resource = ?", view.UserID, view.Resource)
+
+		if view.IsDefault {
+			if err := owner.Session(&gorm.Session{}).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+
+		var existing entity.SavedView
+		err := owner.Session(&gorm.Session{}).Where("name = ?", view.Name).Take(&existing).Error
+		if err == nil {
+			view.ID = existing.ID
+			view.CreatedAt = existing.CreatedAt
+		} else if err != gorm.ErrRecordNotFound {
+			return err
+		}
+		return tx.Save(view).Error
+	})
+}
+
+// Delete deletes a view of the user
+func (s *viewService) Delete(ctx context.Context, userID uint, id uint) error {
+	result := s.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&entity.SavedView{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Package slug turns names into the URL slugs of the shop, e.g.
+// "Calçado de Proteção" into "calcado-de-protecao".
+package slug
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MaxLength is the longest slug made, suffix included
+const MaxLength = 100
+
+// maxSuffix is the longest suffix WithSuffix adds, e.g. "-999999999"
+const maxSuffix = 10
+
+// transliterations spells the accented letters of Portuguese, and of the
+// other Latin languages met in product names, in ASCII
+var transliterations = map[rune]string{
+	'á': "a", 'à': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a",
+	'é': "e", 'è': "e", 'ê': "e", 'ë': "e",
+	'í': "i", 'ì': "i", 'î': "i", 'ï': "i",
+	'ó': "o", 'ò': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o",
+	'ú': "u", 'ù': "u", 'û': "u", 'ü': "u",
+	'ç': "c", 'ñ': "n", 'ý': "y", 'ÿ': "y",
+	'æ': "ae", 'œ': "oe", 'ß': "ss",
+	'ª': "a", 'º': "o",
+}
+
+// Make returns the slug of the text: lower case ASCII letters and digits,
+// words joined by single hyphens. Other characters separate words.
+func Make(text string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(text) {
+		var part string
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			part = string(r)
+		case transliterations[r] != "":
+			part = transliterations[r]
+		default:
+			hyphen = b.Len() > 0
+			continue
+		}
+		if hyphen {
+			b.WriteByte('-')
+			hyphen = false
+		}
+		b.WriteString(part)
+	}
+	return trim(b.String(), MaxLength)
+}
+
+// WithSuffix returns the slug numbered n, e.g. "bota-s3-2", shortened so
+// the suffix fits in MaxLength. The first slug has no suffix.
+func WithSuffix(slug string, n int) string {
+	if n <= 1 {
+		return slug
+	}
+	suffix := "-" + strconv.Itoa(n)
+	return trim(slug, MaxLength-len(suffix)) + suffix
+}
+
+// Numbered reports whether the slug is the base slug, with or without the
+// suffix added by WithSuffix
+func Numbered(slug, base string) bool {
+	if slug == base {
+		return true
+	}
+	i := strings.LastIndexByte(slug, '-')
+	if i < 0 || i == len(slug)-1 || slug[i+1] == '0' {
+		return false
+	}
+	n, err := strconv.Atoi(slug[i+1:])
+	return err == nil && slug == WithSuffix(base, n)
+}
+
+// Stem returns the start shared by the base slug and all its numbered slugs,
+// which WithSuffix shortens to fit their suffix
+func Stem(base string) string {
+	if len(base) > MaxLength-maxSuffix {
+		return base[:MaxLength-maxSuffix]
+	}
+	return base
+}
+
+// trim shortens the slug to n bytes at most, without a trailing hyphen
+func trim(slug string, n int) string {
+	if len(slug) > n {
+		slug = slug[:n]
+	}
+	return strings.TrimRight(slug, "-")
+}
@@ -0,0 +1,119 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "Calçado de Proteção", want: "calcado-de-protecao"},
+		{in: "Bota S3", want: "bota-s3"},
+		{in: "  Luvas -- Nitrilo!  ", want: "luvas-nitrilo"},
+		{in: "Ação & Reação", want: "acao-reacao"},
+		{in: "Æther Straße", want: "aether-strasse"},
+		{in: "1ª Linha Nº 2", want: "1a-linha-no-2"},
+		{in: "ÁÉÍÓÚ ÃÕ Ç", want: "aeiou-ao-c"},
+		{in: "bota-s3", want: "bota-s3"},
+		{in: "日本", want: ""},
+		{in: "!!!", want: ""},
+		{in: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := Make(tt.in); got != tt.want {
+			t.Errorf("Make(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMakeTruncates(t *testing.T) {
+	long := strings.Repeat("a", MaxLength+20)
+	if got := Make(long); len(got) != MaxLength {
+		t.Errorf("Make of %d letters has %d bytes, want %d", len(long), len(got), MaxLength)
+	}
+
+	// A cut right after a word keeps no trailing hyphen
+	words := strings.Repeat("a", MaxLength-1) + " bcd"
+	if got := Make(words); got != strings.Repeat("a", MaxLength-1) {
+		t.Errorf("Make(%q) = %q, want the first word only", words, got)
+	}
+}
+
+func TestWithSuffix(t *testing.T) {
+	long := strings.Repeat("a", MaxLength)
+	tests := []struct {
+		slug string
+		n    int
+		want string
+	}{
+		{slug: "bota-s3", n: 0, want: "bota-s3"},
+		{slug: "bota-s3", n: 1, want: "bota-s3"},
+		{slug: "bota-s3", n: 2, want: "bota-s3-2"},
+		{slug: "bota-s3", n: 12, want: "bota-s3-12"},
+		{slug: long, n: 2, want: long[:MaxLength-2] + "-2"},
+		{slug: long, n: 123, want: long[:MaxLength-4] + "-123"},
+		{slug: strings.Repeat("a", MaxLength-3) + "-bc", n: 2, want: strings.Repeat("a", MaxLength-3) + "-2"},
+	}
+	for _, tt := range tests {
+		got := WithSuffix(tt.slug, tt.n)
+		if got != tt.want {
+			t.Errorf("WithSuffix(%q, %d) = %q, want %q", tt.slug, tt.n, got, tt.want)
+		}
+		if len(got) > MaxLength {
+			t.Errorf("WithSuffix(%q, %d) has %d bytes, more than %d", tt.slug, tt.n, len(got), MaxLength)
+		}
+	}
+}
+
+func TestNumbered(t *testing.T) {
+	long := strings.Repeat("a", MaxLength)
+	tests := []struct {
+		slug string
+		base string
+		want bool
+	}{
+		{slug: "bota-s3", base: "bota-s3", want: true},
+		{slug: "bota-s3-2", base: "bota-s3", want: true},
+		{slug: "bota-s3-15", base: "bota-s3", want: true},
+		{slug: "bota-s3-02", base: "bota-s3", want: false},
+		{slug: "bota-s3-1", base: "bota-s3", want: false},
+		{slug: "bota-s3-", base: "bota-s3", want: false},
+		{slug: "bota-s3-x", base: "bota-s3", want: false},
+		{slug: "bota-s4-2", base: "bota-s3", want: false},
+		{slug: "bota", base: "bota-s3", want: false},
+		{slug: WithSuffix(long, 7), base: long, want: true},
+		{slug: long[:MaxLength-2] + "-7", base: long[:MaxLength-1], want: true},
+	}
+	for _, tt := range tests {
+		if got := Numbered(tt.slug, tt.base); got != tt.want {
+			t.Errorf("Numbered(%q, %q) = %t, want %t", tt.slug, tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestStem(t *testing.T) {
+	long := strings.Repeat("a", MaxLength)
+	tests := []struct {
+		base string
+		want string
+	}{
+		{base: "bota-s3", want: "bota-s3"},
+		{base: long[:MaxLength-maxSuffix], want: long[:MaxLength-maxSuffix]},
+		{base: long, want: long[:MaxLength-maxSuffix]},
+	}
+	for _, tt := range tests {
+		if got := Stem(tt.base); got != tt.want {
+			t.Errorf("Stem(%q) = %q, want %q", tt.base, got, tt.want)
+		}
+	}
+
+	// Every numbered slug of the base starts with its stem
+	for _, n := range []int{2, 99, 123456789} {
+		if numbered := WithSuffix(long, n); !strings.HasPrefix(numbered, Stem(long)) {
+			t.Errorf("WithSuffix(long, %d) = %q does not start with the stem", n, numbered)
+		}
+	}
+}
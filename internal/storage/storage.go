@@ -0,0 +1,157 @@
+// Package storage keeps the uploaded files, such as datasheets, product photos
+// and catalogs, on the local disk or in an S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotExist is returned when a file is not in the storage
+var ErrNotExist = errors.New("file does not exist")
+
+// URLExpiry is how long the URLs of the files shown in the pages stay valid
+const URLExpiry = 12 * time.Hour
+
+// Object describes a stored file
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Storage stores files by key. Keys are slash-separated relative paths, e.g.
+// "photo.webp" or "catalogs/2025.pdf".
+type Storage interface {
+	// Put stores the content read from r under the key, replacing any file
+	// stored there
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the file, returning ErrNotExist when there is none
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the file; removing a missing file is not an error
+	Delete(ctx context.Context, key string) error
+	// URL returns an address the browser can download the file from until
+	// the expiry has passed
+	URL(ctx context.Context, key string, expiry time.Duration) (string, error)
+	// List returns the files whose key starts with the prefix
+	List(ctx context.Context, prefix string) ([]Object, error)
+}
+
+var (
+	mu      sync.Mutex
+	current Storage
+)
+
+// Setup selects the storage used by the application from STORAGE_DRIVER,
+// "local" (default) or "s3"
+func Setup() error {
+	store, err := New(os.Getenv("STORAGE_DRIVER"))
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	current = store
+	return nil
+}
+
+// Default returns the storage set up for the application, the local disk
+// when Setup was not called. Its URLs are then signed with a random key and
+// only last until a restart.
+func Default() Storage {
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil {
+		current = NewLocal(localDir(), randomSecret())
+	}
+	return current
+}
+
+// URL returns the address of a file of the default storage, or "" when it
+// cannot be made
+func URL(key string) string {
+	if key == "" {
+		return ""
+	}
+	url, err := Default().URL(context.Background(), key, URLExpiry)
+	if err != nil {
+		slog.Error("making file URL failed", "key", key, "error", err)
+		return ""
+	}
+	return url
+}
+
+// New returns the storage for the driver, configured from the environment:
+//
+//   - local: files are kept in UPLOAD_DIR, "uploads" by default, and
+//     their URLs are signed with STORAGE_SECRET
+//   - s3: files are kept in S3_BUCKET at S3_ENDPOINT, see S3ConfigFromEnv
+func New(driver string) (Storage, error) {
+	switch driver {
+	case "", "local":
+		dir := localDir()
+		if public(dir) {
+			return nil, fmt.Errorf("UPLOAD_DIR %q is inside public/, which is served to anyone; move the files out of it", dir)
+		}
+		key := os.Getenv("STORAGE_SECRET")
+		if key == "" {
+			return nil, errors.New("STORAGE_SECRET must be set to sign the URLs of the local storage")
+		}
+		return NewLocal(dir, []byte(key)), nil
+	case "s3":
+		return NewS3(S3ConfigFromEnv())
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q, expected local or s3", driver)
+	}
+}
+
+// localDir returns the directory of the local storage, UPLOAD_DIR or
+// "uploads" by default. Files are only served through signed URLs, so the
+// directory is kept out of public/.
+func localDir() string {
+	if dir := os.Getenv("UPLOAD_DIR"); dir != "" {
+		return dir
+	}
+	return "uploads"
+}
+
+// public reports whether the directory is inside public/, which the server
+// serves without authentication
+func public(dir string) bool {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	root, err := filepath.Abs("public")
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, abs)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// randomSecret returns a random key for signing URLs
+func randomSecret() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
+
+// checkKey rejects keys that could reach outside the storage
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) || path.Clean(key) != key || strings.HasPrefix(key, "..") {
+		return fmt.Errorf("invalid file key %q", key)
+	}
+	return nil
+}
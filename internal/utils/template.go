@@ -3,9 +3,14 @@ package utils
 
 import (
 	"belcamp/internal/domain/entity"
+	"belcamp/internal/logging"
+	"belcamp/internal/media"
+	"belcamp/internal/registry"
+	"belcamp/internal/storage"
 	"fmt"
 	"html/template"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -23,32 +28,18 @@ type TemplateData struct {
 	CurrentPage string
 	CurrentYear int
 	Csrf_token  string
-	MenuItems   []MenuItem
-}
-
-type MenuItem struct {
-	Name string
-	URL  string
+	Menu        []registry.MenuGroup
 }
 
 // NewTemplateData creates a new TemplateData struct
 func NewTemplateData(c *gin.Context) gin.H {
-	var menuItems = []MenuItem{
-		{"Dashboard", "/"},
-		{"Products", "/products"},
-		{"Orders", "/orders"},
-		{"Companies", "/companies"},
-		{"Users", "/users"},
-		{"Categories", "/categories"},
-	}
-
 	data := gin.H{}
 
 	data["User"] = getCurrentUser(c)
 	data["CurrentPage"] = getCurrentPage(c)
 	data["CurrentYear"] = time.Now().Year()
 	data["csrf_token"] = csrf.Token(c.Request)
-	data["MenuItems"] = menuItems
+	data["Menu"] = registry.Menu(c)
 
 	return data
 }
@@ -183,10 +174,69 @@ func setupTemplateFunctions(r *gin.Engine) {
 			bStr := fmt.Sprintf("%v", b)
 			return aStr == bStr
 		},
-		"dict": dict,
+		// inList reports whether value is an item of a comma separated list
+		"inList": func(value, list interface{}) bool {
+			if list == nil {
+				return false
+			}
+			for _, item := range strings.Split(fmt.Sprint(list), ",") {
+				if item == fmt.Sprint(value) {
+					return true
+				}
+			}
+			return false
+		},
+		"dict":      dict,
+		"withQuery": withQuery,
+		"photoURL":  media.URL,
+		"fileURL":   fileURL,
 	})
 }
 
+// fileURL returns the address of a stored file, given by its key or a
+// pointer to it. Missing files have no address.
+func fileURL(key interface{}) string {
+	switch key := key.(type) {
+	case string:
+		return storage.URL(key)
+	case *string:
+		if key != nil {
+			return storage.URL(*key)
+		}
+	}
+	return ""
+}
+
+// withQuery returns rawURL with the given key/value query parameters
+// replaced. Empty values remove the parameter.
+func withQuery(rawURL string, pairs ...interface{}) (string, error) {
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("withQuery requires key/value pairs")
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return "", fmt.Errorf("withQuery keys must be strings")
+		}
+		value := fmt.Sprint(pairs[i+1])
+		if value == "" {
+			query.Del(key)
+		} else {
+			query.Set(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
 func dict(values ...interface{}) (map[string]interface{}, error) {
 	if len(values)%2 != 0 {
 		return nil, fmt.Errorf("dict requires an even number of arguments")
@@ -202,6 +252,14 @@ func dict(values ...interface{}) (map[string]interface{}, error) {
 	return dict, nil
 }
 
+// templates holds every parsed template, for HasTemplate
+var templates *template.Template
+
+// HasTemplate reports whether a template with the given name was loaded
+func HasTemplate(name string) bool {
+	return templates != nil && templates.Lookup(name) != nil
+}
+
 func SetupTemplates(r *gin.Engine) {
 	setupTemplateFunctions(r)
 	// Create a new template and specify the functions
@@ -226,6 +284,7 @@ func SetupTemplates(r *gin.Engine) {
 
 	// Set the template engine
 	r.SetHTMLTemplate(tmpl)
+	templates = tmpl
 
 	// Serve static files
 	r.Static("/assets", "./assets")
@@ -244,7 +303,7 @@ func registerTemplateFiles(files []string, tmpl *template.Template) {
 		name := strings.TrimPrefix(file, "templates/")
 		name = strings.TrimSuffix(name, ".html")
 		name = strings.ReplaceAll(name, "/", ".")
-		log.Printf("Loading template: %s as %s", file, name)
+		logging.For("templates").Debug("loading template", "file", file, "name", name)
 
 		// Parse the template with its path as name
 		_, err = tmpl.New(name).Parse(string(content))